@@ -1,17 +1,86 @@
 package config
 
 import (
+	"time"
+
 	"github.com/apex/log"
 	"github.com/caarlos0/env/v6"
 )
 
 // Config configuration.
 type Config struct {
-	RedisURL              string   `env:"REDIS_URL" envDefault:"redis://:@localhost:6379/1"`
-	GitHubTokens          []string `env:"GITHUB_TOKENS" envDefault:"XXX"`
-	GitHubPageSize        int      `env:"GITHUB_PAGE_SIZE" envDefault:"100"`
-	GitHubMaxRateUsagePct int      `env:"GITHUB_MAX_RATE_LIMIT_USAGE" envDefault:"80"`
-	Listen                string   `env:"LISTEN" envDefault:"127.0.0.1:3000"`
+	RedisURL              string        `env:"REDIS_URL" envDefault:"redis://:@localhost:6379/1"`
+	RedisPoolSize         int           `env:"REDIS_POOL_SIZE" envDefault:"50"`
+	RedisDialTimeout      time.Duration `env:"REDIS_DIAL_TIMEOUT" envDefault:"5s"`
+	RedisReadTimeout      time.Duration `env:"REDIS_READ_TIMEOUT" envDefault:"1s"`
+	RedisWriteTimeout     time.Duration `env:"REDIS_WRITE_TIMEOUT" envDefault:"1s"`
+	RedisMaxRetries       int           `env:"REDIS_MAX_RETRIES" envDefault:"2"`
+	RedisClusterAddrs     []string      `env:"REDIS_CLUSTER_ADDRS"`
+	RedisSentinelAddrs    []string      `env:"REDIS_SENTINEL_ADDRS"`
+	RedisSentinelMaster   string        `env:"REDIS_SENTINEL_MASTER"`
+	GitHubTokens          []string      `env:"GITHUB_TOKENS"`
+	GitHubTokensFile      string        `env:"GITHUB_TOKENS_FILE"`
+	GitHubTokensReload    time.Duration `env:"GITHUB_TOKENS_RELOAD_INTERVAL" envDefault:"0"`
+	GitHubPageSize        int           `env:"GITHUB_PAGE_SIZE" envDefault:"100"`
+	GitHubMaxRateUsagePct int           `env:"GITHUB_MAX_RATE_LIMIT_USAGE" envDefault:"80"`
+	GitHubFetchTimeout    time.Duration `env:"GITHUB_FETCH_TIMEOUT" envDefault:"45s"`
+	GitHubRepoTTL         time.Duration `env:"GITHUB_REPO_TTL" envDefault:"5m"`
+	GitHubEtagTTL         time.Duration `env:"GITHUB_ETAG_TTL" envDefault:"720h"`
+	GitHubUserAgent       string        `env:"GITHUB_USER_AGENT"`
+	GitHubStarsMediaType  string        `env:"GITHUB_STARS_MEDIA_TYPE" envDefault:"application/vnd.github.v3.star+json"`
+	GitHubMaxInFlight     int           `env:"GITHUB_MAX_IN_FLIGHT_FETCHES" envDefault:"32"`
+	GitHubValidateTokens  bool          `env:"GITHUB_VALIDATE_TOKENS" envDefault:"false"`
+	GitHubBreakerFailures int           `env:"GITHUB_BREAKER_FAILURES" envDefault:"5"`
+	GitHubBreakerCooldown time.Duration `env:"GITHUB_BREAKER_COOLDOWN" envDefault:"30s"`
+	GitHubRefreshInterval time.Duration `env:"GITHUB_REFRESH_INTERVAL" envDefault:"1m"`
+	GitHubRefreshScale    int           `env:"GITHUB_REFRESH_SCALE_STARS" envDefault:"1000"`
+	GitHubRefreshMax      time.Duration `env:"GITHUB_REFRESH_MAX_INTERVAL" envDefault:"1h"`
+	GitHubWarmupCooldown  time.Duration `env:"GITHUB_WARMUP_COOLDOWN" envDefault:"5m"`
+	GitHubRetryBudget     int           `env:"GITHUB_RETRY_BUDGET" envDefault:"10"`
+	GitHubRepoConcurrency int           `env:"GITHUB_REPO_CONCURRENCY" envDefault:"2"`
+	GitHubTokenMaxConc    int           `env:"GITHUB_TOKEN_MAX_CONCURRENCY" envDefault:"10"`
+	GitHubMaxStars        int           `env:"GITHUB_MAX_STARS" envDefault:"0"`
+	GitHubDedupeStars     bool          `env:"GITHUB_DEDUPE_STARGAZERS" envDefault:"false"`
+	GitHubRepoPrecheck    bool          `env:"GITHUB_REPO_PRECHECK" envDefault:"false"`
+	GitHubRefetchLastPage bool          `env:"GITHUB_REFETCH_LAST_PAGE" envDefault:"false"`
+	GitHubSamplePages     int           `env:"GITHUB_SAMPLE_PAGES" envDefault:"0"`
+	GitHubPageCapWarnPct  int           `env:"GITHUB_PAGE_CAP_WARNING_PCT" envDefault:"90"`
+	ReadOnly              bool          `env:"READ_ONLY" envDefault:"false"`
+	Listen                string        `env:"LISTEN" envDefault:"127.0.0.1:3000"`
+	InstanceName          string        `env:"INSTANCE_NAME" envDefault:"starcharts"`
+	LogFormat             string        `env:"LOG_FORMAT" envDefault:"text"`
+	LogLevel              string        `env:"LOG_LEVEL" envDefault:"info"`
+	Watermark             string        `env:"WATERMARK"`
+	WatermarkDisabled     bool          `env:"WATERMARK_DISABLED" envDefault:"false"`
+	AdminSecret           string        `env:"ADMIN_SECRET"`
+	BadgeCacheTTL         time.Duration `env:"BADGE_CACHE_TTL" envDefault:"1m"`
+	RecentStargazersMax   int           `env:"RECENT_STARGAZERS_MAX" envDefault:"100"`
+	ChartStreaming        bool          `env:"CHART_STREAMING" envDefault:"false"`
+	ChartMaxPoints        int           `env:"CHART_MAX_POINTS" envDefault:"5000"`
+	ChartTheme            string        `env:"CHART_THEME"`
+	ChartLineColor        string        `env:"CHART_LINE_COLOR"`
+	ChartWidth            int           `env:"CHART_WIDTH"`
+	ChartHeight           int           `env:"CHART_HEIGHT"`
+	ChartFont             string        `env:"CHART_FONT"`
+	ChartStaleTTL         time.Duration `env:"CHART_STALE_TTL" envDefault:"0"`
+	ChartDropWeekendStars bool          `env:"CHART_DROP_WEEKEND_STARS" envDefault:"false"`
+	ChartCalendarTicks    bool          `env:"CHART_CALENDAR_TICKS" envDefault:"false"`
+	ChartBusyPlaceholder  bool          `env:"CHART_BUSY_PLACEHOLDER" envDefault:"false"`
+	ChartDataURIMaxSize   int           `env:"CHART_DATA_URI_MAX_SIZE" envDefault:"262144"`
+	ChartRequestBudget    time.Duration `env:"CHART_REQUEST_BUDGET" envDefault:"0"`
+	RepoAllowlist         []string      `env:"REPO_ALLOWLIST"`
+	RepoBlocklist         []string      `env:"REPO_BLOCKLIST"`
+	RepoOverridesFile     string        `env:"REPO_OVERRIDES_FILE"`
+	TrustedProxies        []string      `env:"TRUSTED_PROXIES"`
+	BlobCacheEndpoint     string        `env:"BLOB_CACHE_ENDPOINT" envDefault:"https://s3.amazonaws.com"`
+	BlobCacheBucket       string        `env:"BLOB_CACHE_BUCKET"`
+	BlobCacheRegion       string        `env:"BLOB_CACHE_REGION" envDefault:"us-east-1"`
+	BlobCacheAccessKey    string        `env:"BLOB_CACHE_ACCESS_KEY"`
+	BlobCacheSecretKey    string        `env:"BLOB_CACHE_SECRET_KEY"`
+	BlobCacheMinSize      int           `env:"BLOB_CACHE_MIN_SIZE" envDefault:"65536"`
+	MemoryCacheSize       int           `env:"MEMORY_CACHE_SIZE" envDefault:"0"`
+	MemoryCacheTTL        time.Duration `env:"MEMORY_CACHE_TTL" envDefault:"10s"`
+	CacheLookupTimeout    time.Duration `env:"CACHE_LOOKUP_TIMEOUT" envDefault:"0"`
 }
 
 // Get the current Config.
@@ -0,0 +1,81 @@
+package controller
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"strconv"
+
+	chart "github.com/wcharczuk/go-chart"
+)
+
+// chartTitle is the title of the chart of the given repository or
+// organization, if any.
+func chartTitle(name string) string {
+	if name == "" {
+		return "Star history"
+	}
+	return "Star history for " + name
+}
+
+// describeChart summarizes the star line of the graph for screen readers,
+// e.g. "Star history for owner/repo: 12,345 stars from Jan 2020 to Mar 2024".
+func describeChart(graph chart.Chart, name string) string {
+	title := chartTitle(name)
+	if len(graph.Series) == 0 {
+		return title + ": no stars yet"
+	}
+	line, ok := graph.Series[0].(chart.TimeSeries)
+	if !ok || len(line.XValues) == 0 {
+		return title + ": no stars yet"
+	}
+	stars := int(line.YValues[len(line.YValues)-1])
+	unit := "stars"
+	if stars == 1 {
+		unit = "star"
+	}
+	from := line.XValues[0].Format("Jan 2006")
+	to := line.XValues[len(line.XValues)-1].Format("Jan 2006")
+	if from == to {
+		return fmt.Sprintf("%s: %s %s in %s", title, thousands(stars), unit, from)
+	}
+	return fmt.Sprintf("%s: %s %s from %s to %s", title, thousands(stars), unit, from, to)
+}
+
+// thousands formats n with comma separated thousands, e.g. 12,345.
+func thousands(n int) string {
+	s := strconv.Itoa(n)
+	sign := ""
+	if n < 0 {
+		sign, s = "-", s[1:]
+	}
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return sign + s
+}
+
+// addAccessibility marks the given SVG document as an image labeled with the
+// given description, and adds title and desc elements to it, so screen
+// readers can tell what the chart shows. The title and description are
+// escaped.
+func addAccessibility(svg []byte, title, desc string) ([]byte, error) {
+	start := bytes.Index(svg, []byte("<svg"))
+	if start < 0 {
+		return nil, errNotSVG
+	}
+	end := bytes.IndexByte(svg[start:], '>')
+	if end < 0 {
+		return nil, errNotSVG
+	}
+	end += start
+
+	title, desc = html.EscapeString(title), html.EscapeString(desc)
+	var buf bytes.Buffer
+	buf.Grow(len(svg) + len(title) + 2*len(desc) + 64)
+	buf.Write(svg[:end])
+	fmt.Fprintf(&buf, ` role="img" aria-label="%s">`, desc)
+	fmt.Fprintf(&buf, `<title>%s</title><desc>%s</desc>`, title, desc)
+	buf.Write(svg[end+1:])
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,86 @@
+package controller
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/caarlos0/starcharts/internal/github"
+	"github.com/matryer/is"
+)
+
+func TestThousands(t *testing.T) {
+	for n, expected := range map[int]string{
+		0:        "0",
+		12:       "12",
+		999:      "999",
+		1000:     "1,000",
+		12345:    "12,345",
+		1234567:  "1,234,567",
+		-1234567: "-1,234,567",
+	} {
+		t.Run(expected, func(t *testing.T) {
+			is := is.New(t)
+			is.Equal(expected, thousands(n))
+		})
+	}
+}
+
+func TestDescribeChart(t *testing.T) {
+	date := func(year, month int) time.Time {
+		return time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+	}
+	for expected, points := range map[string][]Point{
+		"Star history for owner/repo: 12,345 stars from Jan 2020 to Mar 2024": {
+			{Date: date(2020, 1), Stars: 1},
+			{Date: date(2024, 3), Stars: 12345},
+		},
+		"Star history for owner/repo: 1 star in Jan 2020": {
+			{Date: date(2020, 1), Stars: 1},
+			{Date: date(2020, 1).Add(time.Hour), Stars: 1},
+		},
+	} {
+		t.Run(expected, func(t *testing.T) {
+			is := is.New(t)
+			graph := buildGraph(log.Log, points, 0, lineColor)
+			is.Equal(expected, describeChart(graph, "owner/repo"))
+		})
+	}
+}
+
+func TestWriteChartAccessibility(t *testing.T) {
+	stargazers := []github.Stargazer{
+		{StarredAt: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{StarredAt: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for name, opts := range map[string]ChartOptions{
+		"plain":       {},
+		"css classes": {CSSClasses: true},
+		"data":        {EmbedData: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			is := is.New(t)
+			opts.Name = `a<b>&"c"`
+			var b bytes.Buffer
+			is.NoErr(WriteChart(&b, stargazers, opts))
+			svg := b.String()
+
+			desc := "Star history for a&lt;b&gt;&amp;&#34;c&#34;: 2 stars from Jan 2020 to Mar 2024"
+			root := svg[:strings.IndexByte(svg, '>')+1]
+			is.True(strings.HasPrefix(root, "<svg "))
+			is.True(strings.Contains(root, ` role="img"`))
+			is.True(strings.Contains(root, ` aria-label="`+desc+`"`))
+			// the title should be the first child, so it is the name read out.
+			is.True(strings.HasPrefix(svg[len(root):], `<title>Star history for a&lt;b&gt;&amp;&#34;c&#34;</title><desc>`+desc+`</desc>`))
+			is.True(!strings.Contains(svg, "a<b>")) // should be escaped
+		})
+	}
+}
+
+func TestAddAccessibilityNotSVG(t *testing.T) {
+	is := is.New(t)
+	_, err := addAccessibility([]byte("<html></html>"), "title", "desc")
+	is.Equal(errNotSVG, err)
+}
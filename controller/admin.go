@@ -0,0 +1,91 @@
+package controller
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/caarlos0/httperr"
+	"github.com/caarlos0/starcharts/internal/github"
+)
+
+// Admin guards the given handler with the admin secret, which must be sent
+// as a bearer token.
+// If no secret is configured, admin endpoints are disabled.
+func Admin(secret string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if secret == "" {
+			http.NotFound(w, r)
+			return
+		}
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(secret)) != 1 {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// tokenHintHeader forces the github token to use for a single request.
+const tokenHintHeader = "X-Starcharts-Token-Hint"
+
+// TokenHint makes requests with the token hint header use the github token
+// ending with it, which helps diagnosing a misbehaving token.
+// The header requires the admin secret, and is rejected if none is
+// configured.
+// Responses served from the cache don't talk to github at all.
+func TokenHint(secret string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hint := r.Header.Get(tokenHintHeader)
+		if hint == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if secret == "" {
+			http.Error(w, "token hints are disabled", http.StatusForbidden)
+			return
+		}
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(secret)) != 1 {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(github.WithTokenHint(r.Context(), hint)))
+	})
+}
+
+// ListCachedRepos lists the repositories in the cache.
+func ListCachedRepos(gh *github.GitHub) http.Handler {
+	return httperr.NewF(func(w http.ResponseWriter, r *http.Request) error {
+		repos, err := gh.CachedRepos()
+		if err != nil {
+			return err
+		}
+		w.Header().Add("content-type", "application/json")
+		return json.NewEncoder(w).Encode(repos)
+	})
+}
+
+// WarmupRepo fetches the given repository in the background, so it is cached
+// for the next requests.
+// Repeated calls for the same repository are coalesced, so it is safe to call
+// from cron or CI.
+func WarmupRepo(gh *github.GitHub) http.Handler {
+	return httperr.NewF(func(w http.ResponseWriter, r *http.Request) error {
+		name, err := repoName(r)
+		if err != nil {
+			return err
+		}
+		status := gh.Warmup(name)
+		w.Header().Add("content-type", "application/json")
+		if status == github.WarmupStarted {
+			w.WriteHeader(http.StatusAccepted)
+		}
+		return json.NewEncoder(w).Encode(map[string]interface{}{
+			"repo":   name,
+			"status": status,
+		})
+	})
+}
@@ -0,0 +1,42 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestTokenHint(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	for name, tt := range map[string]struct {
+		secret string
+		hint   string
+		auth   string
+		status int
+	}{
+		"no hint":      {secret: "s3cr3t", status: http.StatusNoContent},
+		"disabled":     {hint: "abc", auth: "Bearer ", status: http.StatusForbidden},
+		"wrong secret": {secret: "s3cr3t", hint: "abc", auth: "Bearer nope", status: http.StatusUnauthorized},
+		"no secret":    {secret: "s3cr3t", hint: "abc", status: http.StatusUnauthorized},
+		"authorized":   {secret: "s3cr3t", hint: "abc", auth: "Bearer s3cr3t", status: http.StatusNoContent},
+	} {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			is := is.New(t)
+			r := httptest.NewRequest(http.MethodGet, "/test/test.svg", nil)
+			if tt.hint != "" {
+				r.Header.Set(tokenHintHeader, tt.hint)
+			}
+			if tt.auth != "" {
+				r.Header.Set("Authorization", tt.auth)
+			}
+			w := httptest.NewRecorder()
+			TokenHint(tt.secret, next).ServeHTTP(w, r)
+			is.Equal(tt.status, w.Code)
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/caarlos0/httperr"
+	"github.com/caarlos0/starcharts/internal/github"
+)
+
+// GetStarsAt returns how many stars the given repository had at the end of
+// the day given in the date query parameter, e.g. ?date=2022-01-01.
+func GetStarsAt(gh *github.GitHub) http.Handler {
+	return httperr.NewF(func(w http.ResponseWriter, r *http.Request) error {
+		name, err := repoName(r)
+		if err != nil {
+			return err
+		}
+		date, err := time.Parse("2006-01-02", r.URL.Query().Get("date"))
+		if err != nil {
+			return httperr.Errorf(http.StatusBadRequest, "invalid date, expected YYYY-MM-DD: %q", r.URL.Query().Get("date"))
+		}
+
+		log := log.WithField("repo", name)
+		defer log.Trace("collect_stars").Stop(nil)
+		repo, err := gh.RepoDetails(r.Context(), name)
+		if err != nil {
+			return fetchErr(w, err, http.StatusBadRequest)
+		}
+		stargazers, err := gh.Stargazers(r.Context(), repo)
+		if err != nil {
+			log.WithError(err).Error("failed to get stars")
+			return fetchErr(w, err, http.StatusInternalServerError)
+		}
+
+		w.Header().Add("content-type", "application/json")
+		w.Header().Add("cache-control", "public, max-age=86400")
+		return json.NewEncoder(w).Encode(Point{
+			Date:  date,
+			Stars: starsBefore(stargazers, date.AddDate(0, 0, 1)),
+		})
+	})
+}
+
+// starsBefore counts the given sorted stargazers that starred before t.
+func starsBefore(stargazers []github.Stargazer, t time.Time) int {
+	return sort.Search(len(stargazers), func(i int) bool {
+		return !stargazers[i].StarredAt.Before(t)
+	})
+}
@@ -0,0 +1,46 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/caarlos0/starcharts/internal/github"
+	"github.com/matryer/is"
+)
+
+func TestStarsBefore(t *testing.T) {
+	day := func(d int) time.Time {
+		return time.Date(2022, 1, d, 0, 0, 0, 0, time.UTC)
+	}
+	stargazers := []github.Stargazer{
+		{StarredAt: day(2)},
+		{StarredAt: day(2).Add(time.Hour)},
+		{StarredAt: day(4)},
+		{StarredAt: day(4)},
+		{StarredAt: day(6).Add(-time.Nanosecond)},
+	}
+
+	for name, tt := range map[string]struct {
+		t        time.Time
+		expected int
+	}{
+		"before the first star":  {day(1), 0},
+		"at the first star":      {day(2), 0},
+		"just after first star":  {day(2).Add(time.Nanosecond), 1},
+		"between stars":          {day(3), 2},
+		"same time stars":        {day(4).Add(time.Nanosecond), 4},
+		"just before last star":  {day(6).Add(-time.Nanosecond), 4},
+		"at the end of last day": {day(6), 5},
+		"after the last star":    {day(30), 5},
+	} {
+		t.Run(name, func(t *testing.T) {
+			is := is.New(t)
+			is.Equal(tt.expected, starsBefore(stargazers, tt.t))
+		})
+	}
+
+	t.Run("no stars", func(t *testing.T) {
+		is := is.New(t)
+		is.Equal(0, starsBefore(nil, day(1)))
+	})
+}
@@ -0,0 +1,127 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/caarlos0/httperr"
+	"github.com/caarlos0/starcharts/internal/cache"
+	"github.com/caarlos0/starcharts/internal/github"
+	"golang.org/x/sync/singleflight"
+)
+
+// GetBadge returns a SVG badge with the star count of the given repository.
+//
+// Badges are requested a lot, so they only ever use cached data: if the
+// repository was never fetched, a "no data yet" badge is returned and the
+// repository is fetched in the background.
+// Rendered badges are cached for the given ttl, and concurrent requests for
+// the same badge share a single render.
+func GetBadge(gh *github.GitHub, cache cache.Cache, ttl time.Duration) http.Handler {
+	var group singleflight.Group
+	return httperr.NewF(func(w http.ResponseWriter, r *http.Request) error {
+		name, err := repoName(r)
+		if err != nil {
+			return err
+		}
+
+		result, err, _ := group.Do(name, func() (interface{}, error) {
+			return renderBadge(gh, cache, name, ttl)
+		})
+		if err != nil {
+			log.WithError(err).WithField("repo", name).Error("failed to render badge")
+			return fetchErr(w, err, http.StatusInternalServerError)
+		}
+		b := result.(badge)
+
+		w.Header().Add("content-type", "image/svg+xml;charset=utf-8")
+		if b.ready {
+			w.Header().Add("cache-control", fmt.Sprintf("public, max-age=%.0f", ttl.Seconds()))
+		} else {
+			w.Header().Add("cache-control", "no-cache")
+		}
+		if r.Method == http.MethodHead {
+			return nil
+		}
+		_, err = w.Write([]byte(b.svg))
+		return err
+	})
+}
+
+// badge is a rendered badge.
+type badge struct {
+	svg string
+	// ready is false if there is no data for the badge yet.
+	ready bool
+}
+
+func badgeKey(name string) string {
+	return name + "_badge"
+}
+
+// renderBadge renders the badge of the given repository from the cached
+// details, caching the result.
+func renderBadge(gh *github.GitHub, c cache.Cache, name string, ttl time.Duration) (badge, error) {
+	var svg string
+	if err := c.Get(badgeKey(name), &svg); err == nil {
+		return badge{svg: svg, ready: true}, nil
+	}
+
+	repo, err := gh.CachedRepoDetails(name)
+	if errors.Is(err, cache.ErrNotFound) {
+		gh.FetchInBackground(name)
+		return badge{svg: badgeSVG("stars", "no data yet")}, nil
+	}
+	if err != nil {
+		return badge{}, err
+	}
+
+	svg = badgeSVG("stars", formatCount(repo.StargazersCount))
+	if ttl > 0 {
+		if err := c.PutWithTTL(badgeKey(name), svg, ttl); err != nil {
+			log.WithError(err).WithField("repo", name).Warn("failed to cache badge")
+		}
+	}
+	return badge{svg: svg, ready: true}, nil
+}
+
+// formatCount formats a star count the way github does, e.g. 1.2k.
+func formatCount(n int) string {
+	switch {
+	case n >= 1000000:
+		return fmt.Sprintf("%.1fm", float64(n)/1000000)
+	case n >= 1000:
+		return fmt.Sprintf("%.1fk", float64(n)/1000)
+	default:
+		return fmt.Sprintf("%d", n)
+	}
+}
+
+// badgeSVG renders a flat badge with the given label and value.
+//
+// Text widths are estimated, as measuring them would need the fonts.
+func badgeSVG(label, value string) string {
+	labelWidth := 7*len(label) + 10
+	valueWidth := 7*len(value) + 10
+	width := labelWidth + valueWidth
+	return fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%[1]d" height="20" role="img" aria-label="%[2]s: %[3]s">
+	<rect width="%[4]d" height="20" fill="#555"/>
+	<rect x="%[4]d" width="%[5]d" height="20" fill="#81c7ef"/>
+	<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,sans-serif" font-size="11">
+		<text x="%[6]d" y="14">%[2]s</text>
+		<text x="%[7]d" y="14">%[3]s</text>
+	</g>
+</svg>`,
+		width,
+		html.EscapeString(label),
+		html.EscapeString(value),
+		labelWidth,
+		valueWidth,
+		labelWidth/2,
+		labelWidth+valueWidth/2,
+	)
+}
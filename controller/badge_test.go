@@ -0,0 +1,86 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis"
+	"github.com/caarlos0/starcharts/internal/cache"
+	"github.com/caarlos0/starcharts/internal/github"
+	"github.com/go-redis/redis"
+	"github.com/gorilla/mux"
+	"github.com/matryer/is"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestBadge(t *testing.T) {
+	defer gock.Off()
+
+	mr, _ := miniredis.Run()
+	rc := redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	})
+	cache := cache.New(rc)
+	defer cache.Close()
+	gh := github.New(testConfig(), cache)
+	handler := GetBadge(gh, cache, time.Minute)
+
+	request := func(repo string) *httptest.ResponseRecorder {
+		r := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/test/"+repo+"/badge.svg", nil), map[string]string{
+			"owner": "test",
+			"repo":  repo,
+		})
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	t.Run("cached", func(t *testing.T) {
+		is := is.New(t)
+		is.NoErr(cache.Put("test/cached", github.Repository{
+			FullName:        "test/cached",
+			StargazersCount: 1234,
+		}))
+		w := request("cached")
+		is.Equal(http.StatusOK, w.Code)
+		is.True(strings.Contains(w.Body.String(), ">1.2k<")) // should show the count
+		is.Equal("public, max-age=60", w.Header().Get("cache-control"))
+		is.True(mr.Exists("test/cached_badge")) // should cache the rendered badge
+	})
+
+	t.Run("not cached", func(t *testing.T) {
+		is := is.New(t)
+		gock.New("https://api.github.com").
+			Get("/rate_limit").
+			Persist().
+			Reply(200).
+			JSON(map[string]interface{}{"rate": map[string]int{"limit": 5000, "remaining": 4000}})
+		gock.New("https://api.github.com").
+			Get("/repos/test/missing").
+			Reply(200).
+			JSON(github.Repository{FullName: "test/missing", StargazersCount: 10})
+
+		w := request("missing")
+		is.Equal(http.StatusOK, w.Code)
+		is.True(strings.Contains(w.Body.String(), "no data yet")) // should not wait for github
+		is.Equal("no-cache", w.Header().Get("cache-control"))
+
+		// the details are fetched in the background.
+		for i := 0; i < 100 && !mr.Exists("test/missing"); i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		w = request("missing")
+		is.True(strings.Contains(w.Body.String(), ">10<")) // should show the fetched count
+	})
+}
+
+func TestFormatCount(t *testing.T) {
+	is := is.New(t)
+	is.Equal("999", formatCount(999))
+	is.Equal("1.0k", formatCount(1000))
+	is.Equal("12.3k", formatCount(12345))
+	is.Equal("1.5m", formatCount(1500000))
+}
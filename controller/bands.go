@@ -0,0 +1,139 @@
+package controller
+
+import (
+	"html"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/caarlos0/httperr"
+	chart "github.com/wcharczuk/go-chart"
+	"github.com/wcharczuk/go-chart/drawing"
+	"github.com/wcharczuk/go-chart/util"
+)
+
+// maxBands bounds how many band query parameters are drawn.
+const maxBands = 10
+
+// bandLabelHeight is how far apart the labels of bands are stacked, so the
+// labels of overlapping bands don't overlap too.
+const bandLabelHeight = 12
+
+// nolint: gochecknoglobals
+var bandColor = drawing.Color{R: 128, G: 128, B: 128, A: 40}
+
+// Band is a shaded date range drawn behind the data, e.g. a conference
+// season.
+type Band struct {
+	From, To time.Time
+	// Label is drawn at the top of the band, if not empty.
+	Label string
+}
+
+// parseBands parses the band query parameters, e.g.
+// ?band=2023-01-01/2023-02-01:conferences, the label being optional.
+func parseBands(r *http.Request) ([]Band, error) {
+	values := r.URL.Query()["band"]
+	if len(values) > maxBands {
+		return nil, httperr.Errorf(http.StatusBadRequest, "too many bands, at most %d are allowed", maxBands)
+	}
+	var bands []Band
+	for _, value := range values {
+		dates, label, _ := strings.Cut(value, ":")
+		from, to, ok := strings.Cut(dates, "/")
+		if !ok {
+			return nil, httperr.Errorf(http.StatusBadRequest, "invalid band, expected YYYY-MM-DD/YYYY-MM-DD[:label]: %q", value)
+		}
+		band := Band{Label: label}
+		var err error
+		if band.From, err = time.Parse("2006-01-02", from); err != nil {
+			return nil, httperr.Errorf(http.StatusBadRequest, "invalid band start, expected YYYY-MM-DD: %q", from)
+		}
+		if band.To, err = time.Parse("2006-01-02", to); err != nil {
+			return nil, httperr.Errorf(http.StatusBadRequest, "invalid band end, expected YYYY-MM-DD: %q", to)
+		}
+		if !band.From.Before(band.To) {
+			return nil, httperr.Errorf(http.StatusBadRequest, "band must start before it ends: %q", value)
+		}
+		bands = append(bands, band)
+	}
+	return bands, nil
+}
+
+// addBands draws the given bands behind everything else on the graph.
+// Labels are escaped for SVG charts, unless raster is set.
+func addBands(graph *chart.Chart, bands []Band, raster bool) {
+	var series []chart.Series
+	for i, band := range bands {
+		label := band.Label
+		if !raster {
+			label = html.EscapeString(label)
+		}
+		series = append(series, bandSeries{
+			from:  util.Time.ToFloat64(band.From),
+			to:    util.Time.ToFloat64(band.To),
+			label: label,
+			row:   i,
+		})
+	}
+	graph.Series = append(series, graph.Series...)
+}
+
+// bandSeries shades a range of the x axis across the whole plot height.
+//
+// It has no values, so it doesn't change the axes ranges, and is clipped to
+// them instead.
+type bandSeries struct {
+	from, to float64
+	label    string
+	// row is where the label is stacked, from the top of the plot.
+	row int
+}
+
+func (b bandSeries) GetName() string           { return b.label }
+func (b bandSeries) GetYAxis() chart.YAxisType { return chart.YAxisPrimary }
+func (b bandSeries) Validate() error           { return nil }
+
+func (b bandSeries) GetStyle() chart.Style {
+	return chart.Style{
+		Show:        true,
+		StrokeWidth: 0,
+		StrokeColor: bandColor.WithAlpha(0),
+		FillColor:   bandColor,
+	}
+}
+
+func (b bandSeries) Render(r chart.Renderer, canvasBox chart.Box, xrange, yrange chart.Range, defaults chart.Style) {
+	from, to := b.from, b.to
+	if min := xrange.GetMin(); from < min {
+		from = min
+	}
+	if max := xrange.GetMax(); to > max {
+		to = max
+	}
+	if from >= to {
+		// out of the visible range.
+		return
+	}
+	left := canvasBox.Left + xrange.Translate(from)
+	right := canvasBox.Left + xrange.Translate(to)
+	if left > right {
+		// the x axis is reversed.
+		left, right = right, left
+	}
+	chart.Draw.Box(r, chart.Box{
+		Top:    canvasBox.Top,
+		Left:   left,
+		Right:  right,
+		Bottom: canvasBox.Bottom,
+	}, b.GetStyle().InheritFrom(defaults))
+
+	if b.label == "" {
+		return
+	}
+	style := chart.Style{
+		FontSize:  8,
+		FontColor: drawing.Color{R: 85, G: 85, B: 85, A: 255},
+	}.InheritFrom(defaults)
+	chart.Draw.Text(r, b.label, left+4, canvasBox.Top+bandLabelHeight*(b.row+1), style)
+}
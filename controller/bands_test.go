@@ -0,0 +1,105 @@
+package controller
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/caarlos0/starcharts/internal/github"
+	"github.com/matryer/is"
+)
+
+func TestParseBands(t *testing.T) {
+	date := func(month int) time.Time {
+		return time.Date(2023, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+	}
+	t.Run("valid", func(t *testing.T) {
+		is := is.New(t)
+		r := httptest.NewRequest("GET", "/?band=2023-01-01/2023-02-01:conferences&band=2023-03-01/2023-04-01", nil)
+		bands, err := parseBands(r)
+		is.NoErr(err)
+		is.Equal([]Band{
+			{From: date(1), To: date(2), Label: "conferences"},
+			{From: date(3), To: date(4)},
+		}, bands)
+	})
+	for name, value := range map[string]string{
+		"no range":  "2023-01-01",
+		"bad start": "2023-13-01/2023-02-01",
+		"bad end":   "2023-01-01/tomorrow:x",
+		"reversed":  "2023-02-01/2023-01-01",
+		"empty":     "2023-01-01/2023-01-01",
+	} {
+		t.Run(name, func(t *testing.T) {
+			is := is.New(t)
+			r := httptest.NewRequest("GET", "/?band="+value, nil)
+			_, err := parseBands(r)
+			is.True(err != nil)
+		})
+	}
+	t.Run("too many", func(t *testing.T) {
+		is := is.New(t)
+		query := strings.Repeat("&band=2023-01-01/2023-02-01", maxBands+1)
+		_, err := parseBands(httptest.NewRequest("GET", "/?"+query[1:], nil))
+		is.True(err != nil)
+	})
+}
+
+func TestWriteChartBands(t *testing.T) {
+	date := func(month int) time.Time {
+		return time.Date(2023, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+	}
+	stargazers := []github.Stargazer{
+		{StarredAt: date(1)},
+		{StarredAt: date(6)},
+	}
+	render := func(t *testing.T, opts ChartOptions) string {
+		t.Helper()
+		is := is.New(t)
+		var b bytes.Buffer
+		is.NoErr(WriteChart(&b, stargazers, opts))
+		return b.String()
+	}
+	fill := "fill:" + bandColor.String()
+
+	t.Run("overlapping", func(t *testing.T) {
+		is := is.New(t)
+		svg := render(t, ChartOptions{Bands: []Band{
+			{From: date(2), To: date(4), Label: "launch"},
+			{From: date(3), To: date(5), Label: "conf<s>"},
+		}})
+		is.Equal(2, strings.Count(svg, fill))
+		is.True(strings.Contains(svg, ">launch</text>"))
+		is.True(strings.Contains(svg, ">conf&lt;s&gt;</text>"))
+		// the labels are stacked, not drawn over each other.
+		is.True(strings.Contains(svg, `y="23"`))
+		is.True(strings.Contains(svg, `y="35"`))
+		// drawn behind the star line.
+		is.True(strings.LastIndex(svg, fill) < strings.Index(svg, "stroke:"+lineColor.String()))
+	})
+
+	t.Run("out of range", func(t *testing.T) {
+		is := is.New(t)
+		svg := render(t, ChartOptions{Bands: []Band{
+			{From: date(8), To: date(9), Label: "after"},
+			{From: date(1).AddDate(-1, 0, 0), To: date(1).AddDate(0, 0, -1), Label: "before"},
+		}})
+		is.True(!strings.Contains(svg, fill))
+		is.True(!strings.Contains(svg, "after"))
+		is.True(!strings.Contains(svg, "before"))
+	})
+
+	t.Run("clipped", func(t *testing.T) {
+		is := is.New(t)
+		full := render(t, ChartOptions{Bands: []Band{{From: date(5), To: date(9)}}})
+		is.Equal(1, strings.Count(full, fill))
+		// axis_max moves the end of the visible range before the band.
+		svg := render(t, ChartOptions{
+			AxisMax: date(4),
+			Bands:   []Band{{From: date(5), To: date(9), Label: "gone"}},
+		})
+		is.True(!strings.Contains(svg, fill))
+	})
+}
@@ -0,0 +1,149 @@
+package controller
+
+import (
+	"math"
+	"time"
+
+	chart "github.com/wcharczuk/go-chart"
+	"github.com/wcharczuk/go-chart/drawing"
+)
+
+const (
+	// barPeriod is the time span each bar counts the new stars of, unless
+	// the per query parameter asks for another one.
+	barPeriod = 24 * time.Hour
+	// barGap is the fraction of the period left empty on each side of a bar.
+	barGap = 0.1
+	// barAlpha is the opacity of the bars, so they stay behind the line.
+	barAlpha = 64
+	// barAxisPadding is the left padding of charts with bars.
+	barAxisPadding = 40
+)
+
+// dailyBars tells whether the bars query parameter asks for per day bars.
+func dailyBars(value string) bool {
+	return value == "daily"
+}
+
+// weekendRule is what daily bars do with the stars of weekends.
+type weekendRule int
+
+const (
+	// keepWeekends draws weekends like any other day.
+	keepWeekends weekendRule = iota
+	// carryWeekends adds the stars of weekends to the following monday.
+	carryWeekends
+	// dropWeekends ignores the stars of weekends.
+	dropWeekends
+)
+
+// dayBucket returns the day the stars of the given time are counted in, or
+// false if they aren't counted at all.
+func dayBucket(t time.Time, weekends weekendRule) (time.Time, bool) {
+	day := t.UTC().Truncate(barPeriod)
+	if weekends == keepWeekends {
+		return day, true
+	}
+	var untilMonday int
+	switch day.Weekday() {
+	case time.Saturday:
+		untilMonday = 2
+	case time.Sunday:
+		untilMonday = 1
+	default:
+		return day, true
+	}
+	if weekends == dropWeekends {
+		return time.Time{}, false
+	}
+	return day.AddDate(0, 0, untilMonday), true
+}
+
+// periodBucket returns the start of the bucket of the given period the
+// stars of the given time are counted in, or false if they aren't counted at
+// all. The stars of weekends carried to monday are counted in its first
+// bucket.
+func periodBucket(t time.Time, period time.Duration, weekends weekendRule) (time.Time, bool) {
+	day, ok := dayBucket(t, weekends)
+	if !ok || period >= barPeriod || !day.Equal(t.UTC().Truncate(barPeriod)) {
+		return day, ok
+	}
+	return t.UTC().Truncate(period), true
+}
+
+// barCounts returns each bucket of the given period with new stars along
+// with how many there were, from the given cumulative points starting at
+// baseline, handling the stars of weekends by the given rule.
+func barCounts(points []Point, baseline int, period time.Duration, weekends weekendRule) ([]time.Time, []float64) {
+	var starts []time.Time
+	var counts []float64
+	prev := baseline
+	for _, p := range points {
+		added := p.Stars - prev
+		prev = p.Stars
+		start, ok := periodBucket(p.Date, period, weekends)
+		if !ok {
+			continue
+		}
+		if len(starts) == 0 || !starts[len(starts)-1].Equal(start) {
+			starts = append(starts, start)
+			counts = append(counts, 0)
+		}
+		counts[len(counts)-1] += float64(added)
+	}
+	return starts, counts
+}
+
+// addDailyBars draws the new stars of each period, e.g. a day, as faint bars
+// behind the star line, labeled on their own axis on the right side of the
+// plot.
+//
+// go-chart ranges the secondary y axis by the ticks of the primary one, so
+// both axes can't have their own scale. Instead, the bars are scaled into the
+// range of the star ticks, which move to the secondary axis on the left, and
+// the primary axis gets ticks labeled with the bar counts.
+func addDailyBars(graph *chart.Chart, points []Point, baseline, yticks int, period time.Duration, weekends weekendRule, color drawing.Color) {
+	starts, counts := barCounts(points, baseline, period, weekends)
+	if len(starts) == 0 || len(graph.YAxis.Ticks) < 2 {
+		return
+	}
+	var max float64
+	for _, count := range counts {
+		max = math.Max(max, count)
+	}
+	if yticks == 0 {
+		yticks = defaultYTicks
+	}
+	ceiling, step := niceCeiling(max, yticks)
+	lo := graph.YAxis.Ticks[0].Value
+	hi := graph.YAxis.Ticks[len(graph.YAxis.Ticks)-1].Value
+	scale := (hi - lo) / ceiling
+
+	bars := chart.TimeSeries{
+		Style: chart.Style{
+			Show:        true,
+			StrokeColor: transparentStyle.StrokeColor,
+			FillColor:   color.WithAlpha(barAlpha),
+		},
+	}
+	gap := time.Duration(float64(period) * barGap)
+	for i, start := range starts {
+		height := lo + counts[i]*scale
+		bars.XValues = append(bars.XValues,
+			start.Add(gap), start.Add(gap), start.Add(period-gap), start.Add(period-gap))
+		bars.YValues = append(bars.YValues, lo, height, height, lo)
+	}
+	graph.Series = append([]chart.Series{bars}, graph.Series...)
+
+	graph.YAxisSecondary = graph.YAxis
+	// leave room for the name of the axis on the left side.
+	graph.Background.Padding.Left = barAxisPadding
+	graph.YAxis.Name = "New stars per " + periodName(period)
+	graph.YAxis.Ticks = nil
+	for value := 0.0; value <= ceiling+step/2; value += step {
+		graph.YAxis.Ticks = append(graph.YAxis.Ticks, chart.Tick{
+			Value: lo + value*scale,
+			Label: IntValueFormatter(value),
+		})
+	}
+}
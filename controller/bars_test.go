@@ -0,0 +1,107 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/matryer/is"
+	chart "github.com/wcharczuk/go-chart"
+)
+
+func TestDailyCounts(t *testing.T) {
+	is := is.New(t)
+	day := time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC)
+	points := []Point{
+		{Date: day.Add(time.Hour), Stars: 11},
+		{Date: day.Add(2 * time.Hour), Stars: 12},
+		{Date: day.Add(50 * time.Hour), Stars: 13},
+		{Date: day.Add(51 * time.Hour), Stars: 15},
+	}
+	days, counts := barCounts(points, 10, barPeriod, keepWeekends)
+	is.Equal(days, []time.Time{day, day.Add(48 * time.Hour)})
+	is.Equal(counts, []float64{2, 3})
+
+	days, counts = barCounts(nil, 10, barPeriod, keepWeekends)
+	is.Equal(len(days), 0)
+	is.Equal(len(counts), 0)
+}
+
+func TestDailyCounts_Weekends(t *testing.T) {
+	friday := time.Date(2022, 3, 4, 0, 0, 0, 0, time.UTC)
+	saturday := friday.AddDate(0, 0, 1)
+	sunday := friday.AddDate(0, 0, 2)
+	monday := friday.AddDate(0, 0, 3)
+	points := []Point{
+		{Date: friday.Add(23 * time.Hour), Stars: 1},
+		{Date: saturday, Stars: 2},
+		{Date: saturday.Add(12 * time.Hour), Stars: 3},
+		{Date: sunday.Add(23*time.Hour + 59*time.Minute), Stars: 4},
+		{Date: monday, Stars: 5},
+		{Date: monday.AddDate(0, 0, 5), Stars: 7}, // next saturday
+	}
+
+	for name, tt := range map[string]struct {
+		rule   weekendRule
+		days   []time.Time
+		counts []float64
+	}{
+		"keep": {
+			rule:   keepWeekends,
+			days:   []time.Time{friday, saturday, sunday, monday, monday.AddDate(0, 0, 5)},
+			counts: []float64{1, 2, 1, 1, 2},
+		},
+		"carry to monday": {
+			rule:   carryWeekends,
+			days:   []time.Time{friday, monday, monday.AddDate(0, 0, 7)},
+			counts: []float64{1, 4, 2},
+		},
+		"drop": {
+			rule:   dropWeekends,
+			days:   []time.Time{friday, monday},
+			counts: []float64{1, 1},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			is := is.New(t)
+			days, counts := barCounts(points, 0, barPeriod, tt.rule)
+			is.Equal(days, tt.days)
+			is.Equal(counts, tt.counts)
+		})
+	}
+
+	t.Run("local time", func(t *testing.T) {
+		is := is.New(t)
+		// friday evening in são paulo is already saturday in UTC.
+		local := time.FixedZone("BRT", -3*60*60)
+		day, ok := dayBucket(time.Date(2022, 3, 4, 22, 0, 0, 0, local), carryWeekends)
+		is.True(ok)
+		is.Equal(day, monday)
+	})
+}
+
+func TestAddDailyBars(t *testing.T) {
+	is := is.New(t)
+	day := time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC)
+	points := []Point{
+		{Date: day, Stars: 1},
+		{Date: day.Add(time.Hour), Stars: 2},
+		{Date: day.Add(24 * time.Hour), Stars: 3},
+	}
+	graph := buildGraph(log.Log, points, 0, lineColor)
+	applyTicks(&graph, 0, 0)
+	starTicks := graph.YAxis.Ticks
+	addDailyBars(&graph, points, 0, 0, barPeriod, keepWeekends, lineColor)
+
+	is.Equal(len(graph.Series), 2)
+	bars, ok := graph.Series[0].(chart.TimeSeries)
+	is.True(ok) // bars should be drawn first, behind the line
+	is.Equal(len(bars.XValues), 8)
+
+	is.Equal(graph.YAxisSecondary.Ticks, starTicks) // stars should move to the secondary axis
+	is.Equal(graph.YAxis.Name, "New stars per day")
+	top := graph.YAxis.Ticks[len(graph.YAxis.Ticks)-1]
+	is.Equal(top.Label, "2")                                     // should be labeled with the bar counts
+	is.Equal(top.Value, starTicks[len(starTicks)-1].Value)       // should share the star ticks range
+	is.Equal(bars.YValues[1], starTicks[len(starTicks)-1].Value) // tallest bar should reach the top
+}
@@ -0,0 +1,124 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/apex/log"
+	"github.com/caarlos0/httperr"
+	"github.com/caarlos0/starcharts/internal/github"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// maxBatchRepos bounds how many repositories a single batch can ask for.
+	maxBatchRepos = 50
+	// maxBatchBody bounds the size of the batch request body.
+	maxBatchBody = 64 << 10
+)
+
+type batchRequest struct {
+	Repos []string `json:"repos"`
+}
+
+// batchEntry is the result for a single repository of a batch: either its
+// star count and timeline, or the error fetching it.
+type batchEntry struct {
+	Repo     string  `json:"repo"`
+	Stars    int     `json:"stars,omitempty"`
+	Timeline []Point `json:"timeline,omitempty"`
+	Error    string  `json:"error,omitempty"`
+}
+
+// GetBatchJSON returns the star timelines of the repositories posted as
+// {"repos": ["owner/repo", ...]}, in the same order.
+//
+// A repository that fails gets an error entry instead of failing the whole
+// batch, unless every one of them failed because we are overloaded or github
+// is unavailable. With summary=true, only the star counts are returned.
+func GetBatchJSON(gh *github.GitHub, filter RepoFilter) http.Handler {
+	return httperr.NewF(func(w http.ResponseWriter, r *http.Request) error {
+		var req batchRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchBody)).Decode(&req); err != nil {
+			return httperr.Wrap(fmt.Errorf("invalid batch request: %w", err), http.StatusBadRequest)
+		}
+		if len(req.Repos) == 0 {
+			return httperr.Errorf(http.StatusBadRequest, "no repositories given")
+		}
+		if len(req.Repos) > maxBatchRepos {
+			return httperr.Errorf(http.StatusBadRequest, "too many repositories, the maximum is %d", maxBatchRepos)
+		}
+		summary := r.URL.Query().Get("summary") == "true"
+
+		entries := make([]batchEntry, len(req.Repos))
+		errs := make([]error, len(req.Repos))
+		var g errgroup.Group
+		g.SetLimit(gh.RepoConcurrency())
+		for i, repo := range req.Repos {
+			i, repo := i, repo
+			g.Go(func() error {
+				entries[i], errs[i] = batchRepo(r, gh, filter, repo, summary)
+				return nil
+			})
+		}
+		_ = g.Wait()
+		if unavailable(errs) {
+			return fetchErr(w, errs[0], http.StatusInternalServerError)
+		}
+		for _, err := range errs {
+			setRetryAfter(w, err)
+		}
+
+		w.Header().Add("content-type", "application/json")
+		cw := compress(w, r)
+		defer cw.Close()
+		return json.NewEncoder(cw).Encode(entries)
+	})
+}
+
+// unavailable tells whether every repository of a batch failed to be fetched
+// because we are overloaded or github is unavailable.
+func unavailable(errs []error) bool {
+	for _, err := range errs {
+		if err == nil || errStatus(err, 0) != http.StatusServiceUnavailable {
+			return false
+		}
+	}
+	return true
+}
+
+// batchRepo fetches a single repository of a batch, returning the error of
+// fetching it from github, if any, along with the entry.
+func batchRepo(r *http.Request, gh *github.GitHub, filter RepoFilter, repo string, summary bool) (batchEntry, error) {
+	owner, name, _ := strings.Cut(repo, "/")
+	fullName, err := normalizeRepoName(owner, name)
+	if err != nil {
+		return batchEntry{Repo: repo, Error: err.Error()}, nil
+	}
+	entry := batchEntry{Repo: fullName}
+	if !filter.Allowed(fullName) {
+		entry.Error = "repository not allowed"
+		return entry, nil
+	}
+
+	log := log.WithField("repo", fullName)
+	details, err := gh.RepoDetails(r.Context(), fullName)
+	if err != nil {
+		entry.Error = err.Error()
+		return entry, err
+	}
+	entry.Stars = details.StargazersCount
+	if summary {
+		return entry, nil
+	}
+	stargazers, err := gh.Stargazers(r.Context(), details)
+	if err != nil {
+		log.WithError(err).Error("failed to get stars")
+		entry.Error = explainErr(err).Error()
+		return entry, err
+	}
+	entry.Timeline = timelinePoints(stargazers, 0)
+	return entry, nil
+}
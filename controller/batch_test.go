@@ -0,0 +1,100 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis"
+	"github.com/caarlos0/starcharts/internal/cache"
+	"github.com/caarlos0/starcharts/internal/github"
+	"github.com/go-redis/redis"
+	"github.com/matryer/is"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestBatchJSON(t *testing.T) {
+	defer gock.Off()
+
+	mr, _ := miniredis.Run()
+	rc := redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	})
+	cache := cache.New(rc)
+	defer cache.Close()
+	gh := github.New(testConfig(), cache)
+	handler := GetBatchJSON(gh, NewRepoFilter(nil, []string{"blocked/*"}))
+
+	request := func(query, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/batch.json"+query, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	gock.New("https://api.github.com").
+		Get("/rate_limit").
+		Persist().
+		Reply(200).
+		JSON(map[string]interface{}{"rate": map[string]int{"limit": 5000, "remaining": 4000}})
+	gock.New("https://api.github.com").
+		Get("/repos/test/ok$").
+		Persist().
+		Reply(200).
+		JSON(github.Repository{FullName: "test/ok", StargazersCount: 1, CreatedAt: "2008-02-28T20:40:04Z"})
+	gock.New("https://api.github.com").
+		Get("/repos/test/ok/stargazers").
+		Reply(200).
+		JSON([]github.Stargazer{{StarredAt: time.Now()}})
+	gock.New("https://api.github.com").
+		Get("/repos/test/missing").
+		Persist().
+		Reply(404)
+
+	t.Run("timelines", func(t *testing.T) {
+		is := is.New(t)
+		w := request("", `{"repos": ["test/ok", "test/missing", "blocked/repo", "nope"]}`)
+		is.Equal(http.StatusOK, w.Code)
+
+		var entries []batchEntry
+		is.NoErr(json.NewDecoder(w.Body).Decode(&entries))
+		is.Equal(4, len(entries))
+		is.Equal("test/ok", entries[0].Repo)
+		is.Equal("", entries[0].Error)
+		is.Equal(1, entries[0].Stars)
+		is.Equal(1, len(entries[0].Timeline))
+		is.True(entries[1].Error != "") // should have failed
+		is.Equal("repository not allowed", entries[2].Error)
+		is.True(entries[3].Error != "") // should be an invalid name
+	})
+
+	t.Run("summary", func(t *testing.T) {
+		is := is.New(t)
+		w := request("?summary=true", `{"repos": ["test/ok"]}`)
+		is.Equal(http.StatusOK, w.Code)
+
+		var entries []batchEntry
+		is.NoErr(json.NewDecoder(w.Body).Decode(&entries))
+		is.Equal([]batchEntry{{Repo: "test/ok", Stars: 1}}, entries)
+	})
+
+	t.Run("too many repos", func(t *testing.T) {
+		is := is.New(t)
+		repos := make([]string, maxBatchRepos+1)
+		for i := range repos {
+			repos[i] = fmt.Sprintf("%q", fmt.Sprintf("test/repo%d", i))
+		}
+		w := request("", `{"repos": [`+strings.Join(repos, ",")+`]}`)
+		is.Equal(http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("invalid body", func(t *testing.T) {
+		is := is.New(t)
+		is.Equal(http.StatusBadRequest, request("", `nope`).Code)
+		is.Equal(http.StatusBadRequest, request("", `{"repos": []}`).Code)
+	})
+}
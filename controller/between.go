@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/caarlos0/httperr"
+	"github.com/caarlos0/starcharts/internal/github"
+)
+
+// starsWindow is the response of the between endpoint.
+type starsWindow struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Stars int    `json:"stars"`
+	// Stargazers is only set if asked for, newest first.
+	Stargazers []recentStargazer `json:"stargazers,omitempty"`
+	Truncated  bool              `json:"truncated,omitempty"`
+}
+
+// GetStarsBetween returns how many stars the given repository got from the
+// start of the day in the from query parameter to the end of the day in the
+// to query parameter, e.g. ?from=2023-01-01&to=2023-03-31.
+// With list=true, it also lists up to max of the latest of those stargazers.
+func GetStarsBetween(gh *github.GitHub, max int) http.Handler {
+	return httperr.NewF(func(w http.ResponseWriter, r *http.Request) error {
+		name, err := repoName(r)
+		if err != nil {
+			return err
+		}
+		from, err := time.Parse("2006-01-02", r.URL.Query().Get("from"))
+		if err != nil {
+			return httperr.Errorf(http.StatusBadRequest, "invalid from, expected YYYY-MM-DD: %q", r.URL.Query().Get("from"))
+		}
+		to, err := time.Parse("2006-01-02", r.URL.Query().Get("to"))
+		if err != nil {
+			return httperr.Errorf(http.StatusBadRequest, "invalid to, expected YYYY-MM-DD: %q", r.URL.Query().Get("to"))
+		}
+		if to.Before(from) {
+			return httperr.Errorf(http.StatusBadRequest, "to must not be before from")
+		}
+		list, _ := strconv.ParseBool(r.URL.Query().Get("list"))
+
+		log := log.WithField("repo", name)
+		defer log.Trace("collect_stars").Stop(nil)
+		repo, err := gh.RepoDetails(r.Context(), name)
+		if err != nil {
+			return fetchErr(w, err, http.StatusBadRequest)
+		}
+		stargazers, err := gh.Stargazers(r.Context(), repo)
+		if err != nil {
+			log.WithError(err).Error("failed to get stars")
+			return fetchErr(w, err, http.StatusInternalServerError)
+		}
+
+		window := starsBetween(stargazers, from, to.AddDate(0, 0, 1))
+		result := starsWindow{
+			From:  from.Format("2006-01-02"),
+			To:    to.Format("2006-01-02"),
+			Stars: len(window),
+		}
+		if list {
+			if len(window) > max {
+				window = window[len(window)-max:]
+				result.Truncated = true
+			}
+			result.Stargazers = recentStargazers(window)
+		}
+
+		w.Header().Add("content-type", "application/json")
+		w.Header().Add("cache-control", "public, max-age=86400")
+		return json.NewEncoder(w).Encode(result)
+	})
+}
+
+// starsBetween returns the given sorted stargazers that starred in
+// [from, to).
+func starsBetween(stargazers []github.Stargazer, from, to time.Time) []github.Stargazer {
+	return stargazers[starsBefore(stargazers, from):starsBefore(stargazers, to)]
+}
@@ -0,0 +1,43 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/caarlos0/starcharts/internal/github"
+	"github.com/matryer/is"
+)
+
+func TestStarsBetween(t *testing.T) {
+	day := func(d int) time.Time {
+		return time.Date(2023, 1, d, 0, 0, 0, 0, time.UTC)
+	}
+	stargazers := []github.Stargazer{
+		{StarredAt: day(2)},
+		{StarredAt: day(2).Add(time.Hour)},
+		{StarredAt: day(4)},
+		{StarredAt: day(6).Add(-time.Nanosecond)},
+	}
+
+	for name, tt := range map[string]struct {
+		from, to time.Time
+		expected int
+	}{
+		"before the first star": {day(1), day(2), 0},
+		"after the last star":   {day(6), day(10), 0},
+		"between stars":         {day(3), day(4), 0},
+		"partial":               {day(2).Add(time.Minute), day(5), 2},
+		"single day":            {day(4), day(5), 1},
+		"full":                  {day(1), day(10), 4},
+	} {
+		t.Run(name, func(t *testing.T) {
+			is := is.New(t)
+			is.Equal(tt.expected, len(starsBetween(stargazers, tt.from, tt.to)))
+		})
+	}
+
+	t.Run("no stars", func(t *testing.T) {
+		is := is.New(t)
+		is.Equal(0, len(starsBetween(nil, day(1), day(10))))
+	})
+}
@@ -0,0 +1,34 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/caarlos0/starcharts/internal/github"
+)
+
+// errBusy happens when the stargazers of the repository are already being
+// fetched for the first time by another request.
+var errBusy = errors.New("this chart is being generated, please refresh shortly")
+
+// busyRefresh is how many seconds browsers wait to reload a busy placeholder.
+const busyRefresh = "5"
+
+// busy tells whether the chart of the given repository should be a busy
+// placeholder rather than wait for the stargazers another request is
+// fetching for the first time, see ChartConfig.BusyPlaceholder.
+// Requests with wait=true always wait.
+func busy(r *http.Request, gh *github.GitHub, repo github.Repository, format chartFormat) bool {
+	return format.config.BusyPlaceholder &&
+		r.URL.Query().Get("wait") != "true" &&
+		gh.Fetching(repo.FullName)
+}
+
+// busyErr writes the busy placeholder, which is not to be cached, and which
+// browsers reload on their own.
+func busyErr(w http.ResponseWriter, r *http.Request, format chartFormat) error {
+	w.Header().Set("cache-control", "no-cache")
+	w.Header().Set("refresh", busyRefresh)
+	w.Header().Del("etag")
+	return chartErr(w, r, format, errBusy)
+}
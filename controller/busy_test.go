@@ -0,0 +1,77 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis"
+	"github.com/caarlos0/starcharts/internal/cache"
+	"github.com/caarlos0/starcharts/internal/github"
+	"github.com/go-redis/redis"
+	"github.com/gorilla/mux"
+	"github.com/matryer/is"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestBusyPlaceholder(t *testing.T) {
+	is := is.New(t)
+	defer gock.Off()
+
+	mr, _ := miniredis.Run()
+	rc := redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	})
+	cache := cache.New(rc)
+	defer cache.Close()
+	gh := github.New(testConfig(), cache)
+	is.NoErr(cache.Put("test/test_details", github.Repository{FullName: "test/test", StargazersCount: 1}))
+
+	gock.New("https://api.github.com").
+		Get("/rate_limit").
+		Persist().
+		Reply(200).
+		JSON(map[string]interface{}{"rate": map[string]int{"limit": 5000, "remaining": 4000}})
+	release := make(chan struct{})
+	gock.New("https://api.github.com").
+		Get("/repos/test/test/stargazers").
+		Reply(200).
+		JSON([]github.Stargazer{{StarredAt: time.Now()}}).
+		Map(func(resp *http.Response) *http.Response {
+			<-release
+			return resp
+		})
+
+	handler := GetRepoChart(gh, cache, ChartConfig{BusyPlaceholder: true})
+	request := func() *httptest.ResponseRecorder {
+		r := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/test/test.svg", nil), map[string]string{
+			"owner": "test",
+			"repo":  "test",
+		})
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	first := make(chan *httptest.ResponseRecorder)
+	go func() { first <- request() }()
+	for start := time.Now(); !gh.Fetching("test/test"); time.Sleep(time.Millisecond) {
+		if time.Since(start) > 5*time.Second {
+			t.Fatal("first request never started fetching")
+		}
+	}
+
+	w := request()
+	is.Equal(http.StatusServiceUnavailable, w.Code)
+	is.Equal(busyRefresh, w.Header().Get("refresh"))              // should reload on its own
+	is.Equal("no-cache", w.Header().Get("cache-control"))         // should not be cached
+	is.True(strings.Contains(w.Body.String(), "refresh shortly")) // should be a placeholder
+	is.True(strings.HasPrefix(w.Body.String(), "<svg"))           // should be a placeholder
+
+	close(release)
+	w = <-first
+	is.Equal(http.StatusOK, w.Code) // the first request should get the chart
+	is.True(!gh.Fetching("test/test"))
+}
@@ -0,0 +1,310 @@
+package controller
+
+import (
+	"html"
+	"io"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/caarlos0/starcharts/internal/github"
+	"github.com/golang/freetype/truetype"
+	chart "github.com/wcharczuk/go-chart"
+	"github.com/wcharczuk/go-chart/drawing"
+)
+
+// ChartConfig holds the server wide chart settings.
+type ChartConfig struct {
+	// Watermark is drawn on every chart, if not empty.
+	Watermark string
+	// Streaming builds the charts that don't need every stargazer from a
+	// histogram instead, bounding the memory used by huge repositories.
+	Streaming bool
+	// MaxPoints downsamples lines with more points, if positive.
+	MaxPoints int
+	// Defaults are the chart looks used when the request doesn't set them.
+	Defaults ChartDefaults
+	// StaleTTL is how long the last rendered chart of each repository is
+	// kept, to be served when a fresh one can't be rendered, e.g. during a
+	// github outage. Zero disables it.
+	StaleTTL time.Duration
+	// DropWeekendStars drops the stars of weekends from daily bars that skip
+	// them, instead of adding them to the following monday.
+	DropWeekendStars bool
+	// BusyPlaceholder answers chart requests for repositories whose
+	// stargazers are being fetched for the first time with a placeholder
+	// asking to refresh shortly, instead of fetching them again.
+	BusyPlaceholder bool
+	// RequestBudget bounds how long a chart request spends on the cache and
+	// github, if positive. The github client gives up on slow cache lookups
+	// early, to leave most of it to github.
+	RequestBudget time.Duration
+}
+
+// ChartOptions configures how a star chart is rendered.
+type ChartOptions struct {
+	// Baseline is the star count the chart starts at.
+	Baseline int
+	// Goal draws a goal line at the given star count, if positive.
+	Goal int
+	// ForecastDays projects the star count for the given amount of days, if
+	// positive.
+	ForecastDays int
+	// Raster renders a PNG instead of a SVG.
+	Raster bool
+	// Scale multiplies the PNG resolution.
+	Scale int
+	// EmbedData embeds the star timeline as JSON in the SVG.
+	EmbedData bool
+	// Transparent omits the chart background, so it blends with the page it
+	// is embedded in.
+	Transparent bool
+	// Reverse mirrors the time axis, so the newest stars are on the left.
+	Reverse bool
+	// Watermark is a small attribution text drawn in the bottom right corner,
+	// if not empty.
+	Watermark string
+	// Theme is the name of the chart colors, light by default.
+	Theme string
+	// LineColor is the color of the star line. Zero picks the default.
+	LineColor drawing.Color
+	// Width and Height are the chart size, before scaling. Zero picks the
+	// default.
+	Width  int
+	Height int
+	// Font is used for all the chart text. Nil picks the default.
+	Font *truetype.Font
+	// MaxPoints downsamples lines with more points, if positive, so the
+	// output doesn't get too big.
+	MaxPoints int
+	// DateFormat is the go reference time layout of the x axis labels. Empty
+	// picks one based on the time span of the chart.
+	DateFormat string
+	// XTicks and YTicks are about how many ticks to draw on each axis. Zero
+	// picks a default.
+	XTicks int
+	YTicks int
+	// YAxisLeft draws the y axis on the left side of the plot, instead of
+	// the right side.
+	YAxisLeft bool
+	// DailyBars draws the new stars of each day as bars behind the line, with
+	// their own axis on the right side, moving the stars axis to the left.
+	DailyBars bool
+	// Per is the period each of the DailyBars counts the new stars of, a day
+	// if zero. Hours fall back to days for spans that would need too many
+	// bars, see maxHourBuckets.
+	Per time.Duration
+	// SkipWeekends leaves saturdays and sundays out of the daily bars, adding
+	// their stars to the following monday, or dropping them if
+	// DropWeekendStars is set.
+	SkipWeekends     bool
+	DropWeekendStars bool
+	// CSSClasses sets CSS classes on the main parts of SVG charts instead of
+	// inline styles, along with a style element with their default look, so
+	// pages embedding them can restyle them with their own stylesheets.
+	// The classes are sc-line for the star line, sc-axis for the axis lines
+	// and ticks, sc-grid for the horizontal grid lines, hidden by default,
+	// and sc-text for the axis labels and names.
+	CSSClasses bool
+	// CalendarTicks puts the x axis ticks on calendar boundaries, days,
+	// weeks, months, quarters or years apart depending on the span and
+	// width of the chart, unless XTicks is set.
+	CalendarTicks bool
+	// AxisMin and AxisMax force the x axis to start and end at the given
+	// times, leaving empty space where there is no data and clipping the
+	// data outside of them. Zero keeps the data extent on that side.
+	AxisMin time.Time
+	AxisMax time.Time
+	// YBase starts the y axis at the given star count, e.g. zero, cutting
+	// the values below it, if not nil.
+	// YBaseAuto starts it a bit below the lowest visible star count instead,
+	// to emphasize recent change.
+	// Otherwise the y axis starts at the round tick below the lowest star
+	// count.
+	YBase     *int
+	YBaseAuto bool
+	// Approximate marks the chart as an approximation, e.g. of a repository
+	// with too many stars to list, charted from a sample of them.
+	Approximate bool
+	// InterpolatedAfter is when the star line of an approximate chart stops
+	// following the data, only interpolating towards the star count after
+	// it, if not zero.
+	InterpolatedAfter time.Time
+	// Name is what the chart is of, e.g. owner/repo, to describe SVG charts
+	// to screen readers.
+	Name string
+	// Bands are date ranges shaded behind the data.
+	Bands []Band
+	// StrokeWidth is the width of the star line on a chart of the default
+	// size, scaled up for larger ones. Zero picks the default.
+	StrokeWidth float64
+}
+
+// transparentStyle draws nothing.
+//
+// Its colors have zero alpha, but aren't zero values, otherwise go-chart
+// would use its default (white) colors instead.
+// nolint: gochecknoglobals
+var transparentStyle = chart.Style{
+	FillColor:   drawing.Color{R: 255, G: 255, B: 255, A: 0},
+	StrokeColor: drawing.Color{R: 255, G: 255, B: 255, A: 0},
+}
+
+// addWatermark draws the given text in the bottom right corner of the graph,
+// below the x axis labels, so it doesn't overlap the data.
+func addWatermark(graph *chart.Chart, text string) {
+	if text == "" {
+		return
+	}
+	width, height := graph.GetWidth(), graph.GetHeight()
+	margin := height / 80
+	graph.Elements = append(graph.Elements, func(r chart.Renderer, _ chart.Box, defaults chart.Style) {
+		style := chart.Style{
+			FontSize:  8,
+			FontColor: drawing.Color{R: 85, G: 85, B: 85, A: 128},
+		}.InheritFrom(defaults)
+		style.GetTextOptions().WriteToRenderer(r)
+		box := r.MeasureText(text)
+		chart.Draw.Text(r, text, width-box.Width()-margin, height-margin, style)
+	})
+}
+
+// WriteChart renders the star chart of the given stargazers into w.
+func WriteChart(w io.Writer, stargazers []github.Stargazer, opts ChartOptions) error {
+	points := timelinePoints(stargazers, opts.Baseline)
+	graph := buildGraph(log.Log, points, opts.Baseline, opts.lineColor())
+	addGoal(&graph, stargazers, opts.Baseline, opts.Goal)
+	addForecast(&graph, stargazers, opts.Baseline, opts.ForecastDays, opts.lineColor())
+	return renderGraph(w, graph, opts, func() []Point {
+		return points
+	})
+}
+
+// WriteSeriesChart renders the chart of the given points into w, e.g. to
+// chart a timeline that doesn't come from github.
+//
+// The points must be sorted by date. Goals and forecasts need every
+// stargazer, so they are ignored.
+func WriteSeriesChart(w io.Writer, points []Point, opts ChartOptions) error {
+	graph := buildGraph(log.Log, points, opts.Baseline, opts.lineColor())
+	return renderGraph(w, graph, opts, func() []Point {
+		return points
+	})
+}
+
+// WriteHistogramChart renders the star chart of the given histogram into w.
+//
+// Goals, forecasts and embedded data need every stargazer, so they are
+// ignored.
+func WriteHistogramChart(w io.Writer, hist *github.StarHistogram, opts ChartOptions) error {
+	graph := buildHistogramGraph(hist, opts.Baseline, opts.lineColor())
+	opts.EmbedData = false
+	return renderGraph(w, graph, opts, nil)
+}
+
+func (opts ChartOptions) lineColor() drawing.Color {
+	if opts.LineColor.IsZero() {
+		return lineColor
+	}
+	return opts.LineColor
+}
+
+// pixelSize is the size the chart is laid out at, in pixels.
+// PNG charts are scaled up as a whole when drawn, see scaledPNG.
+func (opts ChartOptions) pixelSize() (width, height int) {
+	width, height = opts.Width, opts.Height
+	if width < 1 {
+		width = chart.DefaultChartWidth
+	}
+	if height < 1 {
+		height = chart.DefaultChartHeight
+	}
+	return width, height
+}
+
+func (opts ChartOptions) weekendRule() weekendRule {
+	switch {
+	case !opts.SkipWeekends:
+		return keepWeekends
+	case opts.DropWeekendStars:
+		return dropWeekends
+	default:
+		return carryWeekends
+	}
+}
+
+// renderGraph applies the rendering options to the graph and renders it into
+// w, embedding the points returned by data if asked to.
+func renderGraph(w io.Writer, graph chart.Chart, opts ChartOptions, data func() []Point) error {
+	downsampleGraph(&graph, opts.MaxPoints)
+	clampXAxis(&graph, opts.AxisMin, opts.AxisMax)
+	desc := describeChart(graph, opts.Name)
+	applyDateFormat(&graph, opts.DateFormat)
+	applyTicks(&graph, opts.XTicks, opts.YTicks)
+	applyYBase(&graph, opts.YBase, opts.YBaseAuto, opts.YTicks)
+	if opts.Approximate {
+		graph.YAxis.Name += " (approximate)"
+		desc += " (approximate)"
+	}
+	if !opts.InterpolatedAfter.IsZero() {
+		desc += ", interpolated after " + opts.InterpolatedAfter.Format("Jan 2006") + " as github doesn't list later stars"
+	}
+	width, height := opts.pixelSize()
+	if opts.CalendarTicks && opts.XTicks == 0 {
+		applyCalendarTicks(&graph, opts.DateFormat, width)
+	}
+	if opts.Reverse {
+		xrange, _ := graph.XAxis.Range.(*chart.ContinuousRange)
+		if xrange == nil {
+			xrange = &chart.ContinuousRange{}
+		}
+		xrange.Descending = true
+		graph.XAxis.Range = xrange
+	}
+	applyTheme(&graph, opts.Theme)
+	applyStrokeWidth(&graph, opts.StrokeWidth, width, height)
+	dashInterpolated(&graph, opts.InterpolatedAfter)
+	var classes []svgClass
+	if opts.CSSClasses && !opts.Raster {
+		classes = useClasses(&graph)
+	}
+	if opts.DailyBars && data != nil {
+		points, baseline := clipPoints(data(), opts.Baseline, opts.AxisMin, opts.AxisMax)
+		period := pointsPeriod(opts.Per, points)
+		addDailyBars(&graph, points, baseline, opts.YTicks, period, opts.weekendRule(), opts.lineColor())
+	} else if opts.YAxisLeft {
+		moveYAxisLeft(&graph)
+	}
+	addBands(&graph, opts.Bands, opts.Raster)
+	if opts.Transparent {
+		graph.Background = transparentStyle
+		graph.Canvas = transparentStyle
+	}
+	graph.Font = opts.Font
+	graph.Width, graph.Height = opts.Width, opts.Height
+
+	if opts.Raster {
+		addWatermark(&graph, opts.Watermark)
+		return graph.Render(scaledPNG(opts.Scale), w)
+	}
+
+	// svg text is written as is, so it must be escaped.
+	addWatermark(&graph, html.EscapeString(opts.Watermark))
+
+	svg, err := renderSVG(graph)
+	if err != nil {
+		return err
+	}
+	if classes != nil {
+		svg = setClasses(svg, classes)
+	}
+	if svg, err = addAccessibility(svg, chartTitle(opts.Name), desc); err != nil {
+		return err
+	}
+	if opts.EmbedData {
+		if svg, err = embedData(svg, data()); err != nil {
+			return err
+		}
+	}
+	_, err = w.Write(svg)
+	return err
+}
@@ -0,0 +1,113 @@
+package controller
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/caarlos0/starcharts/internal/github"
+	"github.com/matryer/is"
+	chart "github.com/wcharczuk/go-chart"
+)
+
+func TestWriteChart(t *testing.T) {
+	var stargazers []github.Stargazer
+	for i := 60; i > 0; i-- {
+		stargazers = append(stargazers, github.Stargazer{
+			StarredAt: time.Now().Add(-time.Duration(i) * 24 * time.Hour),
+		})
+	}
+
+	t.Run("svg", func(t *testing.T) {
+		is := is.New(t)
+		var buf bytes.Buffer
+		is.NoErr(WriteChart(&buf, stargazers, ChartOptions{Goal: 100, ForecastDays: 30}))
+		is.True(bytes.HasPrefix(buf.Bytes(), []byte("<svg"))) // should be a svg
+	})
+
+	t.Run("svg with data", func(t *testing.T) {
+		is := is.New(t)
+		var buf bytes.Buffer
+		is.NoErr(WriteChart(&buf, stargazers, ChartOptions{EmbedData: true}))
+		is.True(bytes.Contains(buf.Bytes(), []byte("<metadata"))) // should embed the data
+	})
+
+	t.Run("transparent svg", func(t *testing.T) {
+		is := is.New(t)
+		var buf bytes.Buffer
+		is.NoErr(WriteChart(&buf, stargazers, ChartOptions{Transparent: true}))
+		// go-chart draws the background as a filled path rather than a rect.
+		is.True(!bytes.Contains(buf.Bytes(), []byte("fill:rgba(255,255,255,1.0)"))) // should not have a background
+	})
+
+	t.Run("reversed svg", func(t *testing.T) {
+		is := is.New(t)
+		var buf bytes.Buffer
+		is.NoErr(WriteChart(&buf, stargazers, ChartOptions{Reverse: true, XTicks: 2}))
+		labelX := func(date time.Time) int {
+			re := regexp.MustCompile(`<text x="(\d+)"[^>]*>` + date.Format("2006-01-02") + `</text>`)
+			match := re.FindSubmatch(buf.Bytes())
+			is.True(match != nil) // should have the date label
+			x, err := strconv.Atoi(string(match[1]))
+			is.NoErr(err)
+			return x
+		}
+		newest := labelX(stargazers[len(stargazers)-1].StarredAt)
+		oldest := labelX(stargazers[0].StarredAt)
+		is.True(newest < oldest) // newest should be on the left
+	})
+
+	t.Run("y axis on the left", func(t *testing.T) {
+		is := is.New(t)
+		labelX := func(left bool) int {
+			var buf bytes.Buffer
+			is.NoErr(WriteChart(&buf, stargazers, ChartOptions{YAxisLeft: left}))
+			match := regexp.MustCompile(`<text x="(\d+)"[^>]*>60</text>`).FindSubmatch(buf.Bytes())
+			is.True(match != nil) // should have the y axis label
+			x, err := strconv.Atoi(string(match[1]))
+			is.NoErr(err)
+			return x
+		}
+		left, right := labelX(true), labelX(false)
+		is.True(left < chart.DefaultChartWidth/2)  // label should be on the left
+		is.True(right > chart.DefaultChartWidth/2) // label should be on the right
+	})
+
+	t.Run("daily bars", func(t *testing.T) {
+		is := is.New(t)
+		var buf bytes.Buffer
+		is.NoErr(WriteChart(&buf, stargazers, ChartOptions{DailyBars: true}))
+		is.True(bytes.Contains(buf.Bytes(), []byte(">New stars per day</text>"))) // should label the bars axis
+	})
+
+	t.Run("watermark", func(t *testing.T) {
+		is := is.New(t)
+		var buf bytes.Buffer
+		is.NoErr(WriteChart(&buf, stargazers, ChartOptions{Watermark: "generated by <starcharts>"}))
+		is.True(bytes.Contains(buf.Bytes(), []byte(">generated by &lt;starcharts&gt;</text>"))) // should have the escaped watermark
+	})
+
+	t.Run("png", func(t *testing.T) {
+		is := is.New(t)
+		var buf bytes.Buffer
+		is.NoErr(WriteChart(&buf, stargazers, ChartOptions{Raster: true, Scale: 2}))
+		is.True(bytes.HasPrefix(buf.Bytes(), []byte("\x89PNG"))) // should be a png
+	})
+}
+
+func TestWriteSeriesChart(t *testing.T) {
+	is := is.New(t)
+	now := time.Now()
+	points := []Point{
+		{Date: now.Add(-48 * time.Hour), Stars: 100},
+		{Date: now.Add(-24 * time.Hour), Stars: 250},
+		{Date: now, Stars: 500},
+	}
+	var buf bytes.Buffer
+	is.NoErr(WriteSeriesChart(&buf, points, ChartOptions{EmbedData: true}))
+	is.True(bytes.HasPrefix(buf.Bytes(), []byte("<svg")))       // should be a svg
+	is.True(bytes.Contains(buf.Bytes(), []byte(">500</text>"))) // should plot the given counts
+	is.True(bytes.Contains(buf.Bytes(), []byte("<metadata")))   // should embed the points
+}
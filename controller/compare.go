@@ -0,0 +1,278 @@
+package controller
+
+import (
+	"hash/fnv"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/caarlos0/httperr"
+	"github.com/caarlos0/starcharts/internal/github"
+	chart "github.com/wcharczuk/go-chart"
+	"github.com/wcharczuk/go-chart/drawing"
+	"golang.org/x/sync/errgroup"
+)
+
+// maxCompareRepos bounds how many repositories can be compared in a single
+// chart.
+const maxCompareRepos = 5
+
+// GetCompareChart returns a SVG chart comparing the stars of the
+// repositories given in the repos query parameter, e.g.
+// /compare.svg?repos=caarlos0/starcharts,caarlos0/env.
+//
+// Each repository always gets the same color, unless overridden by the
+// colors query parameter, e.g. colors=ff0000,00ff00, in the same order as
+// repos.
+//
+// With normalize=percent, each repository is plotted as the percentage of its
+// own current total, so repositories of very different sizes can be compared.
+//
+// With stack=true, each repository is a band stacked on top of the previous
+// ones instead, so the top edge is the combined total.
+//
+// The axis_min and axis_max query parameters force the x axis bounds, so
+// several charts can be aligned side by side.
+func GetCompareChart(gh *github.GitHub) http.Handler {
+	return httperr.NewF(func(w http.ResponseWriter, r *http.Request) error {
+		names, err := compareRepoNames(r)
+		if err != nil {
+			return err
+		}
+		colors, err := compareColors(names, r.URL.Query().Get("colors"))
+		if err != nil {
+			return err
+		}
+		axisMin, axisMax, err := axisRange(r)
+		if err != nil {
+			return err
+		}
+		percent := r.URL.Query().Get("normalize") == "percent"
+		stack := r.URL.Query().Get("stack") == "true"
+		if percent && stack {
+			return httperr.Errorf(http.StatusBadRequest, "stack can't be combined with normalize=percent")
+		}
+
+		log := log.WithField("repos", strings.Join(names, ","))
+		defer log.Trace("collect_stars").Stop(nil)
+
+		repos := make([]github.Repository, len(names))
+		for i, name := range names {
+			repo, err := gh.RepoDetails(r.Context(), name)
+			if err != nil {
+				return fetchErr(w, err, http.StatusBadRequest)
+			}
+			repos[i] = repo
+		}
+
+		all := make([][]github.Stargazer, len(repos))
+		g, ctx := errgroup.WithContext(r.Context())
+		g.SetLimit(gh.RepoConcurrency())
+		for i, repo := range repos {
+			i, repo := i, repo
+			g.Go(func() error {
+				stargazers, err := gh.Stargazers(ctx, repo)
+				all[i] = stargazers
+				return err
+			})
+		}
+		if err := g.Wait(); err != nil {
+			log.WithError(err).Error("failed to get stars")
+			return fetchErr(w, err, http.StatusInternalServerError)
+		}
+
+		var series []chart.Series
+		for i, repo := range repos {
+			series = append(series, compareSeries(repo.FullName, all[i], colors[i], percent))
+		}
+		if stack {
+			series = stackSeries(names, all, colors, time.Now())
+		}
+
+		graph := newGraph(IntValueFormatter, series...)
+		if percent {
+			graph.YAxis.Name = "Stargazers (%)"
+			graph.YAxis.Range = &chart.ContinuousRange{Min: 0, Max: 100}
+		}
+		graph.Elements = []chart.Renderable{chart.Legend(&graph)}
+		clampXAxis(&graph, axisMin, axisMax)
+
+		w.Header().Add("content-type", "image/svg+xml;charset=utf-8")
+		w.Header().Add("cache-control", "public, max-age=86400")
+		w.Header().Add("date", time.Now().Format(time.RFC1123))
+		w.Header().Add("expires", time.Now().Format(time.RFC1123))
+
+		defer log.Trace("chart").Stop(&err)
+		svg, err := renderSVG(graph)
+		if err != nil {
+			log.WithError(err).Error("failed to render graph")
+			return err
+		}
+		_, err = w.Write(svg)
+		return err
+	})
+}
+
+// compareSeries builds the series of a single repository in a comparison
+// chart, either as absolute counts or as the percentage of its total.
+func compareSeries(name string, stargazers []github.Stargazer, color drawing.Color, percent bool) chart.TimeSeries {
+	total := float64(len(stargazers))
+	series := starSeries(stargazers, color, func(i int) float64 {
+		if percent {
+			return float64(i+1) * 100 / total
+		}
+		return float64(i)
+	})
+	if len(series.XValues) == 0 {
+		series.XValues = append(series.XValues, time.Now())
+		series.YValues = append(series.YValues, 0)
+	}
+	series.Name = name
+	return series
+}
+
+// stackSeries builds the bands of a stacked comparison chart, each
+// repository on top of the previous ones, so the top edge is the total.
+//
+// The series share the dates of every star, carrying each repository count
+// forward between its own stars, so repositories with different date ranges
+// still stack. The top band comes first, as each band is filled down to the
+// x axis and must be drawn over the ones above it.
+func stackSeries(names []string, stargazers [][]github.Stargazer, colors []drawing.Color, now time.Time) []chart.Series {
+	var dates []time.Time
+	for _, stars := range stargazers {
+		for _, star := range stars {
+			dates = append(dates, star.StarredAt)
+		}
+	}
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+	dates = append(dates, now)
+
+	totals := make([]float64, len(dates))
+	series := make([]chart.Series, len(names))
+	for i, stars := range stargazers {
+		band := chart.TimeSeries{
+			Name: names[i],
+			Style: chart.Style{
+				Show:        true,
+				StrokeColor: colors[i],
+				StrokeWidth: 1,
+				FillColor:   colors[i],
+			},
+		}
+		var count int
+		for j, date := range dates {
+			for count < len(stars) && !stars[count].StarredAt.After(date) {
+				count++
+			}
+			totals[j] += float64(count)
+			band.XValues = append(band.XValues, date)
+			band.YValues = append(band.YValues, totals[j])
+		}
+		series[len(names)-1-i] = band
+	}
+	return series
+}
+
+// comparePalette holds visually distinct colors for comparison charts.
+// nolint: gochecknoglobals
+var comparePalette = []drawing.Color{
+	drawing.ColorFromHex("1f77b4"),
+	drawing.ColorFromHex("ff7f0e"),
+	drawing.ColorFromHex("2ca02c"),
+	drawing.ColorFromHex("d62728"),
+	drawing.ColorFromHex("9467bd"),
+	drawing.ColorFromHex("8c564b"),
+	drawing.ColorFromHex("e377c2"),
+	drawing.ColorFromHex("7f7f7f"),
+	drawing.ColorFromHex("bcbd22"),
+	drawing.ColorFromHex("17becf"),
+}
+
+// nolint: gochecknoglobals
+var hexColorRe = regexp.MustCompile(`^[0-9a-fA-F]{6}$`)
+
+// compareShades lightens or darkens a palette color, so repositories hashed
+// into the same slot are still told apart most of the time.
+// nolint: gochecknoglobals
+var compareShades = []float64{0, 0.35, -0.35}
+
+// compareColors picks a color for each of the given repositories, hashing
+// its name into the palette and a shade of it. The color only depends on the
+// repository itself, so it is the same in every chart it shows up in.
+// The comma separated overrides, if any, take precedence.
+func compareColors(names []string, overrides string) ([]drawing.Color, error) {
+	var custom []string
+	if overrides != "" {
+		custom = strings.Split(overrides, ",")
+	}
+	if len(custom) > len(names) {
+		return nil, httperr.Errorf(http.StatusBadRequest, "more colors than repositories")
+	}
+
+	colors := make([]drawing.Color, len(names))
+	for i, name := range names {
+		if i < len(custom) && custom[i] != "" {
+			if !hexColorRe.MatchString(custom[i]) {
+				return nil, httperr.Errorf(http.StatusBadRequest, "invalid color: %q", custom[i])
+			}
+			colors[i] = drawing.ColorFromHex(custom[i])
+			continue
+		}
+		colors[i] = repoColor(name)
+	}
+	return colors, nil
+}
+
+// repoColor hashes the repository name into a palette color and, with the
+// remaining bits of the hash, one of its shades.
+func repoColor(name string) drawing.Color {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(name))
+	sum := h.Sum32()
+	color := comparePalette[sum%uint32(len(comparePalette))]
+	shade := compareShades[sum/uint32(len(comparePalette))%uint32(len(compareShades))]
+	return shadeColor(color, shade)
+}
+
+// shadeColor moves the color towards white when f is positive and towards
+// black when it is negative.
+func shadeColor(c drawing.Color, f float64) drawing.Color {
+	target := 255.0
+	if f < 0 {
+		target, f = 0, -f
+	}
+	channel := func(v uint8) uint8 {
+		return uint8(float64(v) + (target-float64(v))*f)
+	}
+	return drawing.Color{R: channel(c.R), G: channel(c.G), B: channel(c.B), A: c.A}
+}
+
+// compareRepoNames parses and normalizes the repos query parameter.
+func compareRepoNames(r *http.Request) ([]string, error) {
+	var names []string
+	for _, value := range strings.Split(r.URL.Query().Get("repos"), ",") {
+		if value == "" {
+			continue
+		}
+		parts := strings.Split(value, "/")
+		if len(parts) != 2 {
+			return nil, httperr.Errorf(http.StatusBadRequest, "invalid repository: %q", value)
+		}
+		name, err := normalizeRepoName(parts[0], parts[1])
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return nil, httperr.Errorf(http.StatusBadRequest, "no repositories to compare")
+	}
+	if len(names) > maxCompareRepos {
+		return nil, httperr.Errorf(http.StatusBadRequest, "can compare at most %d repositories", maxCompareRepos)
+	}
+	return names, nil
+}
@@ -0,0 +1,99 @@
+package controller
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/caarlos0/starcharts/internal/github"
+	"github.com/matryer/is"
+	chart "github.com/wcharczuk/go-chart"
+	"github.com/wcharczuk/go-chart/drawing"
+)
+
+func TestCompareSeriesPercent(t *testing.T) {
+	is := is.New(t)
+	now := time.Now()
+	stargazers := []github.Stargazer{
+		{StarredAt: now.Add(-3 * time.Hour)},
+		{StarredAt: now.Add(-2 * time.Hour)},
+		{StarredAt: now.Add(-1 * time.Hour)},
+		{StarredAt: now},
+	}
+
+	series := compareSeries("a/b", stargazers, chart.GetDefaultColor(0), true)
+	is.Equal([]float64{25, 50, 75, 100}, series.YValues)
+
+	series = compareSeries("a/b", stargazers, chart.GetDefaultColor(0), false)
+	is.Equal([]float64{0, 1, 2, 3}, series.YValues)
+}
+
+func TestStackSeries(t *testing.T) {
+	is := is.New(t)
+	now := time.Now()
+	series := stackSeries(
+		[]string{"a/a", "b/b"},
+		[][]github.Stargazer{
+			{{StarredAt: now.Add(-3 * time.Hour)}, {StarredAt: now.Add(-1 * time.Hour)}},
+			{{StarredAt: now.Add(-2 * time.Hour)}},
+		},
+		[]drawing.Color{chart.GetDefaultColor(0), chart.GetDefaultColor(1)},
+		now,
+	)
+	is.Equal(2, len(series))
+
+	top := series[0].(chart.TimeSeries)
+	is.Equal("b/b", top.Name)
+	is.Equal([]float64{1, 2, 3, 3}, top.YValues) // should be the total
+
+	bottom := series[1].(chart.TimeSeries)
+	is.Equal("a/a", bottom.Name)
+	is.Equal([]float64{1, 1, 2, 2}, bottom.YValues) // should be carried forward
+	is.Equal(top.XValues, bottom.XValues)           // should share the dates
+}
+
+func TestCompareRepoNames(t *testing.T) {
+	is := is.New(t)
+
+	names, err := compareRepoNames(httptest.NewRequest("GET", "/compare.svg?repos=Caarlos0/StarCharts,caarlos0/env", nil))
+	is.NoErr(err)
+	is.Equal([]string{"caarlos0/starcharts", "caarlos0/env"}, names)
+
+	_, err = compareRepoNames(httptest.NewRequest("GET", "/compare.svg", nil))
+	is.True(err != nil) // should fail without repos
+
+	_, err = compareRepoNames(httptest.NewRequest("GET", "/compare.svg?repos=a/b/c", nil))
+	is.True(err != nil) // should fail with invalid repo
+
+	_, err = compareRepoNames(httptest.NewRequest("GET", "/compare.svg?repos=a/a,a/b,a/c,a/d,a/e,a/f", nil))
+	is.True(err != nil) // should fail with too many repos
+}
+
+func TestCompareColors(t *testing.T) {
+	is := is.New(t)
+	names := []string{"caarlos0/starcharts", "caarlos0/env", "goreleaser/goreleaser"}
+
+	colors, err := compareColors(names, "")
+	is.NoErr(err)
+	other, err := compareColors([]string{"goreleaser/nfpm", names[2], names[0]}, "")
+	is.NoErr(err)
+	is.Equal(colors[0], other[2]) // should keep the color in other lists
+	is.Equal(colors[2], other[1]) // should keep the color in other lists
+
+	seen := map[drawing.Color]bool{}
+	for _, color := range colors {
+		is.True(!seen[color]) // colors should be distinct
+		seen[color] = true
+	}
+
+	colors, err = compareColors(names, ",ff0000")
+	is.NoErr(err)
+	is.Equal(drawing.ColorFromHex("ff0000"), colors[1]) // should use the override
+	is.Equal(other[2], colors[0])                       // should keep the others
+
+	_, err = compareColors(names, "red")
+	is.True(err != nil) // should fail with invalid color
+
+	_, err = compareColors(names, "ff0000,ff0000,ff0000,ff0000")
+	is.True(err != nil) // should fail with more colors than repos
+}
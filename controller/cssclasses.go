@@ -0,0 +1,151 @@
+package controller
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+
+	chart "github.com/wcharczuk/go-chart"
+	"github.com/wcharczuk/go-chart/drawing"
+)
+
+// cssClasses tells whether the css query parameter asks for CSS classes
+// instead of inline styles.
+func cssClasses(value string) bool {
+	return value == "classes"
+}
+
+// svgClass is a CSS class set on the SVG elements drawn with its marker
+// color, which is never used otherwise.
+type svgClass struct {
+	name   string
+	marker drawing.Color
+	// rule is the default style of the class, so the chart looks as usual.
+	rule string
+}
+
+// nolint: gochecknoglobals
+var (
+	lineMarker = drawing.Color{R: 5, G: 99, B: 1, A: 255}
+	axisMarker = drawing.Color{R: 5, G: 99, B: 2, A: 255}
+	gridMarker = drawing.Color{R: 5, G: 99, B: 3, A: 255}
+	textMarker = drawing.Color{R: 5, G: 99, B: 4, A: 255}
+)
+
+// useClasses draws the main parts of the graph with marker colors, returning
+// the classes that setClasses should set on them:
+//
+//   - sc-line: the star line.
+//   - sc-axis: the axis lines and ticks.
+//   - sc-grid: the horizontal grid lines, hidden by default.
+//   - sc-text: the axis labels and names.
+//
+// Everything else, like goals or forecasts, keeps its inline style.
+func useClasses(graph *chart.Chart) []svgClass {
+	var classes []svgClass
+	if len(graph.Series) > 0 {
+		if series, ok := graph.Series[0].(chart.TimeSeries); ok {
+			classes = append(classes, svgClass{
+				name:   "sc-line",
+				marker: lineMarker,
+				rule:   strokeRule(series.Style.StrokeColor, series.Style.StrokeWidth),
+			})
+			series.Style.StrokeColor = lineMarker
+			graph.Series[0] = series
+		}
+	}
+
+	classes = append(classes, svgClass{
+		name:   "sc-axis",
+		marker: axisMarker,
+		rule:   strokeRule(graph.YAxis.Style.StrokeColor, graph.YAxis.Style.StrokeWidth),
+	})
+	graph.XAxis.Style.StrokeColor = axisMarker
+	graph.YAxis.Style.StrokeColor = axisMarker
+
+	classes = append(classes, svgClass{
+		name:   "sc-grid",
+		marker: gridMarker,
+		rule:   "stroke:none;stroke-width:1;fill:none",
+	})
+	grid := chart.Style{Show: true, StrokeColor: gridMarker, StrokeWidth: 1}
+	graph.YAxis.GridMajorStyle = grid
+	graph.YAxis.GridMinorStyle = grid
+
+	text := graph.XAxis.Style.FontColor
+	if text.IsZero() {
+		text = chart.DefaultTextColor
+	}
+	classes = append(classes, svgClass{
+		name:   "sc-text",
+		marker: textMarker,
+		rule:   "fill:" + text.String(),
+	})
+	for _, style := range []*chart.Style{
+		&graph.XAxis.Style, &graph.XAxis.NameStyle,
+		&graph.YAxis.Style, &graph.YAxis.NameStyle,
+	} {
+		style.FontColor = textMarker
+	}
+	return classes
+}
+
+func strokeRule(color drawing.Color, width float64) string {
+	return fmt.Sprintf("stroke:%s;stroke-width:%.0f;fill:none", color, width)
+}
+
+// svgStyle matches the inline style of the SVG elements.
+// nolint: gochecknoglobals
+var svgStyle = regexp.MustCompile(`style="([^"]*)"`)
+
+// setClasses replaces the inline styles of the elements drawn with the marker
+// colors of the given classes by the classes themselves, adding a style
+// element with their default rules.
+//
+// Only the font size and family of texts stay inline, as the text layout
+// depends on them.
+func setClasses(svg []byte, classes []svgClass) []byte {
+	svg = svgStyle.ReplaceAllFunc(svg, func(attr []byte) []byte {
+		style := string(attr[len(`style="`) : len(attr)-1])
+		for _, class := range classes {
+			marker := class.marker.String()
+			if strings.Contains(style, "stroke:"+marker) {
+				return []byte(`class="` + class.name + `"`)
+			}
+			if strings.Contains(style, "fill:"+marker) {
+				var font []string
+				for _, piece := range strings.Split(style, ";") {
+					if strings.HasPrefix(piece, "font-") {
+						font = append(font, piece)
+					}
+				}
+				return []byte(`class="` + class.name + `" style="` + strings.Join(font, ";") + `"`)
+			}
+		}
+		return attr
+	})
+
+	var rules strings.Builder
+	rules.WriteString("<style>")
+	for _, class := range classes {
+		fmt.Fprintf(&rules, ".%s{%s}", class.name, class.rule)
+	}
+	rules.WriteString("</style>")
+
+	start := bytes.Index(svg, []byte("<svg"))
+	if start < 0 {
+		return svg
+	}
+	end := bytes.IndexByte(svg[start:], '>')
+	if end < 0 {
+		return svg
+	}
+	end += start + 1
+	var buf bytes.Buffer
+	buf.Grow(len(svg) + rules.Len())
+	buf.Write(svg[:end])
+	buf.WriteString(rules.String())
+	buf.Write(svg[end:])
+	return buf.Bytes()
+}
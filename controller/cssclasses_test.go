@@ -0,0 +1,55 @@
+package controller
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/caarlos0/starcharts/internal/github"
+	"github.com/matryer/is"
+)
+
+func TestCSSClasses(t *testing.T) {
+	var stargazers []github.Stargazer
+	for i := 10; i > 0; i-- {
+		stargazers = append(stargazers, github.Stargazer{
+			StarredAt: time.Now().Add(-time.Duration(i) * 24 * time.Hour),
+		})
+	}
+
+	t.Run("classes", func(t *testing.T) {
+		is := is.New(t)
+		var buf bytes.Buffer
+		is.NoErr(WriteChart(&buf, stargazers, ChartOptions{CSSClasses: true}))
+		svg := buf.Bytes()
+		for _, class := range []string{"sc-line", "sc-axis", "sc-grid", "sc-text"} {
+			is.True(bytes.Contains(svg, []byte(`class="`+class+`"`))) // should set the class
+			is.True(bytes.Contains(svg, []byte("."+class+"{")))       // should have a default rule
+		}
+		is.True(bytes.Contains(svg, []byte(".sc-line{stroke:"+lineColor.String()))) // should keep the line color
+		is.True(!bytes.Contains(svg, []byte("rgba(5,99,")))                         // should not leak the marker colors
+	})
+
+	t.Run("inline styles by default", func(t *testing.T) {
+		is := is.New(t)
+		var buf bytes.Buffer
+		is.NoErr(WriteChart(&buf, stargazers, ChartOptions{}))
+		is.True(!bytes.Contains(buf.Bytes(), []byte(`class="`)))
+		is.True(!bytes.Contains(buf.Bytes(), []byte("<style>")))
+	})
+
+	t.Run("png", func(t *testing.T) {
+		is := is.New(t)
+		var buf bytes.Buffer
+		is.NoErr(WriteChart(&buf, stargazers, ChartOptions{CSSClasses: true, Raster: true}))
+		is.True(bytes.HasPrefix(buf.Bytes(), []byte("\x89PNG"))) // should be a png
+	})
+}
+
+func TestSetClasses(t *testing.T) {
+	is := is.New(t)
+	classes := []svgClass{{name: "sc-text", marker: textMarker, rule: "fill:red"}}
+	svg := setClasses([]byte(`<svg width="1"><text style="stroke:none;fill:`+textMarker.String()+`;font-size:12px">a</text><text style="fill:blue">b</text></svg>`), classes)
+	is.Equal(string(svg), `<svg width="1"><style>.sc-text{fill:red}</style><text class="sc-text" style="font-size:12px">a</text><text style="fill:blue">b</text></svg>`)
+	is.Equal(string(setClasses([]byte("nope"), classes)), "nope")
+}
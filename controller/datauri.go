@@ -0,0 +1,80 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+)
+
+// DataURI renders charts as a base64 data uri with output=datauri, or as an
+// img tag with it inlined with output=img, so they can be embedded where
+// remote images can't be referenced, e.g. emails.
+// Data uris are a third larger than the chart, so charts whose data uri would
+// be larger than maxSize are rejected.
+func DataURI(maxSize int, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		output := r.URL.Query().Get("output")
+		switch output {
+		case "":
+			next.ServeHTTP(w, r)
+			return
+		case "datauri", "img":
+		default:
+			http.Error(w, fmt.Sprintf("invalid output %q, use datauri or img", output), http.StatusBadRequest)
+			return
+		}
+
+		// the chart is encoded as it is, so it must not be compressed.
+		r = r.Clone(r.Context())
+		r.Header.Del("Accept-Encoding")
+		bw := &bufferWriter{ResponseWriter: w, code: http.StatusOK, max: base64.StdEncoding.DecodedLen(maxSize)}
+		next.ServeHTTP(bw, r)
+		if bw.code != http.StatusOK {
+			w.WriteHeader(bw.code)
+			_, _ = w.Write(bw.buf.Bytes())
+			return
+		}
+		w.Header().Del("content-length")
+		uri := "data:" + w.Header().Get("content-type") + ";base64," + base64.StdEncoding.EncodeToString(bw.buf.Bytes())
+		if bw.tooLarge || len(uri) > maxSize {
+			w.Header().Del("etag")
+			w.Header().Del("cache-control")
+			http.Error(w, fmt.Sprintf("chart is too large for a data uri, the max is %d bytes", maxSize), http.StatusRequestEntityTooLarge)
+			return
+		}
+		body := uri
+		w.Header().Set("content-type", "text/plain;charset=utf-8")
+		if output == "img" {
+			body = fmt.Sprintf(`<img src="%s" alt="Stargazers over time">`, uri)
+			w.Header().Set("content-type", "text/html;charset=utf-8")
+		}
+		w.WriteHeader(http.StatusOK)
+		if r.Method != http.MethodHead {
+			_, _ = w.Write([]byte(body))
+		}
+	})
+}
+
+// bufferWriter keeps the response body to write it somehow else, up to max
+// bytes.
+type bufferWriter struct {
+	http.ResponseWriter
+	code     int
+	buf      bytes.Buffer
+	max      int
+	tooLarge bool
+}
+
+func (w *bufferWriter) WriteHeader(code int) {
+	w.code = code
+}
+
+func (w *bufferWriter) Write(bts []byte) (int, error) {
+	if w.tooLarge || w.buf.Len()+len(bts) > w.max {
+		w.tooLarge = true
+		w.buf.Reset()
+		return len(bts), nil
+	}
+	return w.buf.Write(bts)
+}
@@ -0,0 +1,58 @@
+package controller
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestDataURI(t *testing.T) {
+	chart := "<svg></svg>"
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", "image/svg+xml;charset=utf-8")
+		_, _ = w.Write([]byte(chart))
+	})
+	serve := func(maxSize int, url string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		DataURI(maxSize, next).ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+		return w
+	}
+	uri := "data:image/svg+xml;charset=utf-8;base64," + base64.StdEncoding.EncodeToString([]byte(chart))
+
+	t.Run("image", func(t *testing.T) {
+		is := is.New(t)
+		w := serve(1024, "/test/test.svg")
+		is.Equal(chart, w.Body.String()) // should not have changed the chart
+	})
+
+	t.Run("data uri", func(t *testing.T) {
+		is := is.New(t)
+		w := serve(1024, "/test/test.svg?output=datauri")
+		is.Equal(http.StatusOK, w.Code)
+		is.Equal("text/plain;charset=utf-8", w.Header().Get("content-type"))
+		is.Equal(uri, w.Body.String())
+	})
+
+	t.Run("img tag", func(t *testing.T) {
+		is := is.New(t)
+		w := serve(1024, "/test/test.svg?output=img")
+		is.Equal("text/html;charset=utf-8", w.Header().Get("content-type"))
+		is.True(strings.Contains(w.Body.String(), `src="`+uri+`"`)) // should inline the data uri
+	})
+
+	t.Run("too large", func(t *testing.T) {
+		is := is.New(t)
+		w := serve(len(uri)-1, "/test/test.svg?output=datauri")
+		is.Equal(http.StatusRequestEntityTooLarge, w.Code)
+	})
+
+	t.Run("invalid output", func(t *testing.T) {
+		is := is.New(t)
+		w := serve(1024, "/test/test.svg?output=nope")
+		is.Equal(http.StatusBadRequest, w.Code)
+	})
+}
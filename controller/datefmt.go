@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	chart "github.com/wcharczuk/go-chart"
+)
+
+// maxDateFormatLen bounds the datefmt query parameter.
+const maxDateFormatLen = 32
+
+// dateFormatTokens are the reference time elements allowed in a date format,
+// longest first so they are matched greedily.
+// nolint: gochecknoglobals
+var dateFormatTokens = []string{
+	"January", "Monday", "2006", "Jan", "Mon", "_2", "01", "02", "06", "1", "2",
+}
+
+// dateFormatSeparators are the characters allowed between date elements.
+const dateFormatSeparators = " -/.,"
+
+var errInvalidDateFormat = errors.New("invalid date format")
+
+// parseDateFormat validates a date format given as a go reference time
+// layout, e.g. "Jan 2006" or "01/06".
+//
+// Only date elements and a few separators are allowed, anything else would be
+// rendered as is in the chart.
+func parseDateFormat(layout string) (string, error) {
+	if layout == "" || len(layout) > maxDateFormatLen {
+		return "", errInvalidDateFormat
+	}
+	var elements int
+	for rest := layout; rest != ""; {
+		if strings.ContainsRune(dateFormatSeparators, rune(rest[0])) {
+			rest = rest[1:]
+			continue
+		}
+		token := matchDateToken(rest)
+		if token == "" {
+			return "", errInvalidDateFormat
+		}
+		elements++
+		rest = rest[len(token):]
+	}
+	if elements == 0 {
+		return "", errInvalidDateFormat
+	}
+	return layout, nil
+}
+
+func matchDateToken(s string) string {
+	for _, token := range dateFormatTokens {
+		if strings.HasPrefix(s, token) {
+			return token
+		}
+	}
+	return ""
+}
+
+// autoDateFormat picks a date format fit for the given time span: full dates
+// for short spans, months otherwise.
+func autoDateFormat(span time.Duration) string {
+	if span < 90*24*time.Hour {
+		return chart.DefaultDateFormat
+	}
+	return "Jan 2006"
+}
+
+// applyDateFormat sets the x axis labels format, picking one based on the
+// time span of the graph if layout is empty.
+func applyDateFormat(graph *chart.Chart, layout string) {
+	if layout == "" {
+		minX, maxX, ok := xBounds(graph)
+		if !ok {
+			return
+		}
+		layout = autoDateFormat(time.Duration(maxX - minX))
+	}
+	graph.XAxis.ValueFormatter = chart.TimeValueFormatterWithFormat(layout)
+}
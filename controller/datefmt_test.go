@@ -0,0 +1,71 @@
+package controller
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/caarlos0/starcharts/internal/github"
+	"github.com/matryer/is"
+)
+
+func TestParseDateFormat(t *testing.T) {
+	for _, layout := range []string{
+		"Jan 2006",
+		"2006-01",
+		"01/06",
+		"January 2, 2006",
+		"Mon _2 Jan",
+	} {
+		t.Run(layout, func(t *testing.T) {
+			is := is.New(t)
+			got, err := parseDateFormat(layout)
+			is.NoErr(err)
+			is.Equal(layout, got)
+		})
+	}
+
+	for _, layout := range []string{
+		"",
+		" - ",
+		"15:04",
+		"2006 MST",
+		"<script>",
+		`2006"`,
+		"Janu",
+		"2006-01-02 2006-01-02 2006-01-02 2006",
+	} {
+		t.Run("invalid "+layout, func(t *testing.T) {
+			is := is.New(t)
+			_, err := parseDateFormat(layout)
+			is.Equal(errInvalidDateFormat, err)
+		})
+	}
+}
+
+func TestAutoDateFormat(t *testing.T) {
+	is := is.New(t)
+	is.Equal("2006-01-02", autoDateFormat(30*24*time.Hour))
+	is.Equal("Jan 2006", autoDateFormat(3*365*24*time.Hour))
+}
+
+func TestWriteChart_DateFormat(t *testing.T) {
+	start := time.Date(2020, 1, 15, 0, 0, 0, 0, time.Local)
+	stargazers := []github.Stargazer{
+		{StarredAt: start},
+		{StarredAt: start.AddDate(1, 0, 0)},
+	}
+
+	for layout, label := range map[string]string{
+		"":        ">Jan 2020<",
+		"2006-01": ">2020-01<",
+		"01/06":   ">01/20<",
+	} {
+		t.Run(layout, func(t *testing.T) {
+			is := is.New(t)
+			var buf bytes.Buffer
+			is.NoErr(WriteChart(&buf, stargazers, ChartOptions{DateFormat: layout, XTicks: 2}))
+			is.True(bytes.Contains(buf.Bytes(), []byte(label))) // should use the date format
+		})
+	}
+}
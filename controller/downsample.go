@@ -0,0 +1,70 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	chart "github.com/wcharczuk/go-chart"
+)
+
+// downsampleStep returns the step to take through n points so no more than
+// about max are kept, 1 meaning all of them.
+func downsampleStep(n, max int) int {
+	if max < 2 || n <= max {
+		return 1
+	}
+	// the last point is always kept, so leave room for it.
+	return (n-1)/(max-1) + 1
+}
+
+// downsampledLen returns how many of n points are kept with the given step.
+func downsampledLen(n, step int) int {
+	if step <= 1 || n == 0 {
+		return n
+	}
+	kept := (n-1)/step + 1
+	if (n-1)%step != 0 {
+		kept++ // the last point
+	}
+	return kept
+}
+
+// downsampleGraph thins out the time series of the graph with more than max
+// points, keeping every nth point along with the last one, so the line ends
+// at the current count.
+func downsampleGraph(graph *chart.Chart, max int) {
+	for i, s := range graph.Series {
+		series, ok := s.(chart.TimeSeries)
+		if !ok {
+			continue
+		}
+		step := downsampleStep(len(series.XValues), max)
+		if step == 1 {
+			continue
+		}
+		last := len(series.XValues) - 1
+		xs := make([]time.Time, 0, downsampledLen(len(series.XValues), step))
+		ys := make([]float64, 0, cap(xs))
+		for j := 0; j <= last; j += step {
+			xs = append(xs, series.XValues[j])
+			ys = append(ys, series.YValues[j])
+		}
+		if last%step != 0 {
+			xs = append(xs, series.XValues[last])
+			ys = append(ys, series.YValues[last])
+		}
+		series.XValues, series.YValues = xs, ys
+		graph.Series[i] = series
+	}
+}
+
+// setDownsampled tells clients that the chart line of the given amount of
+// points was downsampled, and to how many points.
+func setDownsampled(w http.ResponseWriter, n, max int) {
+	step := downsampleStep(n, max)
+	if step == 1 {
+		return
+	}
+	w.Header().Set("x-chart-downsampled", fmt.Sprintf("%d/%d", downsampledLen(n, step), n))
+}
@@ -0,0 +1,69 @@
+package controller
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/caarlos0/starcharts/internal/github"
+	"github.com/matryer/is"
+	chart "github.com/wcharczuk/go-chart"
+)
+
+func TestDownsampleGraph(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		n, max int
+	}{
+		{10, 100},
+		{100, 100},
+		{101, 100},
+		{1000, 100},
+		{1234, 100},
+		{5, 2},
+	} {
+		is := is.New(t)
+		series := chart.TimeSeries{}
+		for i := 0; i < tt.n; i++ {
+			series.XValues = append(series.XValues, start.Add(time.Duration(i)*time.Hour))
+			series.YValues = append(series.YValues, float64(i))
+		}
+		graph := chart.Chart{Series: []chart.Series{series}}
+		downsampleGraph(&graph, tt.max)
+
+		got := graph.Series[0].(chart.TimeSeries)
+		is.True(len(got.XValues) <= tt.max)                                            // should be under the limit
+		is.Equal(downsampledLen(tt.n, downsampleStep(tt.n, tt.max)), len(got.XValues)) // should keep the announced points
+		is.Equal(0.0, got.YValues[0])                                                  // should keep the first point
+		is.Equal(float64(tt.n-1), got.YValues[len(got.YValues)-1])                     // should keep the last point
+	}
+}
+
+func TestWriteChart_MaxPoints(t *testing.T) {
+	// irregular gaps, so the line isn't straight and compacting the svg
+	// doesn't drop most of its points.
+	var stargazers []github.Stargazer
+	at := time.Now().Add(-2000 * 7 * time.Hour)
+	for i := 0; i < 2000; i++ {
+		at = at.Add(time.Duration(i*7919%13) * time.Hour)
+		stargazers = append(stargazers, github.Stargazer{StarredAt: at})
+	}
+
+	is := is.New(t)
+	var full, small bytes.Buffer
+	is.NoErr(WriteChart(&full, stargazers, ChartOptions{}))
+	is.NoErr(WriteChart(&small, stargazers, ChartOptions{MaxPoints: 100}))
+	lines := func(svg []byte) int {
+		return bytes.Count(svg, []byte("L"))
+	}
+	is.True(lines(small.Bytes()) < lines(full.Bytes())/4) // should draw a lot fewer lines
+
+	w := httptest.NewRecorder()
+	setDownsampled(w, len(stargazers), 100)
+	is.Equal("97/2000", w.Header().Get("x-chart-downsampled"))
+
+	w = httptest.NewRecorder()
+	setDownsampled(w, len(stargazers), 0)
+	is.Equal("", w.Header().Get("x-chart-downsampled")) // should not downsample without a limit
+}
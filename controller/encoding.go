@@ -0,0 +1,160 @@
+package controller
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/apex/log"
+	"github.com/klauspost/compress/zstd"
+)
+
+// encodings supported for compressed responses, in order of preference.
+// nolint: gochecknoglobals
+var encodings = []struct {
+	name      string
+	newWriter func(w io.Writer) (io.WriteCloser, error)
+}{
+	{"zstd", newZstdWriter},
+	{"br", func(w io.Writer) (io.WriteCloser, error) {
+		return brotli.NewWriter(w), nil
+	}},
+	{"gzip", func(w io.Writer) (io.WriteCloser, error) {
+		return gzip.NewWriter(w), nil
+	}},
+}
+
+// zstdEncoders keeps the zstd encoders of finished responses, as they are
+// expensive to build.
+// nolint: gochecknoglobals
+var zstdEncoders sync.Pool
+
+// newZstdWriter returns a zstd encoder writing to w, reusing a pooled one if
+// any. Responses are small, so each encoder uses a single goroutine.
+func newZstdWriter(w io.Writer) (io.WriteCloser, error) {
+	zw, ok := zstdEncoders.Get().(*zstd.Encoder)
+	if !ok {
+		var err error
+		if zw, err = zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1)); err != nil {
+			return nil, err
+		}
+	}
+	zw.Reset(w)
+	return &pooledZstdWriter{zw}, nil
+}
+
+// pooledZstdWriter returns its encoder to the pool once closed.
+type pooledZstdWriter struct {
+	*zstd.Encoder
+}
+
+func (z *pooledZstdWriter) Close() error {
+	if z.Encoder == nil {
+		return nil
+	}
+	err := z.Encoder.Close()
+	z.Encoder.Reset(nil)
+	zstdEncoders.Put(z.Encoder)
+	z.Encoder = nil
+	return err
+}
+
+// compressedResponseWriter writes the response body through a compressor.
+type compressedResponseWriter struct {
+	http.ResponseWriter
+	w io.WriteCloser
+}
+
+func (c *compressedResponseWriter) Write(b []byte) (int, error) {
+	return c.w.Write(b)
+}
+
+// Close flushes the compressor.
+func (c *compressedResponseWriter) Close() error {
+	return c.w.Close()
+}
+
+type nopCloser struct {
+	http.ResponseWriter
+}
+
+func (nopCloser) Close() error { return nil }
+
+// compress negotiates the best content-encoding accepted by the request,
+// and returns a writer that compresses the response body with it.
+// The returned writer must be closed to flush the compressed body.
+func compress(w http.ResponseWriter, r *http.Request) interface {
+	http.ResponseWriter
+	io.Closer
+} {
+	varyEncoding(w)
+	encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+	for _, enc := range encodings {
+		if enc.name != encoding {
+			continue
+		}
+		ew, err := enc.newWriter(w)
+		if err != nil {
+			log.WithError(err).Warnf("failed to create %s writer, sending the response uncompressed", enc.name)
+			break
+		}
+		w.Header().Set("content-encoding", enc.name)
+		return &compressedResponseWriter{
+			ResponseWriter: w,
+			w:              ew,
+		}
+	}
+	return nopCloser{w}
+}
+
+// varyEncoding tells caches the response depends on the Accept-Encoding
+// header, as compress does.
+func varyEncoding(w http.ResponseWriter) {
+	w.Header().Add("vary", "Accept-Encoding")
+}
+
+// negotiateEncoding picks the preferred supported encoding from the given
+// Accept-Encoding header, or an empty string for identity.
+func negotiateEncoding(header string) string {
+	accepted := acceptedEncodings(header)
+	for _, enc := range encodings {
+		if accepts(accepted, enc.name) {
+			return enc.name
+		}
+	}
+	return ""
+}
+
+// acceptsEncoding tells whether the given Accept-Encoding header accepts the
+// given encoding.
+func acceptsEncoding(header, name string) bool {
+	return accepts(acceptedEncodings(header), name)
+}
+
+// acceptedEncodings parses the given Accept-Encoding header into whether
+// each of the encodings it lists is accepted.
+func acceptedEncodings(header string) map[string]bool {
+	accepted := map[string]bool{}
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				q = parsed
+			}
+		}
+		accepted[strings.ToLower(name)] = q > 0
+	}
+	return accepted
+}
+
+func accepts(accepted map[string]bool, name string) bool {
+	if ok, found := accepted[name]; found {
+		return ok
+	}
+	return accepted["*"]
+}
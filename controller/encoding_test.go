@@ -0,0 +1,113 @@
+package controller
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"github.com/matryer/is"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	for header, expected := range map[string]string{
+		"":                         "",
+		"identity":                 "",
+		"gzip":                     "gzip",
+		"gzip, deflate, br":        "br",
+		"gzip, deflate, br, zstd":  "zstd",
+		"zstd;q=0, br;q=0.5, gzip": "br",
+		"br;q=0, gzip;q=0.1":       "gzip",
+		"*":                        "zstd",
+		"*, zstd;q=0":              "br",
+	} {
+		t.Run(header, func(t *testing.T) {
+			is := is.New(t)
+			is.Equal(expected, negotiateEncoding(header))
+		})
+	}
+}
+
+func TestCompress(t *testing.T) {
+	decoders := map[string]func(r io.Reader) (io.Reader, error){
+		"zstd": func(r io.Reader) (io.Reader, error) {
+			return zstd.NewReader(r)
+		},
+		"br": func(r io.Reader) (io.Reader, error) {
+			return brotli.NewReader(r), nil
+		},
+		"gzip": func(r io.Reader) (io.Reader, error) {
+			return gzip.NewReader(r)
+		},
+	}
+	for _, enc := range encodings {
+		enc := enc
+		t.Run(enc.name, func(t *testing.T) {
+			// twice, so the second response may reuse the first encoder.
+			for _, body := range []string{"hello", "world"} {
+				is := is.New(t)
+				r := httptest.NewRequest("GET", "/test/test.json", nil)
+				r.Header.Set("Accept-Encoding", enc.name)
+				w := httptest.NewRecorder()
+				cw := compress(w, r)
+				_, err := io.WriteString(cw, body)
+				is.NoErr(err)
+				is.NoErr(cw.Close())
+				is.Equal(enc.name, w.Header().Get("content-encoding"))
+				is.Equal("Accept-Encoding", w.Header().Get("vary"))
+
+				dr, err := decoders[enc.name](w.Body)
+				is.NoErr(err)
+				bts, err := io.ReadAll(dr)
+				is.NoErr(err)
+				is.Equal(body, string(bts))
+			}
+		})
+	}
+}
+
+// BenchmarkEncodings reports the compressed size of a large timeline with
+// each supported encoding.
+func BenchmarkEncodings(b *testing.B) {
+	points := make([]Point, 0, 40000)
+	start := time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < cap(points); i++ {
+		points = append(points, Point{
+			Date:  start.Add(time.Duration(i) * 37 * time.Minute),
+			Stars: i + 1,
+		})
+	}
+	body, err := json.Marshal(points)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("identity", func(b *testing.B) {
+		b.ReportMetric(float64(len(body)), "bytes")
+	})
+	for _, enc := range encodings {
+		enc := enc
+		b.Run(enc.name, func(b *testing.B) {
+			var size int
+			for i := 0; i < b.N; i++ {
+				w := httptest.NewRecorder()
+				cw, err := enc.newWriter(w)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if _, err := cw.Write(body); err != nil {
+					b.Fatal(err)
+				}
+				if err := cw.Close(); err != nil {
+					b.Fatal(err)
+				}
+				size = w.Body.Len()
+			}
+			b.ReportMetric(float64(size), "bytes")
+		})
+	}
+}
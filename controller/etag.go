@@ -0,0 +1,44 @@
+package controller
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/caarlos0/starcharts/internal/github"
+)
+
+// chartEtag computes the etag of a chart from what it is rendered from: the
+// repository star count and the rendering parameters.
+//
+// It doesn't need the stargazers, so unchanged charts can be answered with a
+// 304 without fetching or rendering anything.
+func chartEtag(repo github.Repository, r *http.Request, format chartFormat, opts ChartOptions) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%d\n", repo.FullName, repo.StargazersCount)
+	fmt.Fprintf(h, "%s\n%+v\n", format.contentType, format.config)
+	fmt.Fprintf(h, "%s\n", r.URL.Query().Encode())
+	if opts.Goal > 0 || opts.ForecastDays > 0 {
+		// projections move as time goes by.
+		fmt.Fprintf(h, "%s\n", time.Now().UTC().Format("2006-01-02"))
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil))[:32] + `"`
+}
+
+// etagMatches tells whether the given If-None-Match header matches the etag,
+// using the weak comparison.
+func etagMatches(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+package controller
+
+import (
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/caarlos0/starcharts/internal/overrides"
+	"github.com/gorilla/mux"
+)
+
+// RepoFilter decides which repositories can be charted, based on glob
+// patterns on owner/repo, e.g. myorg/*.
+type RepoFilter struct {
+	allow     []string
+	block     []string
+	overrides *overrides.Overrides
+}
+
+// NewRepoFilter creates a new RepoFilter.
+// Blocked repositories are always rejected, and if the allowlist isn't empty,
+// only repositories matching it are accepted.
+func NewRepoFilter(allow, block []string) RepoFilter {
+	return RepoFilter{
+		allow: normalizePatterns(allow),
+		block: normalizePatterns(block),
+	}
+}
+
+// WithOverrides returns a copy of the filter that lets the access of the
+// given repo overrides take precedence over the lists.
+func (f RepoFilter) WithOverrides(o *overrides.Overrides) RepoFilter {
+	f.overrides = o
+	return f
+}
+
+func normalizePatterns(patterns []string) []string {
+	var result []string
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if pattern != "" {
+			result = append(result, pattern)
+		}
+	}
+	return result
+}
+
+// Allowed tells whether the given normalized owner/repo name can be charted.
+func (f RepoFilter) Allowed(name string) bool {
+	switch f.overrides.For(name).Access {
+	case overrides.Allow:
+		return true
+	case overrides.Deny:
+		return false
+	}
+	if matchAny(f.block, name) {
+		return false
+	}
+	return len(f.allow) == 0 || matchAny(f.allow, name)
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// FilterRepos rejects requests for repositories the filter doesn't allow
+// with a 403, before the handler hits the api.
+// Both the owner/repo path parameters and the repos query parameter are
+// checked; invalid names are left for the handler to reject.
+func FilterRepos(filter RepoFilter, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var names []string
+		if vars := mux.Vars(r); vars["owner"] != "" {
+			if name, err := repoName(r); err == nil {
+				names = append(names, name)
+			}
+		}
+		if r.URL.Query().Get("repos") != "" {
+			if compared, err := compareRepoNames(r); err == nil {
+				names = append(names, compared...)
+			}
+		}
+		for _, name := range names {
+			if !filter.Allowed(name) {
+				http.Error(w, "repository not allowed: "+name, http.StatusForbidden)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
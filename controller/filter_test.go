@@ -0,0 +1,96 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/caarlos0/starcharts/internal/overrides"
+	"github.com/gorilla/mux"
+	"github.com/matryer/is"
+)
+
+func TestRepoFilter(t *testing.T) {
+	for name, tt := range map[string]struct {
+		filter  RepoFilter
+		allowed []string
+		blocked []string
+	}{
+		"no lists": {
+			filter:  NewRepoFilter(nil, nil),
+			allowed: []string{"caarlos0/starcharts", "foo/bar"},
+		},
+		"allow only": {
+			filter:  NewRepoFilter([]string{"caarlos0/*", "goreleaser/goreleaser"}, nil),
+			allowed: []string{"caarlos0/starcharts", "caarlos0/env", "goreleaser/goreleaser"},
+			blocked: []string{"foo/bar", "goreleaser/nfpm"},
+		},
+		"block only": {
+			filter:  NewRepoFilter(nil, []string{"spam/*", "foo/bar"}),
+			allowed: []string{"caarlos0/starcharts", "foo/baz"},
+			blocked: []string{"spam/anything", "foo/bar"},
+		},
+		"combined": {
+			filter:  NewRepoFilter([]string{"Caarlos0/*"}, []string{"caarlos0/secret"}),
+			allowed: []string{"caarlos0/starcharts"},
+			blocked: []string{"caarlos0/secret", "foo/bar"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			is := is.New(t)
+			for _, repo := range tt.allowed {
+				is.True(tt.filter.Allowed(repo)) // should be allowed
+			}
+			for _, repo := range tt.blocked {
+				is.True(!tt.filter.Allowed(repo)) // should be blocked
+			}
+		})
+	}
+}
+
+func TestRepoFilterOverrides(t *testing.T) {
+	is := is.New(t)
+	repoOverrides, err := overrides.Parse(strings.NewReader(`[
+		{"repo": "myorg/*", "access": "allow"},
+		{"repo": "myorg/secret", "access": "deny"},
+		{"repo": "myorg/pages", "max_pages": 10}
+	]`))
+	is.NoErr(err)
+	filter := NewRepoFilter([]string{"caarlos0/*"}, []string{"myorg/blocked", "caarlos0/blocked"}).
+		WithOverrides(repoOverrides)
+
+	is.True(filter.Allowed("caarlos0/starcharts"))  // should follow the allowlist
+	is.True(!filter.Allowed("caarlos0/blocked"))    // should follow the blocklist
+	is.True(!filter.Allowed("foo/bar"))             // should follow the allowlist
+	is.True(filter.Allowed("myorg/anything"))       // override should win over the allowlist
+	is.True(filter.Allowed("myorg/blocked"))        // override should win over the blocklist
+	is.True(filter.Allowed("myorg/pages"))          // later rules should keep the access they don't set
+	is.True(!filter.Allowed("myorg/secret"))        // later rules should win
+	is.True(NewRepoFilter(nil, nil).Allowed("a/b")) // should work without overrides
+}
+
+func TestFilterRepos(t *testing.T) {
+	filter := NewRepoFilter(nil, []string{"spam/*"})
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	for path, tt := range map[string]struct {
+		vars   map[string]string
+		status int
+	}{
+		"/caarlos0/starcharts.svg":          {map[string]string{"owner": "caarlos0", "repo": "starcharts"}, http.StatusTeapot},
+		"/Spam/Repo.svg":                    {map[string]string{"owner": "Spam", "repo": "Repo"}, http.StatusForbidden},
+		"/compare.svg?repos=a/b,spam/repo":  {nil, http.StatusForbidden},
+		"/compare.svg?repos=a/b,caarlos0/c": {nil, http.StatusTeapot},
+	} {
+		t.Run(path, func(t *testing.T) {
+			is := is.New(t)
+			r := mux.SetURLVars(httptest.NewRequest(http.MethodGet, path, nil), tt.vars)
+			w := httptest.NewRecorder()
+			FilterRepos(filter, next).ServeHTTP(w, r)
+			is.Equal(tt.status, w.Code)
+		})
+	}
+}
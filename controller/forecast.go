@@ -0,0 +1,169 @@
+package controller
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/caarlos0/starcharts/internal/github"
+	chart "github.com/wcharczuk/go-chart"
+	"github.com/wcharczuk/go-chart/drawing"
+	"github.com/wcharczuk/go-chart/util"
+)
+
+const (
+	// forecastWindow is how far back we look to fit the growth trend used to
+	// project the star count.
+	forecastWindow = 90
+	// minForecastStars is the minimum amount of stars in the forecast window
+	// needed to project the star count.
+	minForecastStars = 10
+	// maxForecastDays bounds the forecast query parameter.
+	maxForecastDays = 365
+)
+
+// forecast is a linear fit of the daily cumulative star count.
+type forecast struct {
+	perDay float64
+	// stdErr is the standard error of perDay.
+	stdErr float64
+}
+
+// addForecast draws a dashed projection of the star count for the given
+// amount of days, with a shaded band of the likely range.
+func addForecast(graph *chart.Chart, stargazers []github.Stargazer, baseline, days int, color drawing.Color) {
+	if days < 1 {
+		return
+	}
+	now := time.Now()
+	fit, ok := fitForecast(stargazers, now)
+	if !ok {
+		return
+	}
+
+	current := float64(baseline + len(stargazers))
+	band := forecastBand{color: color}
+	projection := chart.TimeSeries{
+		Name: "Projection",
+		Style: chart.Style{
+			Show:            true,
+			StrokeColor:     color,
+			StrokeWidth:     2,
+			StrokeDashArray: []float64{5, 5},
+		},
+	}
+	for day := 0; day <= days; day++ {
+		x := now.Add(time.Duration(day) * 24 * time.Hour)
+		d := float64(day)
+		projection.XValues = append(projection.XValues, x)
+		projection.YValues = append(projection.YValues, current+fit.perDay*d)
+		band.x = append(band.x, util.Time.ToFloat64(x))
+		band.upper = append(band.upper, current+(fit.perDay+2*fit.stdErr)*d)
+		band.lower = append(band.lower, current+math.Max(0, fit.perDay-2*fit.stdErr)*d)
+	}
+
+	last := len(projection.XValues) - 1
+	graph.Series = append(
+		graph.Series,
+		band,
+		projection,
+		chart.AnnotationSeries{
+			Annotations: []chart.Value2{{
+				XValue: band.x[last],
+				YValue: projection.YValues[last],
+				Label:  fmt.Sprintf("Projection: %.0f", projection.YValues[last]),
+			}},
+		},
+	)
+}
+
+// forecastDays parses the forecast query parameter, either as a number of
+// days or with a d suffix, clamping it to maxForecastDays.
+func forecastDays(value string) (int, bool) {
+	days, err := strconv.Atoi(strings.TrimSuffix(value, "d"))
+	if err != nil || days < 1 {
+		return 0, false
+	}
+	if days > maxForecastDays {
+		return maxForecastDays, true
+	}
+	return days, true
+}
+
+// fitForecast fits a line to the daily cumulative star count of the last
+// forecastWindow days, using least squares.
+// It returns false if there isn't enough recent data to extrapolate.
+func fitForecast(stargazers []github.Stargazer, now time.Time) (forecast, bool) {
+	start := now.Add(-forecastWindow * 24 * time.Hour)
+	counts := make([]float64, forecastWindow+1)
+	var recent int
+	for _, star := range stargazers {
+		if star.StarredAt.Before(start) || star.StarredAt.After(now) {
+			continue
+		}
+		recent++
+		counts[int(star.StarredAt.Sub(start).Hours()/24)]++
+	}
+	if recent < minForecastStars {
+		return forecast{}, false
+	}
+	for i := 1; i < len(counts); i++ {
+		counts[i] += counts[i-1]
+	}
+
+	n := float64(len(counts))
+	var sumX, sumY float64
+	for x, y := range counts {
+		sumX += float64(x)
+		sumY += y
+	}
+	meanX, meanY := sumX/n, sumY/n
+	var sxx, sxy float64
+	for x, y := range counts {
+		sxx += (float64(x) - meanX) * (float64(x) - meanX)
+		sxy += (float64(x) - meanX) * (y - meanY)
+	}
+	slope := sxy / sxx
+	intercept := meanY - slope*meanX
+
+	var sse float64
+	for x, y := range counts {
+		residual := y - (intercept + slope*float64(x))
+		sse += residual * residual
+	}
+	return forecast{
+		perDay: slope,
+		stdErr: math.Sqrt(sse / (n - 2) / sxx),
+	}, true
+}
+
+// forecastBand is a shaded band between the lower and upper projections.
+type forecastBand struct {
+	x, lower, upper []float64
+	color           drawing.Color
+}
+
+func (b forecastBand) GetName() string           { return "Projection range" }
+func (b forecastBand) GetYAxis() chart.YAxisType { return chart.YAxisPrimary }
+func (b forecastBand) Validate() error           { return nil }
+
+func (b forecastBand) GetStyle() chart.Style {
+	return chart.Style{
+		Show:        true,
+		StrokeWidth: 1,
+		StrokeColor: b.color.WithAlpha(64),
+		FillColor:   b.color.WithAlpha(48),
+	}
+}
+
+func (b forecastBand) Len() int { return len(b.x) }
+
+func (b forecastBand) GetBoundedValues(index int) (x, y1, y2 float64) {
+	return b.x[index], b.upper[index], b.lower[index]
+}
+
+func (b forecastBand) Render(r chart.Renderer, canvasBox chart.Box, xrange, yrange chart.Range, defaults chart.Style) {
+	chart.Draw.BoundedSeries(r, canvasBox, xrange, yrange, b.GetStyle().InheritFrom(defaults), b)
+}
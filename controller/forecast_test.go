@@ -0,0 +1,53 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/caarlos0/starcharts/internal/github"
+	"github.com/matryer/is"
+)
+
+func TestForecastDays(t *testing.T) {
+	for value, expected := range map[string]int{
+		"30d":  30,
+		"7":    7,
+		"999d": maxForecastDays,
+		"":     0,
+		"0d":   0,
+		"-3d":  0,
+		"abc":  0,
+	} {
+		t.Run(value, func(t *testing.T) {
+			is := is.New(t)
+			days, ok := forecastDays(value)
+			is.Equal(expected > 0, ok)
+			is.Equal(expected, days)
+		})
+	}
+}
+
+func TestFitForecast(t *testing.T) {
+	now := time.Date(2023, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	t.Run("steady growth", func(t *testing.T) {
+		is := is.New(t)
+		// two stars per day for the whole window.
+		var stargazers []github.Stargazer
+		for i := forecastWindow * 2; i > 0; i-- {
+			stargazers = append(stargazers, github.Stargazer{
+				StarredAt: now.Add(-time.Duration(i) * 12 * time.Hour).Add(time.Minute),
+			})
+		}
+		fit, ok := fitForecast(stargazers, now)
+		is.True(ok)                                   // should fit
+		is.True(fit.perDay > 1.9 && fit.perDay < 2.1) // should be about 2 stars per day
+		is.True(fit.stdErr < 0.1)                     // should be confident
+	})
+
+	t.Run("not enough recent data", func(t *testing.T) {
+		is := is.New(t)
+		_, ok := fitForecast([]github.Stargazer{{StarredAt: now.Add(-time.Hour)}}, now)
+		is.True(!ok) // should not fit
+	})
+}
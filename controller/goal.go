@@ -0,0 +1,90 @@
+package controller
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/caarlos0/starcharts/internal/github"
+	chart "github.com/wcharczuk/go-chart"
+	"github.com/wcharczuk/go-chart/drawing"
+	"github.com/wcharczuk/go-chart/util"
+)
+
+const (
+	// goalWindow is how far back we look to compute the growth rate used to
+	// project when a goal will be reached.
+	goalWindow = 30 * 24 * time.Hour
+	// minGoalWindowStars is the minimum amount of stars in the goal window
+	// needed to project when a goal will be reached.
+	minGoalWindowStars = 10
+	// maxGoalDays is how far ahead a goal can be projected, anything further
+	// is considered out of reach.
+	maxGoalDays = 100 * 365
+)
+
+// addGoal draws the given goal as a dashed horizontal line, labeled with the
+// projected date to reach it, if possible.
+func addGoal(graph *chart.Chart, stargazers []github.Stargazer, baseline, goal int) {
+	if goal < 1 {
+		return
+	}
+	series, ok := graph.Series[0].(chart.TimeSeries)
+	if !ok || len(series.XValues) == 0 {
+		return
+	}
+	first := series.XValues[0]
+	last := series.XValues[len(series.XValues)-1]
+
+	label := fmt.Sprintf("Goal: %d", goal)
+	if date, ok := projectGoal(stargazers, baseline+len(stargazers), goal, time.Now()); ok {
+		label = fmt.Sprintf("Goal: %d (est. %s)", goal, date.Format("Jan 2006"))
+	}
+
+	graph.Series = append(
+		graph.Series,
+		chart.TimeSeries{
+			Style: chart.Style{
+				Show:            true,
+				StrokeColor:     drawing.ColorFromHex("f0ad4e"),
+				StrokeWidth:     1,
+				StrokeDashArray: []float64{5, 5},
+			},
+			XValues: []time.Time{first, last},
+			YValues: []float64{float64(goal), float64(goal)},
+		},
+		chart.AnnotationSeries{
+			Annotations: []chart.Value2{{
+				XValue: util.Time.ToFloat64(last),
+				YValue: float64(goal),
+				Label:  label,
+			}},
+		},
+	)
+}
+
+// projectGoal projects when the goal will be reached, based on the growth
+// rate of the stars in the goal window.
+// It returns false if the goal was already reached, is too far away, or there
+// isn't enough recent data to extrapolate.
+func projectGoal(stargazers []github.Stargazer, current, goal int, now time.Time) (time.Time, bool) {
+	if current >= goal {
+		return time.Time{}, false
+	}
+	var recent int
+	for i := len(stargazers) - 1; i >= 0; i-- {
+		if stargazers[i].StarredAt.Before(now.Add(-goalWindow)) {
+			break
+		}
+		recent++
+	}
+	if recent < minGoalWindowStars {
+		return time.Time{}, false
+	}
+	perDay := float64(recent) / goalWindow.Hours() * 24
+	days := math.Ceil(float64(goal-current) / perDay)
+	if days > maxGoalDays {
+		return time.Time{}, false
+	}
+	return now.Add(time.Duration(days) * 24 * time.Hour), true
+}
@@ -0,0 +1,47 @@
+package controller
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/caarlos0/starcharts/internal/github"
+	"github.com/matryer/is"
+)
+
+func TestProjectGoal(t *testing.T) {
+	now := time.Date(2023, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	// one star per day for the last 30 days.
+	var stargazers []github.Stargazer
+	for i := 30; i > 0; i-- {
+		stargazers = append(stargazers, github.Stargazer{
+			StarredAt: now.Add(-time.Duration(i) * 24 * time.Hour),
+		})
+	}
+
+	t.Run("projects", func(t *testing.T) {
+		is := is.New(t)
+		date, ok := projectGoal(stargazers, 100, 110, now)
+		is.True(ok) // should project
+		is.Equal(now.Add(10*24*time.Hour), date)
+	})
+
+	t.Run("already reached", func(t *testing.T) {
+		is := is.New(t)
+		_, ok := projectGoal(stargazers, 100, 100, now)
+		is.True(!ok) // should not project
+	})
+
+	t.Run("out of reach", func(t *testing.T) {
+		is := is.New(t)
+		_, ok := projectGoal(stargazers, 100, math.MaxInt32, now)
+		is.True(!ok) // should not project
+	})
+
+	t.Run("not enough recent data", func(t *testing.T) {
+		is := is.New(t)
+		_, ok := projectGoal(stargazers, 100, 110, now.Add(25*24*time.Hour))
+		is.True(!ok) // should not project
+	})
+}
@@ -0,0 +1,90 @@
+package controller
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/caarlos0/httperr"
+	"github.com/caarlos0/starcharts/internal/github"
+)
+
+// growthPeriods are the periods supported by the period query parameter.
+// nolint: gochecknoglobals
+var growthPeriods = map[string]func(time.Time) time.Time{
+	"7d":  func(t time.Time) time.Time { return t.AddDate(0, 0, -7) },
+	"30d": func(t time.Time) time.Time { return t.AddDate(0, 0, -30) },
+	"1y":  func(t time.Time) time.Time { return t.AddDate(-1, 0, 0) },
+}
+
+// growthStats compares the stars gained in the current period with the ones
+// gained in the previous period of the same length.
+type growthStats struct {
+	Period   string `json:"period"`
+	Current  int    `json:"current"`
+	Previous int    `json:"previous"`
+	// Change is the percentage change from the previous period, or nil if
+	// the previous period had no stars.
+	Change *float64 `json:"change_pct"`
+}
+
+// GetGrowth returns the stars the given repository gained in the period given
+// in the period query parameter (7d, 30d or 1y, 30d by default), compared
+// with the previous period.
+func GetGrowth(gh *github.GitHub) http.Handler {
+	return httperr.NewF(func(w http.ResponseWriter, r *http.Request) error {
+		name, err := repoName(r)
+		if err != nil {
+			return err
+		}
+		period := r.URL.Query().Get("period")
+		if period == "" {
+			period = "30d"
+		}
+		if _, ok := growthPeriods[period]; !ok {
+			return httperr.Errorf(http.StatusBadRequest, "invalid period, expected 7d, 30d or 1y: %q", period)
+		}
+
+		log := log.WithField("repo", name)
+		defer log.Trace("collect_stars").Stop(nil)
+		repo, err := gh.RepoDetails(r.Context(), name)
+		if err != nil {
+			return fetchErr(w, err, http.StatusBadRequest)
+		}
+		stargazers, err := gh.Stargazers(r.Context(), repo)
+		if err != nil {
+			log.WithError(err).Error("failed to get stars")
+			return fetchErr(w, err, http.StatusInternalServerError)
+		}
+
+		w.Header().Add("content-type", "application/json")
+		w.Header().Add("cache-control", "public, max-age=3600")
+		return json.NewEncoder(w).Encode(growth(stargazers, period, time.Now()))
+	})
+}
+
+// growth computes the growth stats of the given sorted stargazers for the
+// given period, ending now.
+func growth(stargazers []github.Stargazer, period string, now time.Time) growthStats {
+	start := growthPeriods[period](now)
+	// the previous period has the same length as the current one.
+	previousStart := start.Add(-now.Sub(start))
+
+	total := starsBefore(stargazers, now)
+	atStart := starsBefore(stargazers, start)
+	atPreviousStart := starsBefore(stargazers, previousStart)
+
+	stats := growthStats{
+		Period:   period,
+		Current:  total - atStart,
+		Previous: atStart - atPreviousStart,
+	}
+	if stats.Previous > 0 {
+		change := float64(stats.Current-stats.Previous) * 100 / float64(stats.Previous)
+		change = math.Round(change*10) / 10
+		stats.Change = &change
+	}
+	return stats
+}
@@ -0,0 +1,49 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/caarlos0/starcharts/internal/github"
+	"github.com/matryer/is"
+)
+
+func TestGrowth(t *testing.T) {
+	now := time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC)
+	daysAgo := func(d int) github.Stargazer {
+		return github.Stargazer{StarredAt: now.AddDate(0, 0, -d)}
+	}
+	stargazers := []github.Stargazer{
+		daysAgo(400),
+		daysAgo(12),
+		daysAgo(10),
+		daysAgo(9),
+		daysAgo(5),
+		daysAgo(3),
+		daysAgo(1),
+	}
+
+	t.Run("7d", func(t *testing.T) {
+		is := is.New(t)
+		stats := growth(stargazers, "7d", now)
+		is.Equal(3, stats.Current)
+		is.Equal(3, stats.Previous)
+		is.Equal(0.0, *stats.Change)
+	})
+
+	t.Run("30d", func(t *testing.T) {
+		is := is.New(t)
+		stats := growth(stargazers, "30d", now)
+		is.Equal(6, stats.Current)
+		is.Equal(0, stats.Previous)
+		is.True(stats.Change == nil) // should not divide by zero
+	})
+
+	t.Run("1y", func(t *testing.T) {
+		is := is.New(t)
+		stats := growth(stargazers, "1y", now)
+		is.Equal(6, stats.Current)
+		is.Equal(1, stats.Previous)
+		is.Equal(500.0, *stats.Change)
+	})
+}
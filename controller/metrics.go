@@ -0,0 +1,56 @@
+package controller
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/caarlos0/httperr"
+	"github.com/caarlos0/starcharts/internal/github"
+)
+
+// GetRepoMetrics returns the star count of every cached repository in the
+// prometheus text exposition format, for a dedicated scrape job.
+//
+// Counts come from the cache only, github is never hit, but listing them
+// scans the cache keyspace, so it is guarded like the admin endpoints: the
+// scrape job must send the admin secret as a bearer token.
+func GetRepoMetrics(gh *github.GitHub) http.Handler {
+	return httperr.NewF(func(w http.ResponseWriter, r *http.Request) error {
+		repos, err := gh.CachedRepoStars()
+		if err != nil {
+			return err
+		}
+		w.Header().Add("content-type", "text/plain; version=0.0.4; charset=utf-8")
+		bw := bufio.NewWriter(w)
+		writeRepoMetrics(bw, repos)
+		return bw.Flush()
+	})
+}
+
+func writeRepoMetrics(w *bufio.Writer, repos []github.CachedRepo) {
+	fmt.Fprintln(w, "# HELP starcharts_repo_stars Current star count of the repository, as cached.")
+	fmt.Fprintln(w, "# TYPE starcharts_repo_stars gauge")
+	for _, repo := range repos {
+		owner, name, ok := strings.Cut(repo.Name, "/")
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(
+			w,
+			"starcharts_repo_stars{owner=\"%s\",repo=\"%s\"} %d\n",
+			escapeLabel(owner),
+			escapeLabel(name),
+			repo.Stars,
+		)
+	}
+}
+
+// nolint: gochecknoglobals
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// escapeLabel escapes a label value as the exposition format requires.
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
@@ -0,0 +1,27 @@
+package controller
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+
+	"github.com/caarlos0/starcharts/internal/github"
+	"github.com/matryer/is"
+)
+
+func TestWriteRepoMetrics(t *testing.T) {
+	is := is.New(t)
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+	writeRepoMetrics(w, []github.CachedRepo{
+		{Name: "caarlos0/starcharts", Stars: 1000},
+		{Name: "foo/bar\"baz", Stars: 2},
+		{Name: "invalid", Stars: 3},
+	})
+	is.NoErr(w.Flush())
+	is.Equal(`# HELP starcharts_repo_stars Current star count of the repository, as cached.
+# TYPE starcharts_repo_stars gauge
+starcharts_repo_stars{owner="caarlos0",repo="starcharts"} 1000
+starcharts_repo_stars{owner="foo",repo="bar\"baz"} 2
+`, buf.String())
+}
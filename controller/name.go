@@ -0,0 +1,48 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/caarlos0/httperr"
+	"github.com/gorilla/mux"
+)
+
+var (
+	// github logins are alphanumeric with single hyphens in between.
+	ownerRe = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+	// github repository names are made of alphanumerics, '-', '_' and '.'.
+	repoRe = regexp.MustCompile(`^[a-z0-9._-]+$`)
+)
+
+const (
+	maxOwnerLen = 39
+	maxRepoLen  = 100
+)
+
+// repoName returns the normalized owner/repo name from the request path
+// parameters, or a 400 error if they are not a valid github repository.
+func repoName(r *http.Request) (string, error) {
+	return normalizeRepoName(mux.Vars(r)["owner"], mux.Vars(r)["repo"])
+}
+
+// normalizeRepoName returns the normalized owner/repo name, or a 400 error if
+// they are not a valid github repository.
+//
+// The name is lowercased and the .git suffix is dropped, so the same
+// repository always ends up with the same cache keys.
+func normalizeRepoName(owner, repo string) (string, error) {
+	owner = strings.ToLower(strings.TrimSpace(owner))
+	repo = strings.ToLower(strings.TrimSpace(repo))
+	repo = strings.TrimSuffix(repo, ".git")
+
+	if len(owner) > maxOwnerLen || !ownerRe.MatchString(owner) {
+		return "", httperr.Wrap(fmt.Errorf("invalid repository owner: %q", owner), http.StatusBadRequest)
+	}
+	if len(repo) > maxRepoLen || !repoRe.MatchString(repo) || repo == "." || repo == ".." {
+		return "", httperr.Wrap(fmt.Errorf("invalid repository name: %q", repo), http.StatusBadRequest)
+	}
+	return owner + "/" + repo, nil
+}
@@ -0,0 +1,57 @@
+package controller
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/matryer/is"
+)
+
+func TestRepoName(t *testing.T) {
+	for _, tt := range []struct {
+		owner, repo string
+		expected    string
+	}{
+		{"caarlos0", "starcharts", "caarlos0/starcharts"},
+		{"Caarlos0", "StarCharts", "caarlos0/starcharts"},
+		{"caarlos0", "starcharts.git", "caarlos0/starcharts"},
+		{"some-org", "some_repo.go", "some-org/some_repo.go"},
+	} {
+		t.Run(tt.owner+"/"+tt.repo, func(t *testing.T) {
+			is := is.New(t)
+			r := mux.SetURLVars(httptest.NewRequest("GET", "/", nil), map[string]string{
+				"owner": tt.owner,
+				"repo":  tt.repo,
+			})
+			name, err := repoName(r)
+			is.NoErr(err)
+			is.Equal(tt.expected, name)
+		})
+	}
+}
+
+func TestRepoNameInvalid(t *testing.T) {
+	for _, tt := range []struct {
+		owner, repo string
+	}{
+		{"", "starcharts"},
+		{"caarlos0", ""},
+		{"-caarlos0", "starcharts"},
+		{"caarlos0", "star charts"},
+		{"caarlos0", "star%2Fcharts"},
+		{"caarlos0", ".."},
+		{"caarlos0", ".git"},
+		{"caarlos0", "<script>"},
+	} {
+		t.Run(tt.owner+"/"+tt.repo, func(t *testing.T) {
+			is := is.New(t)
+			r := mux.SetURLVars(httptest.NewRequest("GET", "/", nil), map[string]string{
+				"owner": tt.owner,
+				"repo":  tt.repo,
+			})
+			_, err := repoName(r)
+			is.True(err != nil) // should have errored
+		})
+	}
+}
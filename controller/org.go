@@ -0,0 +1,86 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/caarlos0/httperr"
+	"github.com/caarlos0/starcharts/internal/github"
+	"github.com/gorilla/mux"
+)
+
+// maxOrgRepos bounds how many repositories of an organization are charted,
+// the most starred ones are picked.
+const maxOrgRepos = 20
+
+// GetOrgChart returns a SVG chart of the stars of all public repositories of
+// the given organization combined.
+//
+// Repositories the filter doesn't allow are left out.
+func GetOrgChart(gh *github.GitHub, filter RepoFilter, config ChartConfig) http.Handler {
+	return httperr.NewF(func(w http.ResponseWriter, r *http.Request) error {
+		org, err := orgName(r)
+		if err != nil {
+			return err
+		}
+		if _, _, err := axisRange(r); err != nil {
+			return err
+		}
+		if _, err := parseBands(r); err != nil {
+			return err
+		}
+		log := log.WithField("org", org)
+		defer log.Trace("collect_stars").Stop(nil)
+
+		all, err := gh.OrgRepos(r.Context(), org)
+		if err != nil {
+			return fetchErr(w, err, http.StatusBadRequest)
+		}
+		var repos []github.Repository
+		for _, repo := range all {
+			if len(repos) == maxOrgRepos {
+				break
+			}
+			if filter.Allowed(strings.ToLower(repo.FullName)) {
+				repos = append(repos, repo)
+			}
+		}
+		if len(repos) == 0 {
+			return httperr.Errorf(http.StatusNotFound, "no starred public repositories found for %q", org)
+		}
+
+		stargazers, err := gh.AggregateStargazers(r.Context(), repos)
+		if err != nil {
+			log.WithError(err).Error("failed to get stars")
+			return fetchErr(w, err, http.StatusInternalServerError)
+		}
+
+		w.Header().Add("content-type", "image/svg+xml;charset=utf-8")
+		w.Header().Add("cache-control", "public, max-age=86400")
+		w.Header().Add("date", time.Now().Format(time.RFC1123))
+		w.Header().Add("expires", time.Now().Format(time.RFC1123))
+
+		opts := chartOptions(r, chartFormat{config: config}, 0)
+		opts.Name = org
+		setDownsampled(w, len(stargazers), opts.MaxPoints)
+		defer log.Trace("chart").Stop(&err)
+		if err := WriteChart(w, stargazers, opts); err != nil {
+			log.WithError(err).Error("failed to render graph")
+			return err
+		}
+		return nil
+	})
+}
+
+// orgName returns the normalized organization name from the request path
+// parameters, or a 400 error if it is not a valid github login.
+func orgName(r *http.Request) (string, error) {
+	org := strings.ToLower(strings.TrimSpace(mux.Vars(r)["org"]))
+	if len(org) > maxOwnerLen || !ownerRe.MatchString(org) {
+		return "", httperr.Wrap(fmt.Errorf("invalid organization: %q", org), http.StatusBadRequest)
+	}
+	return org, nil
+}
@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"time"
+)
+
+// maxHourBuckets bounds how many hours stars are bucketed in, about a month,
+// longer spans falling back to days so charts and timelines don't get huge.
+const maxHourBuckets = 31 * 24
+
+// parsePer parses the per query parameter, the period stars are bucketed in:
+// hour or day. Anything else is zero.
+func parsePer(value string) time.Duration {
+	switch value {
+	case "hour":
+		return time.Hour
+	case "day":
+		return barPeriod
+	default:
+		return 0
+	}
+}
+
+// periodName is the name of the given period, as in the per query parameter.
+func periodName(period time.Duration) string {
+	if period == time.Hour {
+		return "hour"
+	}
+	return "day"
+}
+
+// bucketPeriod returns the given period to bucket the stars of the span
+// [min, max] in, or a day if that would make more than maxHourBuckets
+// buckets. Zero picks a day too.
+func bucketPeriod(per time.Duration, min, max time.Time) time.Duration {
+	if per <= 0 || per >= barPeriod || max.Sub(min)/per >= maxHourBuckets {
+		return barPeriod
+	}
+	return per
+}
+
+// pointsPeriod returns the period to bucket the given sorted points in.
+func pointsPeriod(per time.Duration, points []Point) time.Duration {
+	if len(points) == 0 {
+		return bucketPeriod(per, time.Time{}, time.Time{})
+	}
+	return bucketPeriod(per, points[0].Date, points[len(points)-1].Date)
+}
+
+// bucketPoints returns the cumulative star count of each bucket of the given
+// period with stars, dated at the start of the bucket, from the given sorted
+// points.
+func bucketPoints(points []Point, period time.Duration) []Point {
+	var buckets []Point
+	for _, p := range points {
+		start := p.Date.UTC().Truncate(period)
+		if len(buckets) > 0 && buckets[len(buckets)-1].Date.Equal(start) {
+			buckets[len(buckets)-1].Stars = p.Stars
+			continue
+		}
+		buckets = append(buckets, Point{Date: start, Stars: p.Stars})
+	}
+	return buckets
+}
@@ -0,0 +1,181 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis"
+	"github.com/apex/log"
+	"github.com/caarlos0/starcharts/internal/cache"
+	"github.com/caarlos0/starcharts/internal/github"
+	"github.com/go-redis/redis"
+	"github.com/gorilla/mux"
+	"github.com/matryer/is"
+	"gopkg.in/h2non/gock.v1"
+)
+
+// burst is a viral launch: a few stars a day, then hundreds within a couple
+// of hours.
+func burst() []github.Stargazer {
+	launch := time.Date(2023, 5, 10, 14, 0, 0, 0, time.UTC)
+	var stars []github.Stargazer
+	for i := 3; i > 0; i-- {
+		stars = append(stars, github.Stargazer{StarredAt: launch.AddDate(0, 0, -i)})
+	}
+	for i := 0; i < 300; i++ {
+		// 200 stars in the first hour, 100 in the next.
+		stars = append(stars, github.Stargazer{StarredAt: launch.Add(time.Duration(i) * 18 * time.Second)})
+	}
+	stars = append(stars, github.Stargazer{StarredAt: launch.Add(5 * time.Hour)})
+	return stars
+}
+
+func TestParsePer(t *testing.T) {
+	is := is.New(t)
+	is.Equal(time.Hour, parsePer("hour"))
+	is.Equal(24*time.Hour, parsePer("day"))
+	is.Equal(time.Duration(0), parsePer(""))
+	is.Equal(time.Duration(0), parsePer("minute"))
+}
+
+func TestBucketPeriod(t *testing.T) {
+	start := time.Date(2023, 5, 1, 0, 0, 0, 0, time.UTC)
+	for name, tt := range map[string]struct {
+		per      time.Duration
+		span     time.Duration
+		expected time.Duration
+	}{
+		"default":          {0, time.Hour, barPeriod},
+		"day":              {barPeriod, time.Hour, barPeriod},
+		"hour":             {time.Hour, 10 * 24 * time.Hour, time.Hour},
+		"hour, just fits":  {time.Hour, (maxHourBuckets - 1) * time.Hour, time.Hour},
+		"hour, too long":   {time.Hour, maxHourBuckets * time.Hour, barPeriod},
+		"hour, many years": {time.Hour, 5 * 365 * 24 * time.Hour, barPeriod},
+	} {
+		t.Run(name, func(t *testing.T) {
+			is := is.New(t)
+			is.Equal(tt.expected, bucketPeriod(tt.per, start, start.Add(tt.span)))
+		})
+	}
+}
+
+func TestBucketPoints(t *testing.T) {
+	is := is.New(t)
+	points := timelinePoints(burst(), 10)
+
+	hourly := bucketPoints(points, time.Hour)
+	is.Equal(len(hourly), 6)
+	launch := time.Date(2023, 5, 10, 14, 0, 0, 0, time.UTC)
+	is.Equal(hourly[3], Point{Date: launch, Stars: 213})
+	is.Equal(hourly[4], Point{Date: launch.Add(time.Hour), Stars: 313})
+	is.Equal(hourly[5], Point{Date: launch.Add(5 * time.Hour), Stars: 314})
+
+	daily := bucketPoints(points, barPeriod)
+	is.Equal(len(daily), 4)
+	is.Equal(daily[3], Point{Date: launch.Truncate(barPeriod), Stars: 314}) // the burst is hidden in a single day
+
+	is.Equal(len(bucketPoints(nil, time.Hour)), 0)
+}
+
+func TestBarCountsHourly(t *testing.T) {
+	is := is.New(t)
+	points := timelinePoints(burst(), 0)
+	starts, counts := barCounts(points, 0, time.Hour, keepWeekends)
+	is.Equal(len(starts), 6)
+	is.Equal(counts, []float64{1, 1, 1, 200, 100, 1})
+
+	// weekend stars carried to monday land in its first hour.
+	saturday := time.Date(2023, 5, 13, 15, 0, 0, 0, time.UTC)
+	starts, counts = barCounts([]Point{
+		{Date: saturday, Stars: 1},
+		{Date: saturday.AddDate(0, 0, 2), Stars: 2},
+	}, 0, time.Hour, carryWeekends)
+	monday := time.Date(2023, 5, 15, 0, 0, 0, 0, time.UTC)
+	is.Equal(starts, []time.Time{monday, monday.Add(15 * time.Hour)})
+	is.Equal(counts, []float64{1, 1})
+}
+
+func TestAddDailyBarsHourly(t *testing.T) {
+	is := is.New(t)
+	points := timelinePoints(burst(), 0)
+	graph := buildGraph(log.Log, points, 0, lineColor)
+	applyTicks(&graph, 0, 0)
+	addDailyBars(&graph, points, 0, 0, time.Hour, keepWeekends, lineColor)
+	is.Equal(graph.YAxis.Name, "New stars per hour")
+}
+
+func TestRepoJSONPer(t *testing.T) {
+	defer gock.Off()
+	mr, _ := miniredis.Run()
+	rc := redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	})
+	cache := cache.New(rc)
+	defer cache.Close()
+	gh := github.New(testConfig(), cache)
+	stars := burst()
+	if err := cache.Put("test/test_details", github.Repository{
+		FullName:        "test/test",
+		StargazersCount: len(stars),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	gock.New("https://api.github.com").
+		Get("/rate_limit").
+		Persist().
+		Reply(200).
+		JSON(map[string]interface{}{"rate": map[string]int{"limit": 5000, "remaining": 4000}})
+	gock.New("https://api.github.com").
+		Get("/repos/test/test/stargazers").
+		MatchParam("page", "1").
+		Reply(200).
+		JSON(stars[:100])
+	gock.New("https://api.github.com").
+		Get("/repos/test/test/stargazers").
+		MatchParam("page", "2").
+		Reply(200).
+		JSON(stars[100:200])
+	gock.New("https://api.github.com").
+		Get("/repos/test/test/stargazers").
+		MatchParam("page", "3").
+		Reply(200).
+		JSON(stars[200:300])
+	gock.New("https://api.github.com").
+		Get("/repos/test/test/stargazers").
+		MatchParam("page", "4").
+		Reply(200).
+		JSON(stars[300:])
+
+	request := func(path string) *httptest.ResponseRecorder {
+		r := mux.SetURLVars(httptest.NewRequest(http.MethodGet, path, nil), map[string]string{
+			"owner": "test",
+			"repo":  "test",
+		})
+		w := httptest.NewRecorder()
+		GetRepoJSON(gh).ServeHTTP(w, r)
+		return w
+	}
+
+	for path, tt := range map[string]struct {
+		per    string
+		points int
+	}{
+		"/test/test.json":          {"", len(stars)},
+		"/test/test.json?per=hour": {"hour", 6},
+		"/test/test.json?per=day":  {"day", 4},
+	} {
+		t.Run(path, func(t *testing.T) {
+			is := is.New(t)
+			w := request(path)
+			is.Equal(http.StatusOK, w.Code)
+			is.Equal(tt.per, w.Header().Get("x-timeline-per"))
+			var points []Point
+			is.NoErr(json.NewDecoder(w.Body).Decode(&points))
+			is.Equal(tt.points, len(points))
+			is.Equal(len(stars), points[len(points)-1].Stars) // should end at the total
+		})
+	}
+}
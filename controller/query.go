@@ -0,0 +1,172 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	chart "github.com/wcharczuk/go-chart"
+	"github.com/wcharczuk/go-chart/drawing"
+)
+
+// queryNormalizer canonicalizes the value of a chart query parameter,
+// returning false if it charts the same as not setting the parameter.
+type queryNormalizer func(value string) (string, bool)
+
+// NormalizeQuery rewrites the chart query parameters into their canonical
+// form before handing the request to next, so charts that look the same
+// share the rendered chart cache entry and etag: values that chart the same
+// as the defaults are dropped, aliases and colors are canonicalized, and
+// parameters are sorted.
+// Unknown parameters are kept as they are.
+func NormalizeQuery(defaults ChartDefaults, next http.Handler) http.Handler {
+	normalizers := chartQueryNormalizers(defaults)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.RawQuery == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		r2 := r.Clone(r.Context())
+		r2.URL.RawQuery = normalizeQuery(r.URL.Query(), normalizers).Encode()
+		next.ServeHTTP(w, r2)
+	})
+}
+
+// normalizeQuery returns the canonical form of the given chart query.
+func normalizeQuery(query url.Values, normalizers map[string]queryNormalizer) url.Values {
+	// aliases of other parameters, used only when those aren't set.
+	if query.Get("background") != "transparent" && query.Get("bg") == "none" {
+		query.Set("background", "transparent")
+	}
+	query.Del("bg")
+	if query.Get("scale") == "" {
+		query.Set("scale", query.Get("dpr"))
+	}
+	query.Del("dpr")
+
+	result := url.Values{}
+	for key, values := range query {
+		normalize, ok := normalizers[key]
+		if !ok {
+			result[key] = values
+			continue
+		}
+		if value, ok := normalize(values[0]); ok {
+			result.Set(key, value)
+		}
+	}
+	return result
+}
+
+// chartQueryNormalizers mirrors how chartOptions parses each parameter.
+// nolint: funlen
+func chartQueryNormalizers(defaults ChartDefaults) map[string]queryNormalizer {
+	is := func(expected string) queryNormalizer {
+		return func(value string) (string, bool) {
+			return value, value == expected
+		}
+	}
+	positive := func(parse func(string) int) queryNormalizer {
+		return func(value string) (string, bool) {
+			n := parse(value)
+			return strconv.Itoa(n), n > 0
+		}
+	}
+	// kept for the handler to validate.
+	keep := func(value string) (string, bool) {
+		return value, value != ""
+	}
+	size := func(def, builtin int) queryNormalizer {
+		return func(value string) (string, bool) {
+			n := chartSize(value, def, builtin)
+			return strconv.Itoa(n), n != chartSize("", def, builtin)
+		}
+	}
+
+	theme := defaults.Theme
+	if _, ok := chartThemes[theme]; !ok {
+		theme = defaultTheme
+	}
+	color := defaults.LineColor
+	if color.IsZero() {
+		color = lineColor
+	}
+
+	return map[string]queryNormalizer{
+		"data":          is("true"),
+		"reverse":       is("true"),
+		"skip_weekends": is("true"),
+		"background":    is("transparent"),
+		"yaxis":         is("left"),
+		"bars":          is("daily"),
+		"per":           is("hour"),
+		"css":           is("classes"),
+		"xticks": func(value string) (string, bool) {
+			if value == "calendar" {
+				return value, !defaults.CalendarTicks
+			}
+			ticks := parseTicks(value)
+			// any other value turns calendar ticks off.
+			return strconv.Itoa(ticks), ticks > 0 || defaults.CalendarTicks
+		},
+		"yticks": positive(parseTicks),
+		"goal": positive(func(value string) int {
+			goal, _ := strconv.Atoi(value)
+			return goal
+		}),
+		"forecast": positive(func(value string) int {
+			days, _ := forecastDays(value)
+			return days
+		}),
+		"recent": positive(func(value string) int {
+			recent, _ := strconv.Atoi(value)
+			if recent > maxRecent {
+				return maxRecent
+			}
+			return recent
+		}),
+		"scale": func(value string) (string, bool) {
+			scale := parseScale(value)
+			return strconv.Itoa(scale), scale > 1
+		},
+		"datefmt": func(value string) (string, bool) {
+			_, err := parseDateFormat(value)
+			return value, err == nil
+		},
+		"theme": func(value string) (string, bool) {
+			_, ok := chartThemes[value]
+			return value, ok && value != theme
+		},
+		"color": func(value string) (string, bool) {
+			c, err := ParseColor(value)
+			return hexColor(c), err == nil && c != color
+		},
+		"ybase": func(value string) (string, bool) {
+			base, auto, ok := parseYBase(value)
+			switch {
+			case !ok:
+				return value, false
+			case auto:
+				return "auto", true
+			case *base == 0:
+				return "zero", true
+			default:
+				return strconv.Itoa(*base), true
+			}
+		},
+		"stroke_width": func(value string) (string, bool) {
+			width := parseStrokeWidth(value)
+			return strconv.FormatFloat(width, 'f', -1, 64), width > 0
+		},
+		"axis_min": keep,
+		"axis_max": keep,
+		"width":    size(defaults.Width, chart.DefaultChartWidth),
+		"height":   size(defaults.Height, chart.DefaultChartHeight),
+	}
+}
+
+// hexColor formats the color as ParseColor parses it.
+func hexColor(c drawing.Color) string {
+	return fmt.Sprintf("%02x%02x%02x", c.R, c.G, c.B)
+}
@@ -0,0 +1,67 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/caarlos0/starcharts/internal/github"
+	"github.com/matryer/is"
+)
+
+func TestNormalizeQuery(t *testing.T) {
+	normalized := func(defaults ChartDefaults, url string) string {
+		var normalized string
+		handler := NormalizeQuery(defaults, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			normalized = r.URL.String()
+		}))
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, url, nil))
+		return normalized
+	}
+
+	for name, tt := range map[string]struct {
+		a, b string
+	}{
+		"reordered":          {"/a/b.svg?theme=dark&color=ff0000", "/a/b.svg?color=ff0000&theme=dark"},
+		"default values":     {"/a/b.svg", "/a/b.svg?reverse=false&theme=light&color=81c7ef&width=1024&scale=1&goal=0&yaxis=right"},
+		"empty values":       {"/a/b.svg", "/a/b.svg?theme=&color=&recent="},
+		"invalid values":     {"/a/b.svg", "/a/b.svg?theme=nope&color=red&xticks=lots&forecast=soon"},
+		"color case":         {"/a/b.svg?color=ff00aa", "/a/b.svg?color=%23FF00AA"},
+		"background alias":   {"/a/b.svg?background=transparent", "/a/b.svg?bg=none"},
+		"scale alias":        {"/a/b.png?scale=2", "/a/b.png?dpr=2"},
+		"clamped values":     {"/a/b.png?scale=3&recent=10000", "/a/b.png?scale=9&recent=99999"},
+		"first value counts": {"/a/b.svg?theme=dark", "/a/b.svg?theme=dark&theme=light"},
+		"ybase zero":         {"/a/b.svg?ybase=zero", "/a/b.svg?ybase=0"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			is := is.New(t)
+			is.Equal(normalized(ChartDefaults{}, tt.a), normalized(ChartDefaults{}, tt.b))
+		})
+	}
+
+	t.Run("etag", func(t *testing.T) {
+		is := is.New(t)
+		etag := func(url string) string {
+			var etag string
+			handler := NormalizeQuery(ChartDefaults{}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				etag = chartEtag(github.Repository{FullName: "a/b"}, r, chartFormat{}, ChartOptions{})
+			}))
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, url, nil))
+			return etag
+		}
+		is.Equal(etag("/a/b.svg?theme=dark&reverse=true"), etag("/a/b.svg?reverse=true&color=81C7EF&theme=dark"))
+	})
+
+	t.Run("configured defaults", func(t *testing.T) {
+		is := is.New(t)
+		defaults := ChartDefaults{Theme: "dark", Width: 800}
+		is.Equal(normalized(defaults, "/a/b.svg"), normalized(defaults, "/a/b.svg?theme=dark&width=800"))
+		is.True(normalized(defaults, "/a/b.svg") != normalized(defaults, "/a/b.svg?theme=light")) // should keep the non default theme
+	})
+
+	t.Run("different charts", func(t *testing.T) {
+		is := is.New(t)
+		is.True(normalized(ChartDefaults{}, "/a/b.svg?theme=dark") != normalized(ChartDefaults{}, "/a/b.svg"))
+		is.True(normalized(ChartDefaults{}, "/a/b.svg?utm=x") != normalized(ChartDefaults{}, "/a/b.svg")) // should keep unknown parameters
+	})
+}
@@ -0,0 +1,66 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestParseTrustedProxies(t *testing.T) {
+	is := is.New(t)
+	proxies, err := ParseTrustedProxies([]string{"10.0.0.0/8", " 192.168.1.1 ", "", "::1"})
+	is.NoErr(err)
+	is.Equal(len(proxies), 3)
+
+	_, err = ParseTrustedProxies([]string{"not-an-ip"})
+	is.True(err != nil)
+	_, err = ParseTrustedProxies([]string{"10.0.0.0/99"})
+	is.True(err != nil)
+}
+
+func TestClientIP(t *testing.T) {
+	proxies, err := ParseTrustedProxies([]string{"10.0.0.0/8"})
+	is.New(t).NoErr(err)
+
+	for name, tt := range map[string]struct {
+		remote  string
+		forward string
+		expect  string
+	}{
+		"direct":               {"1.2.3.4:1234", "", "1.2.3.4"},
+		"untrusted forwarding": {"1.2.3.4:1234", "5.6.7.8", "1.2.3.4"},
+		"single proxy":         {"10.0.0.1:1234", "5.6.7.8", "5.6.7.8"},
+		"proxy chain":          {"10.0.0.1:1234", "5.6.7.8, 10.0.0.2", "5.6.7.8"},
+		"spoofed":              {"10.0.0.1:1234", "9.9.9.9, 5.6.7.8", "5.6.7.8"},
+		"invalid entry":        {"10.0.0.1:1234", "garbage", "10.0.0.1"},
+		"all trusted":          {"10.0.0.1:1234", "10.0.0.3", "10.0.0.3"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			is := is.New(t)
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remote
+			if tt.forward != "" {
+				r.Header.Set("X-Forwarded-For", tt.forward)
+			}
+			is.Equal(proxies.ClientIP(r), tt.expect)
+		})
+	}
+}
+
+func TestRealIP(t *testing.T) {
+	is := is.New(t)
+	proxies, err := ParseTrustedProxies([]string{"10.0.0.1"})
+	is.NoErr(err)
+
+	var remote string
+	handler := RealIP(proxies, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		remote = r.RemoteAddr
+	}))
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "10.0.0.1:1234"
+	r.Header.Set("X-Forwarded-For", "5.6.7.8")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	is.Equal(remote, "5.6.7.8:0")
+}
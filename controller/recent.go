@@ -0,0 +1,76 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/caarlos0/httperr"
+	"github.com/caarlos0/starcharts/internal/github"
+)
+
+// defaultRecentStargazers is how many stargazers are listed if the n query
+// parameter isn't set.
+const defaultRecentStargazers = 10
+
+// recentStargazer is a stargazer as listed by the recent endpoint.
+type recentStargazer struct {
+	// Login is empty if github didn't send who starred.
+	Login     string    `json:"login,omitempty"`
+	StarredAt time.Time `json:"starred_at"`
+}
+
+// GetRecentStargazers lists the latest stargazers of the given repository,
+// newest first.
+// The n query parameter sets how many, up to max.
+func GetRecentStargazers(gh *github.GitHub, max int) http.Handler {
+	return httperr.NewF(func(w http.ResponseWriter, r *http.Request) error {
+		name, err := repoName(r)
+		if err != nil {
+			return err
+		}
+		log := log.WithField("repo", name)
+		defer log.Trace("collect_stars").Stop(nil)
+		repo, err := gh.RepoDetails(r.Context(), name)
+		if err != nil {
+			return fetchErr(w, err, http.StatusBadRequest)
+		}
+
+		stargazers, err := gh.RecentStargazers(r.Context(), repo, recentCount(r, max))
+		if err != nil {
+			log.WithError(err).Error("failed to get stars")
+			return fetchErr(w, err, http.StatusInternalServerError)
+		}
+
+		w.Header().Add("content-type", "application/json")
+		w.Header().Add("cache-control", "public, max-age=3600")
+		return json.NewEncoder(w).Encode(recentStargazers(stargazers))
+	})
+}
+
+// recentCount parses the n query parameter, clamping it to [1, max].
+func recentCount(r *http.Request, max int) int {
+	n, err := strconv.Atoi(r.URL.Query().Get("n"))
+	if err != nil || n < 1 {
+		n = defaultRecentStargazers
+	}
+	if n > max {
+		return max
+	}
+	return n
+}
+
+// recentStargazers lists the given sorted stargazers newest first.
+func recentStargazers(stargazers []github.Stargazer) []recentStargazer {
+	result := make([]recentStargazer, 0, len(stargazers))
+	for i := len(stargazers) - 1; i >= 0; i-- {
+		star := recentStargazer{StarredAt: stargazers[i].StarredAt}
+		if user := stargazers[i].User; user != nil {
+			star.Login = user.Login
+		}
+		result = append(result, star)
+	}
+	return result
+}
@@ -0,0 +1,41 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/caarlos0/starcharts/internal/github"
+	"github.com/matryer/is"
+)
+
+func TestRecentStargazers(t *testing.T) {
+	is := is.New(t)
+	now := time.Now()
+	is.Equal([]recentStargazer{
+		{Login: "bar", StarredAt: now},
+		{StarredAt: now.Add(-time.Minute)},
+		{Login: "foo", StarredAt: now.Add(-time.Hour)},
+	}, recentStargazers([]github.Stargazer{
+		{StarredAt: now.Add(-time.Hour), User: &github.User{Login: "foo"}},
+		{StarredAt: now.Add(-time.Minute)},
+		{StarredAt: now, User: &github.User{Login: "bar"}},
+	}))
+}
+
+func TestRecentCount(t *testing.T) {
+	for query, expected := range map[string]int{
+		"":       defaultRecentStargazers,
+		"?n=5":   5,
+		"?n=0":   defaultRecentStargazers,
+		"?n=abc": defaultRecentStargazers,
+		"?n=500": 50,
+	} {
+		t.Run(query, func(t *testing.T) {
+			is := is.New(t)
+			r := httptest.NewRequest(http.MethodGet, "/foo/bar/recent.json"+query, nil)
+			is.Equal(expected, recentCount(r, 50))
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"strings"
+
+	"github.com/apex/log"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var panics = prometheus.NewCounter(prometheus.CounterOpts{
+	Namespace: "starcharts",
+	Subsystem: "http",
+	Name:      "panics_total",
+	Help:      "Total number of requests that panicked",
+})
+
+func init() {
+	prometheus.MustRegister(panics)
+}
+
+// Recover turns panics in the given handler into a 500, or a placeholder
+// image for SVG charts, instead of killing the connection.
+func Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw := &recoverWriter{ResponseWriter: w}
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			if rec == http.ErrAbortHandler {
+				panic(rec)
+			}
+			panics.Inc()
+			log.WithField("method", r.Method).
+				WithField("url", r.URL.String()).
+				WithField("stack", string(debug.Stack())).
+				Errorf("panic: %v", rec)
+			if rw.wroteHeader {
+				// too late to change the response.
+				return
+			}
+			err := errors.New("internal server error")
+			if strings.HasSuffix(r.URL.Path, ".svg") {
+				w.Header().Set("content-type", "image/svg+xml;charset=utf-8")
+				w.WriteHeader(http.StatusInternalServerError)
+				_, _ = fmt.Fprint(w, errSvg(err))
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}()
+		next.ServeHTTP(rw, r)
+	})
+}
+
+// recoverWriter tracks whether the response was already started.
+type recoverWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (w *recoverWriter) WriteHeader(code int) {
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *recoverWriter) Write(bts []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(bts)
+}
+
+// Flush implements http.Flusher, as some handlers stream their responses.
+func (w *recoverWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
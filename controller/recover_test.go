@@ -0,0 +1,60 @@
+package controller
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestRecover(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+	mux.HandleFunc("/panic.svg", func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "ok")
+	})
+	srv := httptest.NewServer(Recover(mux))
+	defer srv.Close()
+
+	get := func(t *testing.T, path string) (*http.Response, string) {
+		t.Helper()
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		bts, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp, string(bts)
+	}
+
+	t.Run("panic", func(t *testing.T) {
+		is := is.New(t)
+		resp, _ := get(t, "/panic")
+		is.Equal(http.StatusInternalServerError, resp.StatusCode)
+	})
+
+	t.Run("panic on svg", func(t *testing.T) {
+		is := is.New(t)
+		resp, body := get(t, "/panic.svg")
+		is.Equal(http.StatusInternalServerError, resp.StatusCode)
+		is.True(strings.HasPrefix(body, "<svg")) // should be a placeholder svg
+	})
+
+	t.Run("still up", func(t *testing.T) {
+		is := is.New(t)
+		resp, body := get(t, "/ok")
+		is.Equal(http.StatusOK, resp.StatusCode)
+		is.Equal("ok", body)
+	})
+}
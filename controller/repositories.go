@@ -1,28 +1,33 @@
 package controller
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/apex/log"
 	"github.com/caarlos0/httperr"
 	"github.com/caarlos0/starcharts/internal/cache"
 	"github.com/caarlos0/starcharts/internal/github"
-	"github.com/gorilla/mux"
 	chart "github.com/wcharczuk/go-chart"
 	"github.com/wcharczuk/go-chart/drawing"
 )
 
 // GetRepo shows the given repo chart.
-func GetRepo(fsys fs.FS, github *github.GitHub, cache *cache.Redis, version string) http.Handler {
+func GetRepo(fsys fs.FS, github *github.GitHub, cache cache.Cache, version string) http.Handler {
 	return httperr.NewF(func(w http.ResponseWriter, r *http.Request) error {
-		name := fmt.Sprintf(
-			"%s/%s",
-			mux.Vars(r)["owner"],
-			mux.Vars(r)["repo"],
-		)
+		name, err := repoName(r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return executeTemplate(fsys, w, map[string]error{
+				"Error": err,
+			})
+		}
 		// 核心调用
 		details, err := github.RepoDetails(r.Context(), name)
 		if err != nil {
@@ -46,104 +51,457 @@ func IntValueFormatter(v interface{}) string {
 }
 
 // GetRepoChart returns the SVG chart for the given repository.
+func GetRepoChart(gh *github.GitHub, cache cache.Cache, config ChartConfig) http.Handler {
+	return repoChart(gh, chartFormat{
+		contentType: "image/svg+xml;charset=utf-8",
+		config:      config,
+		cache:       cache,
+	})
+}
+
+// GetRepoChartPNG returns the PNG chart for the given repository.
 //
+// The optional scale (or dpr) query parameter multiplies the rendering
+// resolution, so the image looks crisp on high-DPI displays.
+func GetRepoChartPNG(gh *github.GitHub, cache cache.Cache, config ChartConfig) http.Handler {
+	return repoChart(gh, chartFormat{
+		contentType: "image/png",
+		raster:      true,
+		config:      config,
+		cache:       cache,
+	})
+}
+
+// maxScale bounds the PNG scale factor, and thus the memory used to render it.
+const maxScale = 3
+
+type chartFormat struct {
+	contentType string
+	raster      bool
+	config      ChartConfig
+	// cache keeps the last rendered charts, see ChartConfig.StaleTTL.
+	cache cache.Cache
+}
+
 // nolint: funlen
 // TODO: refactor.
-func GetRepoChart(gh *github.GitHub, cache *cache.Redis) http.Handler {
+func repoChart(gh *github.GitHub, format chartFormat) http.Handler {
 	return httperr.NewF(func(w http.ResponseWriter, r *http.Request) error {
-		name := fmt.Sprintf(
-			"%s/%s",
-			mux.Vars(r)["owner"],
-			mux.Vars(r)["repo"],
-		)
+		name, err := repoName(r)
+		if err != nil {
+			return err
+		}
+		if _, _, err := axisRange(r); err != nil {
+			return err
+		}
+		if _, err := parseBands(r); err != nil {
+			return err
+		}
+		if budget := format.config.RequestBudget; budget > 0 {
+			ctx, cancel := context.WithTimeout(r.Context(), budget)
+			defer cancel()
+			r = r.WithContext(ctx)
+		}
 		log := log.WithField("repo", name)
 		defer log.Trace("collect_stars").Stop(nil)
 		repo, err := gh.RepoDetails(r.Context(), name)
+		if errors.Is(err, github.ErrNoTokensConfigured) || errors.Is(err, github.ErrNotYetAvailable) {
+			return chartErr(w, r, format, err)
+		}
 		if err != nil {
-			return httperr.Wrap(err, http.StatusBadRequest)
+			if serveLastChart(w, r, format) {
+				return nil
+			}
+			return fetchErr(w, err, http.StatusBadRequest)
 		}
 
-		w.Header().Add("content-type", "image/svg+xml;charset=utf-8")
+		w.Header().Add("content-type", format.contentType)
 		w.Header().Add("cache-control", "public, max-age=86400")
 		w.Header().Add("date", time.Now().Format(time.RFC1123))
 		w.Header().Add("expires", time.Now().Format(time.RFC1123))
+		opts := chartOptions(r, format, 0)
+		opts.Name = repo.FullName
+		etag := chartEtag(repo, r, format, opts)
+		if cachesCharts(format) && !format.raster {
+			w.Header().Add("vary", "Accept-Encoding")
+		}
+		if gzipsChart(r, format) {
+			etag = gzipEtag(etag)
+		}
+		w.Header().Set("etag", etag)
+		if etagMatches(r.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return nil
+		}
+		if r.Method == http.MethodHead {
+			// headers only, no need to fetch the stars.
+			return nil
+		}
+
+		if busy(r, gh, repo, format) {
+			return busyErr(w, r, format)
+		}
 
-		stargazers, err := gh.Stargazers(r.Context(), repo)
+		if format.config.Streaming && !needsStargazers(r, opts) {
+			return histogramChart(w, r, gh, repo, format, opts)
+		}
+
+		stargazers, baseline, err := stars(r, gh, repo)
 		fmt.Printf("stargazers length --- > %v\n", len(stargazers))
 		fmt.Printf("stargazers --- > %v\n", stargazers)
 
+		if sampled(gh, err) {
+			return sampledChart(w, r, gh, repo, format, opts)
+		}
 		if err != nil {
 			log.WithError(err).Error("failed to get stars")
-			_, err = w.Write([]byte(errSvg(err)))
+			return chartErr(w, r, format, err)
+		}
+
+		opts.Baseline = baseline
+		setDownsampled(w, len(stargazers), opts.MaxPoints)
+		defer log.Trace("chart").Stop(&err)
+		if err := writeChart(w, r, format, func(w io.Writer) error {
+			return WriteChart(w, stargazers, opts)
+		}); err != nil {
+			log.WithError(err).Error("failed to render graph")
 			return err
 		}
+		return nil
+	})
+}
+
+// histogramBucket is the bucket size of histogram charts, unless the per
+// query parameter asks for another one.
+const histogramBucket = 24 * time.Hour
+
+// histogramChart renders the chart of the given repository from a histogram
+// of its stars.
+func histogramChart(w http.ResponseWriter, r *http.Request, gh *github.GitHub, repo github.Repository, format chartFormat, opts ChartOptions) (err error) {
+	log := log.WithField("repo", repo.FullName)
+	bucket := histogramBucket
+	if created, err := time.Parse(time.RFC3339, repo.CreatedAt); err == nil {
+		bucket = bucketPeriod(opts.Per, created, time.Now())
+	}
+	hist, err := gh.StargazersHistogram(r.Context(), repo, bucket)
+	if sampled(gh, err) {
+		return sampledChart(w, r, gh, repo, format, opts)
+	}
+	if err != nil {
+		log.WithError(err).Error("failed to get stars")
+		return chartErr(w, r, format, err)
+	}
+	setDownsampled(w, len(hist.Points()), opts.MaxPoints)
+	defer log.Trace("chart").Stop(&err)
+	if err := writeChart(w, r, format, func(w io.Writer) error {
+		return WriteHistogramChart(w, hist, opts)
+	}); err != nil {
+		log.WithError(err).Error("failed to render graph")
+		return err
+	}
+	return nil
+}
+
+// needsStargazers tells whether rendering the chart needs every stargazer,
+// rather than just how many stars there were over time.
+func needsStargazers(r *http.Request, opts ChartOptions) bool {
+	return opts.Goal > 0 || opts.ForecastDays > 0 || opts.EmbedData ||
+		opts.DailyBars || r.URL.Query().Get("recent") != ""
+}
+
+// chartErr writes the error of fetching stargazers for a chart, as a SVG
+// image or as a plain http error, unless the last chart can be served
+// instead.
+func chartErr(w http.ResponseWriter, r *http.Request, format chartFormat, err error) error {
+	if serveLastChart(w, r, format) {
+		return nil
+	}
+	setRetryAfter(w, err)
+	if !format.raster {
+		w.WriteHeader(errStatus(err, http.StatusOK))
+		_, err = w.Write([]byte(errSvg(err)))
+		return err
+	}
+	return httperr.Wrap(explainErr(err), errStatus(err, http.StatusInternalServerError))
+}
+
+// chartOptions parses the chart options from the request query parameters.
+func chartOptions(r *http.Request, format chartFormat, baseline int) ChartOptions {
+	opts := ChartOptions{
+		Baseline:         baseline,
+		Raster:           format.raster,
+		EmbedData:        r.URL.Query().Get("data") == "true",
+		Transparent:      transparentBackground(r),
+		Reverse:          r.URL.Query().Get("reverse") == "true",
+		Watermark:        format.config.Watermark,
+		MaxPoints:        format.config.MaxPoints,
+		XTicks:           parseTicks(r.URL.Query().Get("xticks")),
+		YTicks:           parseTicks(r.URL.Query().Get("yticks")),
+		YAxisLeft:        yAxisLeft(r.URL.Query().Get("yaxis")),
+		DailyBars:        dailyBars(r.URL.Query().Get("bars")),
+		Per:              parsePer(r.URL.Query().Get("per")),
+		CSSClasses:       cssClasses(r.URL.Query().Get("css")),
+		SkipWeekends:     r.URL.Query().Get("skip_weekends") == "true",
+		StrokeWidth:      parseStrokeWidth(r.URL.Query().Get("stroke_width")),
+		DropWeekendStars: format.config.DropWeekendStars,
+	}
+	if goal, err := strconv.Atoi(r.URL.Query().Get("goal")); err == nil {
+		opts.Goal = goal
+	}
+	if layout, err := parseDateFormat(r.URL.Query().Get("datefmt")); err == nil {
+		opts.DateFormat = layout
+	}
+	if days, ok := forecastDays(r.URL.Query().Get("forecast")); ok {
+		opts.ForecastDays = days
+	}
+	if base, auto, ok := parseYBase(r.URL.Query().Get("ybase")); ok {
+		opts.YBase, opts.YBaseAuto = base, auto
+	}
+	if format.raster {
+		opts.Scale = chartScale(r)
+	}
+	// invalid ranges are rejected before rendering, see axisRange.
+	opts.AxisMin, opts.AxisMax, _ = axisRange(r)
+	opts.Bands, _ = parseBands(r)
+	applyChartDefaults(r, &opts, format.config.Defaults)
+	return opts
+}
+
+// transparentBackground tells whether the background=transparent (or bg=none)
+// query parameter is set.
+func transparentBackground(r *http.Request) bool {
+	return r.URL.Query().Get("background") == "transparent" ||
+		r.URL.Query().Get("bg") == "none"
+}
+
+// maxRecent bounds the recent query parameter.
+const maxRecent = 10000
+
+// stars gets the stargazers to chart: all of them, or only the last ones if
+// the recent query parameter is set, along with the count to start the
+// chart at.
+func stars(r *http.Request, gh *github.GitHub, repo github.Repository) ([]github.Stargazer, int, error) {
+	recent, err := strconv.Atoi(r.URL.Query().Get("recent"))
+	if err != nil || recent < 1 {
+		stars, err := gh.Stargazers(r.Context(), repo)
+		return stars, 0, err
+	}
+	if recent > maxRecent {
+		recent = maxRecent
+	}
+	stars, err := gh.RecentStargazers(r.Context(), repo, recent)
+	baseline := repo.StargazersCount - len(stars)
+	if baseline < 0 {
+		baseline = 0
+	}
+	return stars, baseline, err
+}
+
+// chartScale parses the scale (or dpr) query parameter, clamping it to
+// [1, maxScale].
+func chartScale(r *http.Request) int {
+	value := r.URL.Query().Get("scale")
+	if value == "" {
+		value = r.URL.Query().Get("dpr")
+	}
+	return parseScale(value)
+}
+
+// parseScale parses a scale factor, clamping it to [1, maxScale].
+func parseScale(value string) int {
+	scale, err := strconv.Atoi(value)
+	if err != nil || scale < 1 {
+		return 1
+	}
+	if scale > maxScale {
+		return maxScale
+	}
+	return scale
+}
+
+// buildHistogramGraph builds the chart for the given star histogram, starting
+// the cumulative count at baseline.
+func buildHistogramGraph(hist *github.StarHistogram, baseline int, color drawing.Color) chart.Chart {
+	series := chart.TimeSeries{
+		Style: chart.Style{
+			Show:        true,
+			StrokeColor: color,
+			StrokeWidth: 2,
+		},
+	}
+	for _, point := range hist.Points() {
+		series.XValues = append(series.XValues, point.Time)
+		series.YValues = append(series.YValues, float64(baseline+point.Total))
+	}
+	if len(series.XValues) < 2 {
+		series.XValues = append(series.XValues, time.Now())
+		series.YValues = append(series.YValues, float64(baseline+1))
+	}
+	return newGraph(IntValueFormatter, series)
+}
+
+// buildGraph builds the chart for the given points.
+// If there aren't enough of them to draw a line, it is extended to now, from
+// baseline if there are none.
+func buildGraph(log log.Interface, points []Point, baseline int, color drawing.Color) chart.Chart {
+	series := chart.TimeSeries{
+		Style: chart.Style{
+			Show:        true,
+			StrokeColor: color,
+			StrokeWidth: 2,
+		},
+	}
+	for _, p := range points {
+		series.XValues = append(series.XValues, p.Date)
+		series.YValues = append(series.YValues, float64(p.Stars))
+	}
+	if len(series.XValues) < 2 {
+		log.Info("not enough results, adding some fake ones")
+		last := float64(baseline)
+		if len(points) > 0 {
+			last = float64(points[len(points)-1].Stars)
+		}
+		series.XValues = append(series.XValues, time.Now())
+		series.YValues = append(series.YValues, last)
+	}
+	return newGraph(IntValueFormatter, series)
+}
 
-		// 画图
-		series := chart.TimeSeries{
+// nolint: gochecknoglobals
+var lineColor = drawing.Color{
+	R: 129,
+	G: 199,
+	B: 239,
+	A: 255,
+}
+
+// starSeries builds a time series of the given stargazers, using value to
+// compute the y value of the i-th star.
+func starSeries(stargazers []github.Stargazer, color drawing.Color, value func(i int) float64) chart.TimeSeries {
+	series := chart.TimeSeries{
+		Style: chart.Style{
+			Show:        true,
+			StrokeColor: color,
+			StrokeWidth: 2,
+		},
+	}
+	for i, star := range stargazers {
+		series.XValues = append(series.XValues, star.StarredAt)
+		series.YValues = append(series.YValues, value(i))
+	}
+	return series
+}
+
+// newGraph builds a chart plotting the given series over time.
+func newGraph(formatter chart.ValueFormatter, series ...chart.Series) chart.Chart {
+	return chart.Chart{
+		XAxis: chart.XAxis{
+			Name:      "Time",
+			NameStyle: chart.StyleShow(),
 			Style: chart.Style{
-				Show: true,
+				Show:        true,
+				StrokeWidth: 2,
 				StrokeColor: drawing.Color{
-					R: 129,
-					G: 199,
-					B: 239,
+					R: 85,
+					G: 85,
+					B: 85,
 					A: 255,
 				},
-				StrokeWidth: 2,
-			},
-		}
-		for i, star := range stargazers {
-			series.XValues = append(series.XValues, star.StarredAt)
-			series.YValues = append(series.YValues, float64(i))
-		}
-		if len(series.XValues) < 2 {
-			log.Info("not enough results, adding some fake ones")
-			series.XValues = append(series.XValues, time.Now())
-			series.YValues = append(series.YValues, 1)
-		}
-
-		graph := chart.Chart{
-			XAxis: chart.XAxis{
-				Name:      "Time",
-				NameStyle: chart.StyleShow(),
-				Style: chart.Style{
-					Show:        true,
-					StrokeWidth: 2,
-					StrokeColor: drawing.Color{
-						R: 85,
-						G: 85,
-						B: 85,
-						A: 255,
-					},
-				},
 			},
-			YAxis: chart.YAxis{
-				Name:      "Stargazers",
-				NameStyle: chart.StyleShow(),
-				Style: chart.Style{
-					Show:        true,
-					StrokeWidth: 2,
-					StrokeColor: drawing.Color{
-						R: 85,
-						G: 85,
-						B: 85,
-						A: 255,
-					},
+		},
+		YAxis: chart.YAxis{
+			Name:      "Stargazers",
+			NameStyle: chart.StyleShow(),
+			Style: chart.Style{
+				Show:        true,
+				StrokeWidth: 2,
+				StrokeColor: drawing.Color{
+					R: 85,
+					G: 85,
+					B: 85,
+					A: 255,
 				},
-				ValueFormatter: IntValueFormatter,
 			},
-			Series: []chart.Series{series},
-		}
-		defer log.Trace("chart").Stop(&err)
-		if err := graph.Render(chart.SVG, w); err != nil {
-			log.WithError(err).Error("failed to render graph")
-			return err
-		}
-		return nil
-	})
+			ValueFormatter: formatter,
+		},
+		Series: series,
+	}
+}
+
+// errStatus maps errors from fetching stargazers into http status codes,
+// returning fallback for errors without a specific status.
+func errStatus(err error, fallback int) int {
+	switch {
+	case errors.Is(err, github.ErrTimeout), errors.Is(err, github.ErrCacheTimeout):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, github.ErrOverloaded), errors.Is(err, github.ErrCircuitOpen),
+		errors.Is(err, github.ErrRetryBudgetExhausted), errors.Is(err, github.ErrNoTokensConfigured),
+		errors.Is(err, github.ErrNotYetAvailable), errors.Is(err, errBusy):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, github.ErrTooManyStars), errors.Is(err, github.ErrAboveMaxStars),
+		errors.Is(err, github.ErrRecentTooFar):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, github.ErrUnknownToken):
+		return http.StatusBadRequest
+	case errors.Is(err, github.ErrRepoNotFound):
+		return http.StatusNotFound
+	default:
+		return fallback
+	}
+}
+
+// retryAfterUnavailable is how long clients should wait to retry when we are
+// overloaded or github is unavailable.
+const retryAfterUnavailable = "5"
+
+// setRetryAfter tells clients when to retry errors that are likely to be
+// transient.
+func setRetryAfter(w http.ResponseWriter, err error) {
+	if errors.Is(err, github.ErrOverloaded) || errors.Is(err, github.ErrCircuitOpen) ||
+		errors.Is(err, github.ErrNotYetAvailable) || errors.Is(err, errBusy) {
+		w.Header().Set("retry-after", retryAfterUnavailable)
+	}
+}
+
+// fetchErr wraps errors from fetching data from github with their http
+// status, telling clients when to retry the transient ones.
+func fetchErr(w http.ResponseWriter, err error, fallback int) error {
+	setRetryAfter(w, err)
+	return httperr.Wrap(err, errStatus(err, fallback))
+}
+
+// explainErr adds a hint on how to work around errors the user can do
+// something about.
+// Charting only the latest stars works only if github lists them, which it
+// doesn't for repos with too many stars.
+func explainErr(err error) error {
+	if errors.Is(err, github.ErrRecentListable) {
+		return fmt.Errorf("%w, use the recent query parameter to chart only the latest stars, e.g. ?recent=1000", err)
+	}
+	if errors.Is(err, github.ErrNoTokensConfigured) {
+		return fmt.Errorf("%w, this instance needs GITHUB_TOKENS or GITHUB_TOKENS_FILE set", err)
+	}
+	return err
 }
 
 func errSvg(err error) string {
+	msg := err.Error()
+	if errors.Is(err, github.ErrTooManyStars) || errors.Is(err, github.ErrAboveMaxStars) {
+		msg = "too many stars to chart"
+	}
+	if errors.Is(err, github.ErrRecentListable) {
+		msg = "too many stars to chart, try ?recent=1000 to chart only the latest stars"
+	}
+	if errors.Is(err, github.ErrRecentTooFar) {
+		msg = "too many stars, github won't list the latest ones"
+	}
+	if errors.Is(err, github.ErrNotYetAvailable) {
+		msg = "this chart is not available yet, please try again later"
+	}
+	if errors.Is(err, github.ErrNoTokensConfigured) {
+		msg = "this instance has no github tokens configured, set GITHUB_TOKENS or GITHUB_TOKENS_FILE"
+	}
 	return fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" width="1024" height="50">
 	<text xmlns="http://www.w3.org/2000/svg" y="20" x="100" fill="red">%s</text>
- </svg>`, err.Error())
+ </svg>`, msg)
 }
@@ -0,0 +1,285 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis"
+	"github.com/caarlos0/httperr"
+	"github.com/caarlos0/starcharts/config"
+	"github.com/caarlos0/starcharts/internal/cache"
+	"github.com/caarlos0/starcharts/internal/github"
+	"github.com/go-redis/redis"
+	"github.com/gorilla/mux"
+	"github.com/matryer/is"
+)
+
+func TestTooManyStars(t *testing.T) {
+	mr, _ := miniredis.Run()
+	rc := redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	})
+	cache := cache.New(rc)
+	defer cache.Close()
+	config := testConfig()
+	config.GitHubMaxStars = 1000
+	gh := github.New(config, cache)
+
+	// cached details, so github is never hit.
+	for name, stars := range map[string]int{
+		"test/test":  1000000,
+		"test/small": 5000,
+	} {
+		if err := cache.Put(name+"_details", github.Repository{
+			FullName:        name,
+			StargazersCount: stars,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	request := func(handler http.Handler, name, ext string) *httptest.ResponseRecorder {
+		owner, repo, _ := strings.Cut(name, "/")
+		r := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/"+name+ext, nil), map[string]string{
+			"owner": owner,
+			"repo":  repo,
+		})
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	t.Run("json", func(t *testing.T) {
+		is := is.New(t)
+		w := request(GetRepoJSON(gh), "test/test", ".json")
+		is.Equal(http.StatusUnprocessableEntity, w.Code)
+		is.True(!strings.Contains(w.Body.String(), "recent=")) // github doesn't list the latest stars either
+	})
+
+	t.Run("json above the instance max", func(t *testing.T) {
+		is := is.New(t)
+		w := request(GetRepoJSON(gh), "test/small", ".json")
+		is.Equal(http.StatusUnprocessableEntity, w.Code)
+		is.True(strings.Contains(w.Body.String(), "recent=")) // should suggest the recent param
+	})
+
+	t.Run("svg", func(t *testing.T) {
+		is := is.New(t)
+		w := request(GetRepoChart(gh, cache, ChartConfig{}), "test/test", ".svg")
+		is.Equal(http.StatusUnprocessableEntity, w.Code)
+		is.True(strings.HasPrefix(w.Body.String(), "<svg"))                   // should be a placeholder svg
+		is.True(strings.Contains(w.Body.String(), "too many stars to chart")) // should explain the error
+		is.True(!strings.Contains(w.Body.String(), "recent="))                // github doesn't list the latest stars either
+	})
+
+	t.Run("svg above the instance max", func(t *testing.T) {
+		is := is.New(t)
+		w := request(GetRepoChart(gh, cache, ChartConfig{}), "test/small", ".svg")
+		is.Equal(http.StatusUnprocessableEntity, w.Code)
+		is.True(strings.Contains(w.Body.String(), "recent=")) // should suggest the recent param
+	})
+}
+
+func TestNoTokens(t *testing.T) {
+	mr, _ := miniredis.Run()
+	rc := redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	})
+	cache := cache.New(rc)
+	defer cache.Close()
+	// as an instance started without any configuration.
+	t.Setenv("GITHUB_TOKENS", "")
+	is.New(t).NoErr(os.Unsetenv("GITHUB_TOKENS"))
+	gh := github.New(config.Get(), cache)
+
+	request := func(handler http.Handler, path string) *httptest.ResponseRecorder {
+		r := mux.SetURLVars(httptest.NewRequest(http.MethodGet, path, nil), map[string]string{
+			"owner": "test",
+			"repo":  "test",
+		})
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	t.Run("svg", func(t *testing.T) {
+		is := is.New(t)
+		w := request(GetRepoChart(gh, cache, ChartConfig{}), "/test/test.svg")
+		is.Equal(http.StatusServiceUnavailable, w.Code)
+		is.True(strings.HasPrefix(w.Body.String(), "<svg"))            // should be a placeholder svg
+		is.True(strings.Contains(w.Body.String(), "no github tokens")) // should explain the error
+		is.True(strings.Contains(w.Body.String(), "GITHUB_TOKENS"))    // should tell how to fix it
+	})
+
+	t.Run("png", func(t *testing.T) {
+		is := is.New(t)
+		w := request(GetRepoChartPNG(gh, cache, ChartConfig{}), "/test/test.png")
+		is.Equal(http.StatusServiceUnavailable, w.Code)
+		is.True(strings.Contains(w.Body.String(), "GITHUB_TOKENS")) // should tell how to fix it
+	})
+
+	t.Run("batch", func(t *testing.T) {
+		is := is.New(t)
+		r := httptest.NewRequest(http.MethodPost, "/batch.json", strings.NewReader(`{"repos": ["test/test", "test/other"]}`))
+		w := httptest.NewRecorder()
+		GetBatchJSON(gh, NewRepoFilter(nil, nil)).ServeHTTP(w, r)
+		is.Equal(http.StatusServiceUnavailable, w.Code)
+		is.True(strings.Contains(w.Body.String(), "no github tokens")) // should explain the error
+	})
+}
+
+func TestReadOnlyNotCached(t *testing.T) {
+	is := is.New(t)
+	mr, _ := miniredis.Run()
+	rc := redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	})
+	cache := cache.New(rc)
+	defer cache.Close()
+	config := testConfig()
+	config.ReadOnly = true
+	gh := github.New(config, cache)
+
+	r := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/test/test.svg", nil), map[string]string{
+		"owner": "test",
+		"repo":  "test",
+	})
+	w := httptest.NewRecorder()
+	GetRepoChart(gh, cache, ChartConfig{}).ServeHTTP(w, r)
+	is.Equal(http.StatusServiceUnavailable, w.Code)
+	is.True(w.Header().Get("retry-after") != "")                    // should tell when to retry
+	is.True(strings.Contains(w.Body.String(), "not available yet")) // should be a placeholder
+}
+
+func TestHead(t *testing.T) {
+	mr, _ := miniredis.Run()
+	rc := redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	})
+	cache := cache.New(rc)
+	defer cache.Close()
+	gh := github.New(testConfig(), cache)
+
+	// cached details and no stars, so any attempt to fetch them would fail.
+	if err := cache.Put("test/test_details", github.Repository{
+		FullName:        "test/test",
+		StargazersCount: 10,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for path, tt := range map[string]struct {
+		handler     http.Handler
+		contentType string
+		vary        string
+	}{
+		"/test/test.svg":  {GetRepoChart(gh, cache, ChartConfig{}), "image/svg+xml;charset=utf-8", ""},
+		"/test/test.png":  {GetRepoChartPNG(gh, cache, ChartConfig{}), "image/png", ""},
+		"/test/test.json": {GetRepoJSON(gh), "application/json", "Accept-Encoding"},
+		"/test/test.csv":  {GetRepoCSV(gh), "text/csv;charset=utf-8", "Accept-Encoding"},
+	} {
+		t.Run(path, func(t *testing.T) {
+			is := is.New(t)
+			r := mux.SetURLVars(httptest.NewRequest(http.MethodHead, path, nil), map[string]string{
+				"owner": "test",
+				"repo":  "test",
+			})
+			w := httptest.NewRecorder()
+			tt.handler.ServeHTTP(w, r)
+			is.Equal(http.StatusOK, w.Code)
+			is.Equal(tt.contentType, w.Header().Get("content-type"))
+			is.Equal(tt.vary, w.Header().Get("vary")) // should vary as the GET does
+			is.Equal(0, w.Body.Len())                 // should not have a body
+		})
+	}
+}
+
+func TestChartEtag(t *testing.T) {
+	mr, _ := miniredis.Run()
+	rc := redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	})
+	cache := cache.New(rc)
+	defer cache.Close()
+	gh := github.New(testConfig(), cache)
+
+	// cached details and no stars, so any attempt to fetch them would fail.
+	if err := cache.Put("test/test_details", github.Repository{
+		FullName:        "test/test",
+		StargazersCount: 10,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	handler := GetRepoChart(gh, cache, ChartConfig{})
+	request := func(method, path, etag string) *httptest.ResponseRecorder {
+		r := mux.SetURLVars(httptest.NewRequest(method, path, nil), map[string]string{
+			"owner": "test",
+			"repo":  "test",
+		})
+		if etag != "" {
+			// camo forwards the etag it got the last time.
+			r.Header.Set("If-None-Match", etag)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	is := is.New(t)
+	etag := request(http.MethodHead, "/test/test.svg", "").Header().Get("etag")
+	is.True(etag != "") // should have an etag
+
+	w := request(http.MethodGet, "/test/test.svg", etag)
+	is.Equal(http.StatusNotModified, w.Code)
+	is.Equal(etag, w.Header().Get("etag"))
+	is.Equal(0, w.Body.Len()) // should not render the chart
+
+	other := request(http.MethodHead, "/test/test.svg?variant=dark", "").Header().Get("etag")
+	is.True(other != etag) // should depend on the rendering parameters
+}
+
+func TestEtagMatches(t *testing.T) {
+	is := is.New(t)
+	is.True(etagMatches(`"abc"`, `"abc"`))
+	is.True(etagMatches(`W/"abc"`, `"abc"`))
+	is.True(etagMatches(`"foo", "abc"`, `"abc"`))
+	is.True(etagMatches(`*`, `"abc"`))
+	is.True(!etagMatches(``, `"abc"`))
+	is.True(!etagMatches(`"abd"`, `"abc"`))
+}
+
+func TestFetchErr(t *testing.T) {
+	for name, tt := range map[string]struct {
+		err        error
+		status     int
+		retryAfter string
+	}{
+		"overloaded": {github.ErrOverloaded, http.StatusServiceUnavailable, retryAfterUnavailable},
+		"wrapped":    {fmt.Errorf("stars: %w", github.ErrOverloaded), http.StatusServiceUnavailable, retryAfterUnavailable},
+		"not found":  {github.ErrRepoNotFound, http.StatusNotFound, ""},
+		"other":      {errors.New("nope"), http.StatusBadRequest, ""},
+	} {
+		t.Run(name, func(t *testing.T) {
+			is := is.New(t)
+			w := httptest.NewRecorder()
+			var herr httperr.Error
+			is.True(errors.As(fetchErr(w, tt.err, http.StatusBadRequest), &herr))
+			is.Equal(tt.status, herr.Status)
+			is.Equal(tt.retryAfter, w.Header().Get("retry-after"))
+		})
+	}
+}
+
+// testConfig is the default config with a github token, as github is mocked
+// and requests without tokens fail early.
+func testConfig() config.Config {
+	cfg := config.Get()
+	cfg.GitHubTokens = []string{"XXX"}
+	return cfg
+}
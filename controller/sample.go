@@ -0,0 +1,85 @@
+package controller
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/caarlos0/starcharts/internal/github"
+	chart "github.com/wcharczuk/go-chart"
+)
+
+// sampled tells whether the chart of the given repository can be
+// approximated from a sample of its stargazers after fetching all of them
+// failed with err.
+func sampled(gh *github.GitHub, err error) bool {
+	return errors.Is(err, github.ErrTooManyStars) && gh.Sampling()
+}
+
+// sampledChart renders an approximate chart of the given repository, which
+// has too many stargazers to list, from a sample of them.
+//
+// Only the cumulative curve is known, so daily bars are left out.
+// Github doesn't list the latest stars of such repositories, so the line
+// from the last listed star to the star count is dashed, and the
+// x-chart-listed-stars header tells how many stars were sampled from.
+func sampledChart(w http.ResponseWriter, r *http.Request, gh *github.GitHub, repo github.Repository, format chartFormat, opts ChartOptions) (err error) {
+	log := log.WithField("repo", repo.FullName)
+	sample, err := gh.StargazersSample(r.Context(), repo)
+	if err != nil {
+		log.WithError(err).Error("failed to sample stars")
+		return chartErr(w, r, format, err)
+	}
+	listed := gh.ListedStars(repo)
+	points := make([]Point, 0, len(sample))
+	for _, p := range sample {
+		points = append(points, Point{Date: p.Time, Stars: p.Total})
+		if p.Total <= listed && listed < repo.StargazersCount {
+			opts.InterpolatedAfter = p.Time
+		}
+	}
+	opts.Approximate = true
+	opts.DailyBars = false
+	w.Header().Set("x-chart-approximate", "sampled")
+	w.Header().Set("x-chart-listed-stars", strconv.Itoa(listed))
+	defer log.Trace("chart").Stop(&err)
+	if err := writeChart(w, r, format, func(w io.Writer) error {
+		return WriteSeriesChart(w, points, opts)
+	}); err != nil {
+		log.WithError(err).Error("failed to render graph")
+		return err
+	}
+	return nil
+}
+
+// dashInterpolated draws the star line after the given time dashed, as it is
+// interpolated there rather than sampled.
+func dashInterpolated(graph *chart.Chart, after time.Time) {
+	if after.IsZero() || len(graph.Series) == 0 {
+		return
+	}
+	line, ok := graph.Series[0].(chart.TimeSeries)
+	if !ok {
+		return
+	}
+	i := sort.Search(len(line.XValues), func(i int) bool {
+		return !line.XValues[i].Before(after)
+	})
+	if i >= len(line.XValues)-1 {
+		return
+	}
+	tail := chart.TimeSeries{
+		Name:    "Interpolated",
+		Style:   line.Style,
+		XValues: line.XValues[i:],
+		YValues: line.YValues[i:],
+	}
+	tail.Style.StrokeDashArray = []float64{5, 5}
+	line.XValues, line.YValues = line.XValues[:i+1], line.YValues[:i+1]
+	graph.Series[0] = line
+	graph.Series = append(graph.Series, tail)
+}
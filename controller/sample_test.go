@@ -0,0 +1,105 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis"
+	"github.com/caarlos0/starcharts/internal/cache"
+	"github.com/caarlos0/starcharts/internal/github"
+	"github.com/go-redis/redis"
+	"github.com/gorilla/mux"
+	"github.com/matryer/is"
+	chart "github.com/wcharczuk/go-chart"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestSampledChart(t *testing.T) {
+	defer gock.Off()
+	mr, _ := miniredis.Run()
+	rc := redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	})
+	cache := cache.New(rc)
+	defer cache.Close()
+	if err := cache.Put("test/test_details", github.Repository{
+		FullName:        "test/test",
+		StargazersCount: 50000,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	request := func(gh *github.GitHub) *httptest.ResponseRecorder {
+		r := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/test/test.svg", nil), map[string]string{
+			"owner": "test",
+			"repo":  "test",
+		})
+		w := httptest.NewRecorder()
+		GetRepoChart(gh, cache, ChartConfig{}).ServeHTTP(w, r)
+		return w
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		is := is.New(t)
+		w := request(github.New(testConfig(), cache))
+		is.Equal(http.StatusUnprocessableEntity, w.Code)
+		is.True(strings.Contains(w.Body.String(), "too many stars to chart"))
+	})
+
+	t.Run("sampled", func(t *testing.T) {
+		is := is.New(t)
+		gock.New("https://api.github.com").
+			Get("/rate_limit").
+			Persist().
+			Reply(200).
+			JSON(map[string]interface{}{"rate": map[string]int{"limit": 5000, "remaining": 4000}})
+		for page, starred := range map[string]time.Time{
+			"1":   time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC),
+			"400": time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		} {
+			gock.New("https://api.github.com").
+				Get("/repos/test/test/stargazers").
+				MatchParam("page", page).
+				Reply(200).
+				JSON([]github.Stargazer{{StarredAt: starred}, {StarredAt: starred.Add(time.Hour)}})
+		}
+
+		config := testConfig()
+		config.GitHubSamplePages = 2
+		w := request(github.New(config, cache))
+		is.Equal(http.StatusOK, w.Code)
+		is.Equal("sampled", w.Header().Get("x-chart-approximate"))
+		is.Equal("40000", w.Header().Get("x-chart-listed-stars"))                 // should tell how many stars were sampled from
+		is.True(strings.Contains(w.Body.String(), "interpolated after Jan 2020")) // should tell the end is interpolated
+		is.True(strings.Contains(w.Body.String(), "stroke-dasharray"))            // should dash the interpolated line
+		is.True(strings.Contains(w.Body.String(), "Stargazers (approximate)"))    // should be marked as approximate
+		is.True(strings.Contains(w.Body.String(), "50,000 stars"))                // should end at the star count
+		is.True(!gock.HasUnmatchedRequest())                                      // should only fetch the sampled pages
+	})
+}
+
+func TestDashInterpolated(t *testing.T) {
+	is := is.New(t)
+	day := func(d int) time.Time {
+		return time.Date(2020, 1, d, 0, 0, 0, 0, time.UTC)
+	}
+	graph := chart.Chart{Series: []chart.Series{chart.TimeSeries{
+		XValues: []time.Time{day(1), day(2), day(3), day(10)},
+		YValues: []float64{1, 2, 3, 10},
+	}}}
+	dashInterpolated(&graph, day(3))
+	is.Equal(len(graph.Series), 2)
+	line := graph.Series[0].(chart.TimeSeries)
+	tail := graph.Series[1].(chart.TimeSeries)
+	is.Equal(line.XValues, []time.Time{day(1), day(2), day(3)}) // should keep the data solid
+	is.Equal(tail.XValues, []time.Time{day(3), day(10)})        // should join the line
+	is.Equal(tail.YValues, []float64{3, 10})
+	is.True(len(tail.Style.StrokeDashArray) > 0) // should be dashed
+	is.True(line.Style.StrokeDashArray == nil)   // should leave the line alone
+
+	dashInterpolated(&graph, time.Time{})
+	is.Equal(len(graph.Series), 2) // should do nothing without a time
+}
@@ -0,0 +1,91 @@
+package controller
+
+import (
+	"math"
+
+	chart "github.com/wcharczuk/go-chart"
+)
+
+// scaledPNG renders PNG charts scale times larger than their size, so they
+// look crisp on high-DPI displays.
+//
+// The chart is still laid out at its size, and every coordinate, stroke and
+// font is scaled when drawn, so it looks the same as at 1x, only sharper.
+func scaledPNG(scale int) chart.RendererProvider {
+	return func(width, height int) (chart.Renderer, error) {
+		if scale <= 1 {
+			return chart.PNG(width, height)
+		}
+		r, err := chart.PNG(width*scale, height*scale)
+		if err != nil {
+			return nil, err
+		}
+		return scaledRenderer{Renderer: r, scale: float64(scale)}, nil
+	}
+}
+
+// scaledRenderer scales everything drawn on the renderer it wraps.
+type scaledRenderer struct {
+	chart.Renderer
+	scale float64
+}
+
+func (r scaledRenderer) up(v int) int {
+	return int(math.Round(float64(v) * r.scale))
+}
+
+func (r scaledRenderer) down(v int) int {
+	return int(math.Round(float64(v) / r.scale))
+}
+
+func (r scaledRenderer) SetStrokeWidth(width float64) {
+	r.Renderer.SetStrokeWidth(width * r.scale)
+}
+
+func (r scaledRenderer) SetStrokeDashArray(dashArray []float64) {
+	scaled := make([]float64, len(dashArray))
+	for i, dash := range dashArray {
+		scaled[i] = dash * r.scale
+	}
+	r.Renderer.SetStrokeDashArray(scaled)
+}
+
+func (r scaledRenderer) SetFontSize(size float64) {
+	r.Renderer.SetFontSize(size * r.scale)
+}
+
+func (r scaledRenderer) MoveTo(x, y int) {
+	r.Renderer.MoveTo(r.up(x), r.up(y))
+}
+
+func (r scaledRenderer) LineTo(x, y int) {
+	r.Renderer.LineTo(r.up(x), r.up(y))
+}
+
+func (r scaledRenderer) QuadCurveTo(cx, cy, x, y int) {
+	r.Renderer.QuadCurveTo(r.up(cx), r.up(cy), r.up(x), r.up(y))
+}
+
+func (r scaledRenderer) ArcTo(cx, cy int, rx, ry, startAngle, delta float64) {
+	r.Renderer.ArcTo(r.up(cx), r.up(cy), rx*r.scale, ry*r.scale, startAngle, delta)
+}
+
+func (r scaledRenderer) Circle(radius float64, x, y int) {
+	r.Renderer.Circle(radius*r.scale, r.up(x), r.up(y))
+}
+
+func (r scaledRenderer) Text(body string, x, y int) {
+	r.Renderer.Text(body, r.up(x), r.up(y))
+}
+
+// MeasureText measures the text at the size the chart is laid out at.
+func (r scaledRenderer) MeasureText(body string) chart.Box {
+	box := r.Renderer.MeasureText(body)
+	return chart.Box{
+		Top:    r.down(box.Top),
+		Left:   r.down(box.Left),
+		Right:  r.down(box.Right),
+		Bottom: r.down(box.Bottom),
+		IsSet:  box.IsSet,
+	}
+}
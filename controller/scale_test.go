@@ -0,0 +1,78 @@
+package controller
+
+import (
+	"bytes"
+	"image/png"
+	"testing"
+	"time"
+
+	"github.com/caarlos0/starcharts/internal/github"
+	"github.com/matryer/is"
+	chart "github.com/wcharczuk/go-chart"
+)
+
+// drawRecorder records what is drawn on it.
+type drawRecorder struct {
+	chart.Renderer
+	strokeWidth float64
+	dashes      []float64
+	fontSize    float64
+	points      [][2]int
+	radius      float64
+}
+
+func (r *drawRecorder) SetStrokeWidth(width float64)        { r.strokeWidth = width }
+func (r *drawRecorder) SetStrokeDashArray(dashes []float64) { r.dashes = dashes }
+func (r *drawRecorder) SetFontSize(size float64)            { r.fontSize = size }
+func (r *drawRecorder) MoveTo(x, y int)                     { r.points = append(r.points, [2]int{x, y}) }
+func (r *drawRecorder) LineTo(x, y int)                     { r.points = append(r.points, [2]int{x, y}) }
+func (r *drawRecorder) Text(_ string, x, y int)             { r.points = append(r.points, [2]int{x, y}) }
+func (r *drawRecorder) Circle(radius float64, x, y int) {
+	r.radius = radius
+	r.points = append(r.points, [2]int{x, y})
+}
+
+func (r *drawRecorder) MeasureText(string) chart.Box {
+	return chart.Box{Top: 0, Left: 0, Right: 30, Bottom: 12, IsSet: true}
+}
+
+func TestScaledRenderer(t *testing.T) {
+	is := is.New(t)
+	rec := &drawRecorder{}
+	r := scaledRenderer{Renderer: rec, scale: 3}
+
+	r.SetStrokeWidth(2)
+	r.SetStrokeDashArray([]float64{5, 5})
+	r.SetFontSize(10)
+	r.MoveTo(1, 2)
+	r.LineTo(10, 20)
+	r.Text("stars", 4, 5)
+	r.Circle(2, 7, 8)
+
+	is.Equal(6.0, rec.strokeWidth)                                                 // should scale strokes
+	is.Equal([]float64{15, 15}, rec.dashes)                                        // should scale dashes
+	is.Equal(30.0, rec.fontSize)                                                   // should scale fonts
+	is.Equal(6.0, rec.radius)                                                      // should scale circles
+	is.Equal([][2]int{{3, 6}, {30, 60}, {12, 15}, {21, 24}}, rec.points)           // should scale coordinates
+	is.Equal(chart.Box{Right: 10, Bottom: 4, IsSet: true}, r.MeasureText("stars")) // should measure at the laid out size
+}
+
+func TestScaledPNG(t *testing.T) {
+	stargazers := []github.Stargazer{
+		{StarredAt: time.Now().Add(-48 * time.Hour)},
+		{StarredAt: time.Now()},
+	}
+	for _, scale := range []int{0, 1, 2, 3} {
+		is := is.New(t)
+		var buf bytes.Buffer
+		is.NoErr(WriteChart(&buf, stargazers, ChartOptions{Raster: true, Scale: scale, Width: 400, Height: 200}))
+		img, err := png.Decode(&buf)
+		is.NoErr(err)
+		factor := scale
+		if factor < 1 {
+			factor = 1
+		}
+		is.Equal(400*factor, img.Bounds().Dx()) // should be scaled up
+		is.Equal(200*factor, img.Bounds().Dy())
+	}
+}
@@ -0,0 +1,186 @@
+package controller
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/apex/log"
+)
+
+// staleHeader tells clients the chart is the last one rendered, served
+// because a fresh one couldn't be.
+const staleHeader = "x-chart-cache"
+
+// chartParams are the query parameters changing how a chart looks, the only
+// ones the cached charts are keyed on.
+var chartParams = func() map[string]bool {
+	params := map[string]bool{"band": true}
+	for key := range chartQueryNormalizers(ChartDefaults{}) {
+		params[key] = true
+	}
+	return params
+}()
+
+// lastChartKey is the cache key of the last chart rendered for the request,
+// which depends on the repository, format and chart options. Other query
+// parameters are ignored, so they can't fill the cache.
+func lastChartKey(r *http.Request) string {
+	query := url.Values{}
+	for key, values := range r.URL.Query() {
+		if chartParams[key] {
+			query[key] = values
+		}
+	}
+	h := sha256.Sum256([]byte(strings.ToLower(r.URL.Path) + "?" + query.Encode()))
+	return "last_chart_" + hex.EncodeToString(h[:16])
+}
+
+// lastChartEtagKey is the cache key of the etag of the last chart rendered
+// for the request, kept while the cached chart is fresh.
+func lastChartEtagKey(r *http.Request) string {
+	return lastChartKey(r) + "_etag"
+}
+
+// freshChart tells whether the cached chart of the request is the one with
+// the given etag, and was cached recently enough not to be cached again.
+func freshChart(r *http.Request, format chartFormat, etag string) bool {
+	if etag == "" {
+		return false
+	}
+	var cached string
+	return format.cache.Get(lastChartEtagKey(r), &cached) == nil && cached == etag
+}
+
+// cachesCharts tells whether the rendered charts are cached.
+func cachesCharts(format chartFormat) bool {
+	return format.config.StaleTTL > 0 && format.cache != nil
+}
+
+// gzipsChart tells whether the chart is sent gzipped: SVG charts are cached
+// gzipped, so clients accepting gzip get them without compressing them again.
+func gzipsChart(r *http.Request, format chartFormat) bool {
+	return cachesCharts(format) && !format.raster &&
+		acceptsEncoding(r.Header.Get("Accept-Encoding"), "gzip")
+}
+
+// gzipEtag is the etag of the gzipped chart with the given etag.
+func gzipEtag(etag string) string {
+	return strings.TrimSuffix(etag, `"`) + `-gzip"`
+}
+
+// plainEtag is the etag of the chart with the given, maybe gzipped, etag.
+func plainEtag(etag string) string {
+	if !strings.HasSuffix(etag, `-gzip"`) {
+		return etag
+	}
+	return strings.TrimSuffix(etag, `-gzip"`) + `"`
+}
+
+// writeChart renders the chart into w, keeping a copy of it in the cache to
+// serve if rendering the next one fails.
+func writeChart(w http.ResponseWriter, r *http.Request, format chartFormat, render func(w io.Writer) error) error {
+	if !cachesCharts(format) {
+		return render(w)
+	}
+	var buf bytes.Buffer
+	if err := render(&buf); err != nil {
+		return err
+	}
+	chart := buf.Bytes()
+	if !format.raster {
+		var gz bytes.Buffer
+		zw := gzip.NewWriter(&gz)
+		if _, err := zw.Write(chart); err != nil {
+			return err
+		}
+		if err := zw.Close(); err != nil {
+			return err
+		}
+		chart = gz.Bytes()
+	}
+	if etag := plainEtag(w.Header().Get("etag")); !freshChart(r, format, etag) {
+		cacheChart(r, format, chart, etag)
+	}
+	if !gzipsChart(r, format) {
+		chart = buf.Bytes()
+	}
+	return sendChart(w, r, format, chart)
+}
+
+// cacheChart caches the given chart for the stale ttl, and its etag for half
+// of it: the chart is cached again once its etag expires, so it doesn't have
+// to be written on every render.
+func cacheChart(r *http.Request, format chartFormat, chart []byte, etag string) {
+	log := log.WithField("url", r.URL.String())
+	if err := format.cache.PutWithTTL(lastChartKey(r), chart, format.config.StaleTTL); err != nil {
+		log.WithError(err).Warn("failed to cache chart")
+		return
+	}
+	ttl := format.config.StaleTTL / 2
+	if etag == "" || ttl <= 0 {
+		return
+	}
+	if err := format.cache.PutWithTTL(lastChartEtagKey(r), etag, ttl); err != nil {
+		log.WithError(err).Warn("failed to cache chart etag")
+	}
+}
+
+// sendChart writes the given cached chart, gzipped if the client accepts it,
+// decompressing it otherwise.
+func sendChart(w http.ResponseWriter, r *http.Request, format chartFormat, chart []byte) error {
+	gzipped := isGzip(chart)
+	if gzipped && !gzipsChart(r, format) {
+		zr, err := gzip.NewReader(bytes.NewReader(chart))
+		if err != nil {
+			return err
+		}
+		if chart, err = io.ReadAll(zr); err != nil {
+			return err
+		}
+		gzipped = false
+	}
+	if gzipped {
+		w.Header().Set("content-encoding", "gzip")
+	}
+	w.Header().Set("content-length", strconv.Itoa(len(chart)))
+	if r.Method == http.MethodHead {
+		return nil
+	}
+	_, err := w.Write(chart)
+	return err
+}
+
+// isGzip tells whether the given bytes start with the gzip magic number.
+// Charts cached before they were gzipped don't.
+func isGzip(bts []byte) bool {
+	return len(bts) > 1 && bts[0] == 0x1f && bts[1] == 0x8b
+}
+
+// serveLastChart writes the last chart rendered for the request, if it is
+// still cached, telling whether it did.
+func serveLastChart(w http.ResponseWriter, r *http.Request, format chartFormat) bool {
+	if !cachesCharts(format) {
+		return false
+	}
+	var chart []byte
+	if err := format.cache.Get(lastChartKey(r), &chart); err != nil {
+		return false
+	}
+	log.WithField("url", r.URL.String()).Warn("serving stale chart")
+	w.Header().Set("content-type", format.contentType)
+	w.Header().Set("cache-control", "no-cache")
+	w.Header().Set(staleHeader, "stale-on-error")
+	w.Header().Del("etag")
+	w.Header().Del("x-chart-downsampled")
+	if err := sendChart(w, r, format, chart); err != nil {
+		log.WithError(err).WithField("url", r.URL.String()).Warn("failed to send stale chart")
+	}
+	return true
+}
@@ -0,0 +1,155 @@
+package controller
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis"
+	"github.com/caarlos0/starcharts/internal/cache"
+	"github.com/caarlos0/starcharts/internal/github"
+	"github.com/go-redis/redis"
+	"github.com/gorilla/mux"
+	"github.com/matryer/is"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestStaleChart(t *testing.T) {
+	defer gock.Off()
+
+	mr, _ := miniredis.Run()
+	rc := redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	})
+	cache := cache.New(rc)
+	defer cache.Close()
+	gh := github.New(testConfig(), cache)
+
+	gock.New("https://api.github.com").
+		Get("/rate_limit").
+		Persist().
+		Reply(200).
+		JSON(map[string]interface{}{"rate": map[string]int{"limit": 5000, "remaining": 4000}})
+	gock.New("https://api.github.com").
+		Get("/repos/test/test/stargazers").
+		Reply(200).
+		JSON([]github.Stargazer{{StarredAt: time.Now().Add(-time.Hour)}})
+
+	setDetails := func(stars int) {
+		if err := cache.Put("test/test_details", github.Repository{
+			FullName:        "test/test",
+			CreatedAt:       "2008-02-28T20:40:04Z",
+			StargazersCount: stars,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	request := func(handler http.Handler, path string, encodings ...string) *httptest.ResponseRecorder {
+		r := mux.SetURLVars(httptest.NewRequest(http.MethodGet, path, nil), map[string]string{
+			"owner": "test",
+			"repo":  "test",
+		})
+		r.Header.Set("Accept-Encoding", strings.Join(encodings, ", "))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	handler := GetRepoChart(gh, cache, ChartConfig{StaleTTL: time.Hour})
+	setDetails(1)
+	fresh := request(handler, "/test/test.svg")
+	is.New(t).Equal(http.StatusOK, fresh.Code)
+	is.New(t).Equal("", fresh.Header().Get(staleHeader))
+	gunzip := func(t *testing.T, w *httptest.ResponseRecorder) string {
+		t.Helper()
+		is := is.New(t)
+		is.Equal("gzip", w.Header().Get("content-encoding"))
+		is.Equal(strconv.Itoa(w.Body.Len()), w.Header().Get("content-length")) // should be the compressed length
+		zr, err := gzip.NewReader(w.Body)
+		is.NoErr(err)
+		bts, err := io.ReadAll(zr)
+		is.NoErr(err)
+		return string(bts)
+	}
+
+	t.Run("fresh gzipped", func(t *testing.T) {
+		is := is.New(t)
+		w := request(handler, "/test/test.svg", "br", "gzip")
+		is.Equal(http.StatusOK, w.Code)
+		is.Equal(gzipEtag(fresh.Header().Get("etag")), w.Header().Get("etag"))
+		is.Equal(fresh.Body.String(), gunzip(t, w))
+	})
+
+	t.Run("cached once while fresh", func(t *testing.T) {
+		is := is.New(t)
+		key := lastChartKey(httptest.NewRequest(http.MethodGet, "/test/test.svg", nil))
+		is.Equal(time.Hour, mr.TTL(key))              // should have cached the chart
+		is.Equal(30*time.Minute, mr.TTL(key+"_etag")) // should have cached its etag for half as long
+
+		mr.FastForward(20 * time.Minute)
+		is.Equal(http.StatusOK, request(handler, "/test/test.svg").Code)
+		is.Equal(40*time.Minute, mr.TTL(key)) // should not have cached the same chart again
+
+		mr.FastForward(20 * time.Minute)
+		is.Equal(http.StatusOK, request(handler, "/test/test.svg").Code)
+		is.Equal(time.Hour, mr.TTL(key)) // should have cached the chart again once its etag expired
+	})
+
+	// too many stars to fetch, so rendering a fresh chart fails.
+	setDetails(1000000)
+
+	t.Run("stale on error", func(t *testing.T) {
+		is := is.New(t)
+		w := request(handler, "/test/test.svg")
+		is.Equal(http.StatusOK, w.Code)
+		is.Equal("stale-on-error", w.Header().Get(staleHeader))
+		is.Equal("no-cache", w.Header().Get("cache-control"))
+		is.Equal("", w.Header().Get("etag"))
+		is.Equal(fresh.Body.String(), w.Body.String())
+	})
+
+	t.Run("stale on error gzipped", func(t *testing.T) {
+		is := is.New(t)
+		w := request(handler, "/test/test.svg", "gzip")
+		is.Equal("stale-on-error", w.Header().Get(staleHeader))
+		is.Equal(fresh.Body.String(), gunzip(t, w))
+	})
+
+	t.Run("unknown options", func(t *testing.T) {
+		is := is.New(t)
+		w := request(handler, "/test/test.svg?x=123")
+		is.Equal("stale-on-error", w.Header().Get(staleHeader))
+		is.Equal(fresh.Body.String(), w.Body.String())
+	})
+
+	t.Run("other options", func(t *testing.T) {
+		is := is.New(t)
+		w := request(handler, "/test/test.svg?theme=dark")
+		is.Equal(http.StatusUnprocessableEntity, w.Code)
+		is.Equal("", w.Header().Get(staleHeader))
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		is := is.New(t)
+		w := request(GetRepoChart(gh, cache, ChartConfig{}), "/test/test.svg")
+		is.Equal(http.StatusUnprocessableEntity, w.Code)
+		is.Equal("", w.Header().Get(staleHeader))
+	})
+}
+
+func TestLastChartKey(t *testing.T) {
+	key := func(url string) string {
+		return lastChartKey(httptest.NewRequest(http.MethodGet, url, nil))
+	}
+	is := is.New(t)
+	is.Equal(key("/a/b.svg"), key("/a/b.svg?x=1&utm_source=readme"))       // should ignore unknown parameters
+	is.Equal(key("/a/b.svg?theme=dark"), key("/a/b.svg?theme=dark&x=2"))   // should ignore unknown parameters
+	is.True(key("/a/b.svg") != key("/a/b.svg?theme=dark"))                 // should depend on the chart options
+	is.True(key("/a/b.svg") != key("/a/b.png"))                            // should depend on the format
+	is.True(key("/a/b.svg") != key("/a/b.svg?band=2020-01-01/2020-02-01")) // should depend on the bands
+}
@@ -0,0 +1,45 @@
+package controller
+
+import (
+	"math"
+	"strconv"
+
+	chart "github.com/wcharczuk/go-chart"
+)
+
+// minStrokeWidth and maxStrokeWidth bound the stroke_width query parameter.
+const (
+	minStrokeWidth = 1
+	maxStrokeWidth = 10
+)
+
+// parseStrokeWidth parses the stroke_width query parameter, clamping it to
+// [minStrokeWidth, maxStrokeWidth]. It returns 0 if it isn't set or valid.
+func parseStrokeWidth(value string) float64 {
+	width, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(width) || width <= 0 {
+		return 0
+	}
+	return math.Min(math.Max(width, minStrokeWidth), maxStrokeWidth)
+}
+
+// applyStrokeWidth sets the width of the star line of the graph, scaled up
+// for charts larger than the default size, given in pixels, so a thin line
+// doesn't vanish on a huge chart.
+// It is rounded to whole pixels, as SVG charts truncate it.
+// A zero width keeps the default.
+func applyStrokeWidth(graph *chart.Chart, width float64, chartWidth, chartHeight int) {
+	if width <= 0 || len(graph.Series) == 0 {
+		return
+	}
+	series, ok := graph.Series[0].(chart.TimeSeries)
+	if !ok {
+		return
+	}
+	scale := math.Max(
+		float64(chartWidth)/chart.DefaultChartWidth,
+		float64(chartHeight)/chart.DefaultChartHeight,
+	)
+	series.Style.StrokeWidth = math.Round(width * math.Max(scale, 1))
+	graph.Series[0] = series
+}
@@ -0,0 +1,55 @@
+package controller
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/caarlos0/starcharts/internal/github"
+	"github.com/matryer/is"
+)
+
+func TestParseStrokeWidth(t *testing.T) {
+	for value, expected := range map[string]float64{
+		"":    0,
+		"abc": 0,
+		"0":   0,
+		"-1":  0,
+		"NaN": 0,
+		"0.5": minStrokeWidth,
+		"1.5": 1.5,
+		"4":   4,
+		"100": maxStrokeWidth,
+	} {
+		t.Run(value, func(t *testing.T) {
+			is := is.New(t)
+			is.Equal(expected, parseStrokeWidth(value))
+		})
+	}
+}
+
+func TestWriteChartStrokeWidth(t *testing.T) {
+	stargazers := []github.Stargazer{
+		{StarredAt: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{StarredAt: time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range []struct {
+		opts     ChartOptions
+		expected float64
+	}{
+		{ChartOptions{}, 2},
+		{ChartOptions{StrokeWidth: 5}, 5},
+		{ChartOptions{StrokeWidth: 4, Width: 512, Height: 200}, 4},
+		{ChartOptions{StrokeWidth: 3, Width: 2048}, 6},
+		{ChartOptions{StrokeWidth: 1.5, Width: 2048}, 3},
+	} {
+		t.Run(fmt.Sprintf("%v@%dx%d", tt.opts.StrokeWidth, tt.opts.Width, tt.opts.Height), func(t *testing.T) {
+			is := is.New(t)
+			var b bytes.Buffer
+			is.NoErr(WriteChart(&b, stargazers, tt.opts))
+			is.True(strings.Contains(b.String(), fmt.Sprintf("stroke-width:%v;stroke:rgba(129,199,239,1.0)", tt.expected)))
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package controller
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+
+	chart "github.com/wcharczuk/go-chart"
+)
+
+// svgPath matches the data of the paths go-chart writes.
+// nolint: gochecknoglobals
+var svgPath = regexp.MustCompile(` d="([^"]*)"`)
+
+// renderSVG renders the graph as a compact SVG document.
+func renderSVG(graph chart.Chart) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := graph.Render(chart.SVG, &buf); err != nil {
+		return nil, err
+	}
+	return compactSVG(buf.Bytes()), nil
+}
+
+// compactSVG shrinks the paths of the given SVG document without changing
+// how it looks.
+//
+// go-chart already writes whole pixel coordinates, but writes a command for
+// every point of a series, even when many of them land on the same pixel or
+// along the same straight line, as with big repositories. Those points are
+// dropped, and the commands are written without the optional whitespace.
+func compactSVG(svg []byte) []byte {
+	return svgPath.ReplaceAllFunc(svg, func(attr []byte) []byte {
+		d := string(attr[len(` d="`) : len(attr)-1])
+		return []byte(` d="` + compactPath(d) + `"`)
+	})
+}
+
+type pathCommand struct {
+	op   byte
+	x, y int
+}
+
+// compactPath drops the redundant line commands of the given path data.
+//
+// Only paths made of move, line and close commands are compacted, others are
+// returned as is.
+func compactPath(d string) string {
+	var cmds []pathCommand
+	for _, field := range strings.Split(d, "\n") {
+		cmd, ok := parsePathCommand(field)
+		if !ok {
+			return d
+		}
+		n := len(cmds)
+		if cmd.op == 'L' && n > 0 && cmds[n-1].op == 'L' {
+			last := cmds[n-1]
+			if last.x == cmd.x && last.y == cmd.y {
+				continue
+			}
+			if n > 1 && cmds[n-2].op != 'Z' && continuesLine(cmds[n-2], last, cmd) {
+				cmds[n-1] = cmd
+				continue
+			}
+		}
+		cmds = append(cmds, cmd)
+	}
+
+	var b strings.Builder
+	b.Grow(len(d) / 2)
+	for _, cmd := range cmds {
+		b.WriteByte(cmd.op)
+		if cmd.op == 'Z' {
+			continue
+		}
+		b.WriteString(strconv.Itoa(cmd.x))
+		b.WriteByte(' ')
+		b.WriteString(strconv.Itoa(cmd.y))
+	}
+	return b.String()
+}
+
+// parsePathCommand parses a "M x y", "L x y" or "Z" command.
+func parsePathCommand(field string) (pathCommand, bool) {
+	parts := strings.Fields(field)
+	if len(parts) == 1 && parts[0] == "Z" {
+		return pathCommand{op: 'Z'}, true
+	}
+	if len(parts) != 3 || (parts[0] != "M" && parts[0] != "L") {
+		return pathCommand{}, false
+	}
+	x, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return pathCommand{}, false
+	}
+	y, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return pathCommand{}, false
+	}
+	return pathCommand{op: parts[0][0], x: x, y: y}, true
+}
+
+// continuesLine tells whether c lies on the line from a through b, past b, so
+// the line could go from a to c directly.
+func continuesLine(a, b, c pathCommand) bool {
+	dx1, dy1 := b.x-a.x, b.y-a.y
+	dx2, dy2 := c.x-b.x, c.y-b.y
+	return dx1*dy2 == dy1*dx2 && dx1*dx2+dy1*dy2 > 0
+}
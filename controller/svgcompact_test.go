@@ -0,0 +1,50 @@
+package controller
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/matryer/is"
+	chart "github.com/wcharczuk/go-chart"
+)
+
+func TestCompactPath(t *testing.T) {
+	for name, tt := range map[string]struct {
+		d      string
+		expect string
+	}{
+		"whitespace":     {"M 0 10\nL 5 3", "M0 10L5 3"},
+		"same pixel":     {"M 0 0\nL 1 1\nL 1 1\nL 2 3", "M0 0L1 1L2 3"},
+		"straight line":  {"M 0 0\nL 1 0\nL 2 0\nL 3 0\nL 3 5", "M0 0L3 0L3 5"},
+		"diagonal":       {"M 0 0\nL 1 2\nL 2 4\nL 4 8", "M0 0L4 8"},
+		"turning back":   {"M 0 0\nL 2 0\nL 1 0", "M0 0L2 0L1 0"},
+		"after a move":   {"M 0 0\nL 1 0\nM 2 0\nL 3 0", "M0 0L1 0M2 0L3 0"},
+		"closed":         {"M 0 0\nL 1 0\nL 2 0\nZ\nL 3 0", "M0 0L2 0ZL3 0"},
+		"curves kept":    {"M 0 0\nQ1,1 2,2", "M 0 0\nQ1,1 2,2"},
+		"invalid number": {"M 0 x", "M 0 x"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			is.New(t).Equal(compactPath(tt.d), tt.expect)
+		})
+	}
+}
+
+func TestCompactSVG(t *testing.T) {
+	is := is.New(t)
+	start := time.Now().Add(-365 * 24 * time.Hour)
+	var points []Point
+	for i := 0; i < 20000; i++ {
+		points = append(points, Point{
+			Date:  start.Add(time.Duration(i) * 25 * time.Minute),
+			Stars: i + 1,
+		})
+	}
+	graph := buildGraph(log.Log, points, 0, lineColor)
+	var buf bytes.Buffer
+	is.NoErr(graph.Render(chart.SVG, &buf))
+	compact := compactSVG(buf.Bytes())
+	is.True(len(compact) < buf.Len()/4) // should be much smaller
+	is.True(bytes.HasSuffix(bytes.TrimSpace(compact), []byte("</svg>")))
+}
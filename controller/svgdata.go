@@ -0,0 +1,32 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+)
+
+var errNotSVG = errors.New("not a svg document")
+
+// embedData embeds the given points as JSON inside a metadata element of the
+// given SVG document, so the exact values can be extracted from the same
+// file shown to users.
+func embedData(svg []byte, points []Point) ([]byte, error) {
+	end := bytes.LastIndex(svg, []byte("</svg>"))
+	if end < 0 {
+		return nil, errNotSVG
+	}
+	data, err := json.Marshal(points)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(len(svg) + len(data) + 64)
+	buf.Write(svg[:end])
+	buf.WriteString(`<metadata id="starcharts-data"><![CDATA[`)
+	buf.Write(data)
+	buf.WriteString(`]]></metadata>`)
+	buf.Write(svg[end:])
+	return buf.Bytes(), nil
+}
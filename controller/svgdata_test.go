@@ -0,0 +1,34 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestEmbedData(t *testing.T) {
+	is := is.New(t)
+	points := []Point{
+		{Date: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), Stars: 1},
+		{Date: time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC), Stars: 2},
+	}
+	svg, err := embedData([]byte(`<svg><path/></svg>`), points)
+	is.NoErr(err)
+	is.True(bytes.HasSuffix(svg, []byte(`]]></metadata></svg>`))) // should be inside the svg element
+
+	match := regexp.MustCompile(`<!\[CDATA\[(.*)\]\]>`).FindSubmatch(svg)
+	is.True(match != nil) // should have embedded data
+	var result []Point
+	is.NoErr(json.Unmarshal(match[1], &result))
+	is.Equal(points, result)
+}
+
+func TestEmbedDataNotSVG(t *testing.T) {
+	is := is.New(t)
+	_, err := embedData([]byte(`not a svg`), nil)
+	is.Equal(errNotSVG, err)
+}
@@ -0,0 +1,133 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/golang/freetype/truetype"
+	chart "github.com/wcharczuk/go-chart"
+	"github.com/wcharczuk/go-chart/drawing"
+)
+
+// ChartDefaults are the chart looks used when the request doesn't set them.
+// Zero values fall back to the built-in defaults.
+type ChartDefaults struct {
+	// Theme is the name of one of the chartThemes.
+	Theme string
+	// LineColor is the color of the star line.
+	LineColor drawing.Color
+	// Width and Height are the chart size, before scaling.
+	Width  int
+	Height int
+	// Font is used for all the chart text.
+	Font *truetype.Font
+	// CalendarTicks puts the x axis ticks on calendar boundaries, unless
+	// the xticks query parameter is set.
+	CalendarTicks bool
+}
+
+const (
+	defaultTheme = "light"
+	// minChartSize and maxChartSize bound the width and height query
+	// parameters.
+	minChartSize = 200
+	maxChartSize = 2000
+)
+
+// chartTheme holds the colors of a chart.
+type chartTheme struct {
+	background drawing.Color
+	axis       drawing.Color
+	text       drawing.Color
+}
+
+// nolint: gochecknoglobals
+var chartThemes = map[string]chartTheme{
+	"light": {
+		background: drawing.ColorWhite,
+		axis:       drawing.Color{R: 85, G: 85, B: 85, A: 255},
+		text:       chart.DefaultTextColor,
+	},
+	"dark": {
+		background: drawing.ColorFromHex("0d1117"),
+		axis:       drawing.ColorFromHex("8b949e"),
+		text:       drawing.ColorFromHex("c9d1d9"),
+	},
+}
+
+// applyChartDefaults sets the theme, line color, size and font options,
+// taking them from the query parameters, then from the server defaults, and
+// falling back to the built-in defaults.
+func applyChartDefaults(r *http.Request, opts *ChartOptions, defaults ChartDefaults) {
+	opts.Theme = defaults.Theme
+	if _, ok := chartThemes[r.URL.Query().Get("theme")]; ok {
+		opts.Theme = r.URL.Query().Get("theme")
+	}
+	if _, ok := chartThemes[opts.Theme]; !ok {
+		opts.Theme = defaultTheme
+	}
+
+	opts.LineColor = defaults.LineColor
+	if color, err := ParseColor(r.URL.Query().Get("color")); err == nil {
+		opts.LineColor = color
+	}
+	if opts.LineColor.IsZero() {
+		opts.LineColor = lineColor
+	}
+
+	opts.Width = chartSize(r.URL.Query().Get("width"), defaults.Width, chart.DefaultChartWidth)
+	opts.Height = chartSize(r.URL.Query().Get("height"), defaults.Height, chart.DefaultChartHeight)
+	opts.Font = defaults.Font
+
+	opts.CalendarTicks = defaults.CalendarTicks
+	if xticks := r.URL.Query().Get("xticks"); xticks != "" {
+		opts.CalendarTicks = xticks == "calendar"
+	}
+}
+
+// ParseColor parses a hex color, e.g. 81c7ef or #81c7ef.
+func ParseColor(hex string) (drawing.Color, error) {
+	hex = strings.TrimPrefix(hex, "#")
+	if !hexColorRe.MatchString(hex) {
+		return drawing.Color{}, fmt.Errorf("invalid color: %q", hex)
+	}
+	return drawing.ColorFromHex(hex), nil
+}
+
+// chartSize parses a size query parameter, clamping it to
+// [minChartSize, maxChartSize], and falling back to def, or builtin if def
+// isn't set.
+func chartSize(value string, def, builtin int) int {
+	size, err := strconv.Atoi(value)
+	if err != nil || size < 1 {
+		size = def
+	}
+	if size < 1 {
+		return builtin
+	}
+	if size < minChartSize {
+		return minChartSize
+	}
+	if size > maxChartSize {
+		return maxChartSize
+	}
+	return size
+}
+
+// applyTheme colors the graph with the given theme.
+func applyTheme(graph *chart.Chart, name string) {
+	theme, ok := chartThemes[name]
+	if !ok {
+		return
+	}
+	graph.Background.FillColor = theme.background
+	graph.Canvas.FillColor = theme.background
+	for _, axis := range []*chart.Style{&graph.XAxis.Style, &graph.YAxis.Style} {
+		axis.StrokeColor = theme.axis
+		axis.FontColor = theme.text
+	}
+	graph.XAxis.NameStyle.FontColor = theme.text
+	graph.YAxis.NameStyle.FontColor = theme.text
+}
@@ -0,0 +1,107 @@
+package controller
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/caarlos0/starcharts/internal/github"
+	"github.com/matryer/is"
+	chart "github.com/wcharczuk/go-chart"
+	"github.com/wcharczuk/go-chart/drawing"
+)
+
+func TestChartDefaults(t *testing.T) {
+	defaults := ChartDefaults{
+		Theme:     "dark",
+		LineColor: drawing.ColorFromHex("ff0000"),
+		Width:     800,
+		Height:    300,
+	}
+
+	for name, tt := range map[string]struct {
+		query    string
+		defaults ChartDefaults
+		theme    string
+		color    drawing.Color
+		width    int
+		height   int
+	}{
+		"built-in defaults": {
+			theme:  "light",
+			color:  lineColor,
+			width:  chart.DefaultChartWidth,
+			height: chart.DefaultChartHeight,
+		},
+		"config defaults": {
+			defaults: defaults,
+			theme:    "dark",
+			color:    drawing.ColorFromHex("ff0000"),
+			width:    800,
+			height:   300,
+		},
+		"params override config": {
+			query:    "?theme=light&color=00ff00&width=640&height=480",
+			defaults: defaults,
+			theme:    "light",
+			color:    drawing.ColorFromHex("00ff00"),
+			width:    640,
+			height:   480,
+		},
+		"invalid params fall back to config": {
+			query:    "?theme=neon&color=red&width=abc",
+			defaults: defaults,
+			theme:    "dark",
+			color:    drawing.ColorFromHex("ff0000"),
+			width:    800,
+			height:   300,
+		},
+		"sizes are clamped": {
+			query:  "?width=10&height=100000",
+			theme:  "light",
+			color:  lineColor,
+			width:  minChartSize,
+			height: maxChartSize,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			is := is.New(t)
+			r := httptest.NewRequest(http.MethodGet, "/foo/bar.svg"+tt.query, nil)
+			opts := chartOptions(r, chartFormat{config: ChartConfig{Defaults: tt.defaults}}, 0)
+			is.Equal(tt.theme, opts.Theme)
+			is.Equal(tt.color, opts.LineColor)
+			is.Equal(tt.width, opts.Width)
+			is.Equal(tt.height, opts.Height)
+		})
+	}
+}
+
+func TestWriteChart_Theme(t *testing.T) {
+	stargazers := []github.Stargazer{
+		{StarredAt: time.Now().Add(-time.Hour)},
+		{StarredAt: time.Now()},
+	}
+
+	is := is.New(t)
+	var buf bytes.Buffer
+	is.NoErr(WriteChart(&buf, stargazers, ChartOptions{
+		Theme:     "dark",
+		LineColor: drawing.ColorFromHex("ff0000"),
+		Width:     640,
+		Height:    480,
+	}))
+	is.True(bytes.Contains(buf.Bytes(), []byte(`width="640" height="480"`))) // should use the size
+	is.True(bytes.Contains(buf.Bytes(), []byte("fill:rgba(13,17,23,1.0)")))  // should use the dark background
+	is.True(bytes.Contains(buf.Bytes(), []byte("stroke:rgba(255,0,0,1.0)"))) // should use the line color
+}
+
+func TestParseColor(t *testing.T) {
+	is := is.New(t)
+	color, err := ParseColor("#81c7ef")
+	is.NoErr(err)
+	is.Equal(lineColor, color)
+	_, err = ParseColor("blue")
+	is.True(err != nil) // should reject non hex colors
+}
@@ -0,0 +1,149 @@
+package controller
+
+import (
+	"math"
+	"strconv"
+
+	chart "github.com/wcharczuk/go-chart"
+)
+
+const (
+	// minTicks and maxTicks bound the xticks and yticks query parameters.
+	minTicks = 2
+	maxTicks = 20
+	// defaultYTicks is the amount of y axis ticks when none is given.
+	defaultYTicks = 6
+)
+
+// parseTicks parses a tick count query parameter, clamping it to
+// [minTicks, maxTicks]. It returns 0 if it isn't set.
+func parseTicks(value string) int {
+	ticks, err := strconv.Atoi(value)
+	if err != nil || ticks < 1 {
+		return 0
+	}
+	if ticks < minTicks {
+		return minTicks
+	}
+	if ticks > maxTicks {
+		return maxTicks
+	}
+	return ticks
+}
+
+// applyTicks sets about the given amount of ticks on the graph axes: evenly
+// spaced dates on the x axis and round numbers on the y axis.
+// A zero xticks keeps the default x axis ticks.
+func applyTicks(graph *chart.Chart, xticks, yticks int) {
+	_, _, minY, maxY, ok := seriesBounds(graph.Series)
+	if !ok {
+		return
+	}
+	minX, maxX, _ := xBounds(graph)
+	if yticks == 0 {
+		yticks = defaultYTicks
+	}
+	for _, value := range niceTicks(minY, maxY, yticks) {
+		graph.YAxis.Ticks = append(graph.YAxis.Ticks, chart.Tick{
+			Value: value,
+			Label: IntValueFormatter(value),
+		})
+	}
+	if xticks == 0 || maxX <= minX {
+		return
+	}
+	format := graph.XAxis.ValueFormatter
+	if format == nil {
+		format = chart.TimeValueFormatter
+	}
+	step := (maxX - minX) / float64(xticks-1)
+	for i := 0; i < xticks; i++ {
+		value := minX + step*float64(i)
+		graph.XAxis.Ticks = append(graph.XAxis.Ticks, chart.Tick{
+			Value: value,
+			Label: format(value),
+		})
+	}
+}
+
+// seriesBounds returns the minimum and maximum values of the given series.
+func seriesBounds(series []chart.Series) (minX, maxX, minY, maxY float64, ok bool) {
+	minX, minY = math.MaxFloat64, math.MaxFloat64
+	maxX, maxY = -math.MaxFloat64, -math.MaxFloat64
+	visit := func(x float64, ys ...float64) {
+		ok = true
+		minX, maxX = math.Min(minX, x), math.Max(maxX, x)
+		for _, y := range ys {
+			minY, maxY = math.Min(minY, y), math.Max(maxY, y)
+		}
+	}
+	for _, s := range series {
+		switch vp := s.(type) {
+		case chart.BoundedValuesProvider:
+			for i := 0; i < vp.Len(); i++ {
+				x, y1, y2 := vp.GetBoundedValues(i)
+				visit(x, y1, y2)
+			}
+		case chart.ValuesProvider:
+			for i := 0; i < vp.Len(); i++ {
+				x, y := vp.GetValues(i)
+				visit(x, y)
+			}
+		}
+	}
+	return
+}
+
+// niceTicks returns about n round tick values covering [min, max], e.g.
+// 0, 2000, 4000 instead of 0, 1873, 3746.
+//
+// See Heckbert's "Nice Numbers for Graph Labels", Graphics Gems, 1990.
+func niceTicks(min, max float64, n int) []float64 {
+	if n < minTicks {
+		n = minTicks
+	}
+	if max <= min {
+		max = min + 1
+	}
+	_, step := niceCeiling(max-min, n)
+	first := math.Floor(min/step) * step
+	last := math.Ceil(max/step) * step
+	var ticks []float64
+	for i := 0; first+step*float64(i) <= last+step/2; i++ {
+		ticks = append(ticks, first+step*float64(i))
+	}
+	return ticks
+}
+
+// niceCeiling rounds the given data maximum up to a nice value for the top of
+// the y axis, e.g. 5000 for 4873, returning it along with the round step of
+// about n ticks from zero to it.
+func niceCeiling(max float64, n int) (ceiling, step float64) {
+	if n < minTicks {
+		n = minTicks
+	}
+	if max <= 0 {
+		max = 1
+	}
+	step = niceNumber(niceNumber(max, false)/float64(n-1), true)
+	return math.Ceil(max/step) * step, step
+}
+
+// niceNumber returns a number close to x which is 1, 2 or 5 times a power of
+// ten, rounding it if round is true, or taking its ceiling otherwise.
+func niceNumber(x float64, round bool) float64 {
+	exp := math.Floor(math.Log10(x))
+	fraction := x / math.Pow(10, exp)
+	var nice float64
+	switch {
+	case round && fraction < 1.5, !round && fraction <= 1:
+		nice = 1
+	case round && fraction < 3, !round && fraction <= 2:
+		nice = 2
+	case round && fraction < 7, !round && fraction <= 5:
+		nice = 5
+	default:
+		nice = 10
+	}
+	return nice * math.Pow(10, exp)
+}
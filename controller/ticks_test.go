@@ -0,0 +1,66 @@
+package controller
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestNiceTicks(t *testing.T) {
+	for _, tt := range []struct {
+		min, max float64
+		n        int
+		expected []float64
+	}{
+		{0, 3746, 3, []float64{0, 2000, 4000}},
+		{0, 1873, 6, []float64{0, 500, 1000, 1500, 2000}},
+		{120, 980, 5, []float64{0, 200, 400, 600, 800, 1000}},
+		{0, 7, 4, []float64{0, 5, 10}},
+		{10, 10, 2, []float64{10, 11}},
+	} {
+		t.Run(fmt.Sprintf("%v-%v/%d", tt.min, tt.max, tt.n), func(t *testing.T) {
+			is := is.New(t)
+			is.Equal(tt.expected, niceTicks(tt.min, tt.max, tt.n))
+		})
+	}
+}
+
+func TestNiceCeiling(t *testing.T) {
+	for _, tt := range []struct {
+		max           float64
+		ceiling, step float64
+	}{
+		{3, 3, 1},
+		{47, 50, 10},
+		{1873, 2000, 500},
+		{4873, 5000, 1000},
+		{120345, 150000, 50000},
+		{0, 1, 0.2},
+	} {
+		t.Run(fmt.Sprint(tt.max), func(t *testing.T) {
+			is := is.New(t)
+			ceiling, step := niceCeiling(tt.max, defaultYTicks)
+			is.Equal(tt.ceiling, ceiling)
+			is.Equal(tt.step, step)
+			ticks := niceTicks(0, tt.max, defaultYTicks)
+			is.Equal(ceiling, ticks[len(ticks)-1]) // should be the top tick
+		})
+	}
+}
+
+func TestParseTicks(t *testing.T) {
+	for value, expected := range map[string]int{
+		"":    0,
+		"abc": 0,
+		"0":   0,
+		"1":   minTicks,
+		"8":   8,
+		"100": maxTicks,
+	} {
+		t.Run(value, func(t *testing.T) {
+			is := is.New(t)
+			is.Equal(expected, parseTicks(value))
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package controller
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/caarlos0/httperr"
+	"github.com/caarlos0/starcharts/internal/github"
+)
+
+// Point is the cumulative star count of a repository at a given time.
+type Point struct {
+	Date  time.Time `json:"date"`
+	Stars int       `json:"stars"`
+}
+
+// GetRepoJSON returns the star timeline of the given repository as JSON.
+func GetRepoJSON(gh *github.GitHub) http.Handler {
+	return repoTimeline(gh, "application/json", func(w http.ResponseWriter, points []Point) error {
+		return json.NewEncoder(w).Encode(points)
+	})
+}
+
+// GetRepoCSV returns the star timeline of the given repository as CSV.
+func GetRepoCSV(gh *github.GitHub) http.Handler {
+	return repoTimeline(gh, "text/csv;charset=utf-8", func(w http.ResponseWriter, points []Point) error {
+		cw := csv.NewWriter(w)
+		if err := cw.Write([]string{"date", "stars"}); err != nil {
+			return err
+		}
+		for _, p := range points {
+			if err := cw.Write([]string{
+				p.Date.Format(time.RFC3339),
+				strconv.Itoa(p.Stars),
+			}); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	})
+}
+
+func repoTimeline(
+	gh *github.GitHub,
+	contentType string,
+	write func(w http.ResponseWriter, points []Point) error,
+) http.Handler {
+	return httperr.NewF(func(w http.ResponseWriter, r *http.Request) error {
+		name, err := repoName(r)
+		if err != nil {
+			return err
+		}
+		log := log.WithField("repo", name)
+		defer log.Trace("collect_stars").Stop(nil)
+		repo, err := gh.RepoDetails(r.Context(), name)
+		if err != nil {
+			return fetchErr(w, err, http.StatusBadRequest)
+		}
+		if r.Method == http.MethodHead {
+			// headers only, no need to fetch the stars.
+			w.Header().Add("content-type", contentType)
+			w.Header().Add("cache-control", "public, max-age=86400")
+			varyEncoding(w)
+			return nil
+		}
+
+		stargazers, baseline, err := stars(r, gh, repo)
+		if err != nil {
+			log.WithError(err).Error("failed to get stars")
+			setRetryAfter(w, err)
+			return httperr.Wrap(explainErr(err), errStatus(err, http.StatusInternalServerError))
+		}
+
+		points := timelinePoints(stargazers, baseline)
+		if per := parsePer(r.URL.Query().Get("per")); per > 0 {
+			period := pointsPeriod(per, points)
+			points = bucketPoints(points, period)
+			w.Header().Set("x-timeline-per", periodName(period))
+		}
+
+		w.Header().Add("content-type", contentType)
+		w.Header().Add("cache-control", "public, max-age=86400")
+		cw := compress(w, r)
+		defer cw.Close()
+		return write(cw, points)
+	})
+}
+
+// timelinePoints returns the cumulative star count at each star, starting
+// at baseline.
+func timelinePoints(stargazers []github.Stargazer, baseline int) []Point {
+	points := make([]Point, 0, len(stargazers))
+	for i, star := range stargazers {
+		points = append(points, Point{
+			Date:  star.StarredAt,
+			Stars: baseline + i + 1,
+		})
+	}
+	return points
+}
@@ -0,0 +1,159 @@
+package controller
+
+import (
+	"time"
+
+	chart "github.com/wcharczuk/go-chart"
+	"github.com/wcharczuk/go-chart/util"
+)
+
+// tickLabelCharWidth and tickLabelPadding estimate how wide x axis labels
+// are, in pixels, to pick tick intervals whose labels don't overlap.
+// tickPlotMargin is the part of the chart width taken by the y axis and
+// paddings.
+const (
+	tickLabelCharWidth = 8
+	tickLabelPadding   = 16
+	tickPlotMargin     = 100
+)
+
+// tickInterval is a calendar interval between x axis ticks.
+type tickInterval struct {
+	name string
+	// approx is about how long the interval is, to pick one fit for a span.
+	approx time.Duration
+	// layout formats the labels of the ticks.
+	layout string
+	// first returns the first tick at or before t.
+	first func(t time.Time) time.Time
+	// next returns the tick after t.
+	next func(t time.Time) time.Time
+}
+
+func dayTicks(name string, n int) tickInterval {
+	return tickInterval{
+		name:   name,
+		approx: time.Duration(n) * 24 * time.Hour,
+		layout: "Jan 2",
+		first: func(t time.Time) time.Time {
+			return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		},
+		next: func(t time.Time) time.Time {
+			return t.AddDate(0, 0, n)
+		},
+	}
+}
+
+func weekTicks(name string, n int) tickInterval {
+	interval := dayTicks(name, 7*n)
+	day := interval.first
+	interval.first = func(t time.Time) time.Time {
+		// weeks start on mondays.
+		t = day(t)
+		return t.AddDate(0, 0, -(int(t.Weekday())+6)%7)
+	}
+	return interval
+}
+
+func monthTicks(name string, n int) tickInterval {
+	return tickInterval{
+		name: name,
+		// the shortest month, so labels don't overlap in february.
+		approx: time.Duration(n) * 28 * 24 * time.Hour,
+		layout: "Jan 2006",
+		first: func(t time.Time) time.Time {
+			month := (int(t.Month())-1)/n*n + 1
+			return time.Date(t.Year(), time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+		},
+		next: func(t time.Time) time.Time {
+			return t.AddDate(0, n, 0)
+		},
+	}
+}
+
+func yearTicks(name string, n int) tickInterval {
+	return tickInterval{
+		name:   name,
+		approx: time.Duration(n) * 365 * 24 * time.Hour,
+		layout: "2006",
+		first: func(t time.Time) time.Time {
+			return time.Date(t.Year()/n*n, time.January, 1, 0, 0, 0, 0, time.UTC)
+		},
+		next: func(t time.Time) time.Time {
+			return t.AddDate(n, 0, 0)
+		},
+	}
+}
+
+// tickIntervals are the calendar intervals x axis ticks can be apart,
+// shortest first.
+// nolint: gochecknoglobals
+var tickIntervals = []tickInterval{
+	dayTicks("day", 1),
+	dayTicks("2 days", 2),
+	weekTicks("week", 1),
+	weekTicks("2 weeks", 2),
+	monthTicks("month", 1),
+	monthTicks("2 months", 2),
+	monthTicks("quarter", 3),
+	monthTicks("half year", 6),
+	yearTicks("year", 1),
+	yearTicks("2 years", 2),
+	yearTicks("5 years", 5),
+	yearTicks("10 years", 10),
+}
+
+// pickTickInterval picks the shortest calendar interval whose labels fit
+// side by side in the given width for the span [min, max], or the longest
+// one if none do.
+func pickTickInterval(min, max time.Time, width int) tickInterval {
+	span := max.Sub(min)
+	for _, interval := range tickIntervals {
+		label := len(min.Format(interval.layout))*tickLabelCharWidth + tickLabelPadding
+		ticks := int(span/interval.approx) + 1
+		if ticks*label <= width {
+			return interval
+		}
+	}
+	return tickIntervals[len(tickIntervals)-1]
+}
+
+// calendarTicks returns the ticks of the given interval within [min, max].
+func calendarTicks(min, max time.Time, interval tickInterval, layout string) []chart.Tick {
+	if layout == "" {
+		layout = interval.layout
+	}
+	var ticks []chart.Tick
+	for t := interval.first(min); !t.After(max); t = interval.next(t) {
+		if t.Before(min) {
+			continue
+		}
+		ticks = append(ticks, chart.Tick{
+			Value: util.Time.ToFloat64(t),
+			Label: t.Format(layout),
+		})
+	}
+	return ticks
+}
+
+// applyCalendarTicks sets x axis ticks on calendar boundaries, e.g. the first
+// of each month, picking the interval so the labels of the given chart width
+// don't overlap.
+// The label format follows the interval, unless layout is set.
+func applyCalendarTicks(graph *chart.Chart, layout string, width int) {
+	lo, hi, ok := xBounds(graph)
+	if !ok || hi <= lo {
+		return
+	}
+	min, max := util.Time.FromFloat64(lo).UTC(), util.Time.FromFloat64(hi).UTC()
+	ticks := calendarTicks(min, max, pickTickInterval(min, max, width-tickPlotMargin), layout)
+	// go-chart ranges the axis by its ticks, so the ends get unlabeled ones
+	// to keep the whole span.
+	if len(ticks) == 0 || ticks[0].Value > lo {
+		ticks = append([]chart.Tick{{Value: lo}}, ticks...)
+	}
+	if ticks[len(ticks)-1].Value < hi {
+		ticks = append(ticks, chart.Tick{Value: hi})
+	}
+	graph.XAxis.Ticks = ticks
+}
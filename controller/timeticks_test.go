@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	chart "github.com/wcharczuk/go-chart"
+	"github.com/wcharczuk/go-chart/util"
+)
+
+func TestPickTickInterval(t *testing.T) {
+	start := time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC)
+	for name, tt := range map[string]struct {
+		span     time.Duration
+		width    int
+		expected string
+	}{
+		"few days":        {5 * 24 * time.Hour, 1024, "day"},
+		"two weeks":       {14 * 24 * time.Hour, 1024, "day"},
+		"two weeks small": {14 * 24 * time.Hour, 300, "week"},
+		"two months":      {60 * 24 * time.Hour, 1024, "week"},
+		"half year":       {182 * 24 * time.Hour, 1024, "2 weeks"},
+		"two years":       {2 * 365 * 24 * time.Hour, 1024, "quarter"},
+		"two years small": {2 * 365 * 24 * time.Hour, 400, "half year"},
+		"five years":      {5 * 365 * 24 * time.Hour, 1024, "half year"},
+		"decade":          {10 * 365 * 24 * time.Hour, 1024, "year"},
+		"decade small":    {10 * 365 * 24 * time.Hour, 300, "2 years"},
+		"century tiny":    {100 * 365 * 24 * time.Hour, 50, "10 years"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			is := is.New(t)
+			interval := pickTickInterval(start, start.Add(tt.span), tt.width)
+			is.Equal(tt.expected, interval.name)
+
+			// labels, estimated as in pickTickInterval, must not overlap.
+			ticks := calendarTicks(start, start.Add(tt.span), interval, "")
+			scale := float64(tt.width) / float64(tt.span)
+			for i := 1; i < len(ticks); i++ {
+				gap := (ticks[i].Value - ticks[i-1].Value) * scale
+				if tt.width >= 300 {
+					is.True(gap >= float64(len(ticks[i-1].Label)*tickLabelCharWidth)) // labels should not collide
+				}
+			}
+		})
+	}
+}
+
+func TestCalendarTicks(t *testing.T) {
+	is := is.New(t)
+	min := time.Date(2021, 11, 17, 12, 0, 0, 0, time.UTC)
+	max := time.Date(2022, 8, 3, 0, 0, 0, 0, time.UTC)
+
+	var labels []string
+	for _, tick := range calendarTicks(min, max, monthTicks("quarter", 3), "") {
+		labels = append(labels, tick.Label)
+	}
+	is.Equal([]string{"Jan 2022", "Apr 2022", "Jul 2022"}, labels) // should start on quarters
+
+	var weeks []time.Weekday
+	for _, tick := range calendarTicks(min, max, weekTicks("week", 1), "") {
+		weeks = append(weeks, util.Time.FromFloat64(tick.Value).UTC().Weekday())
+	}
+	for _, day := range weeks {
+		is.Equal(time.Monday, day) // should be on mondays
+	}
+}
+
+func TestApplyCalendarTicks(t *testing.T) {
+	is := is.New(t)
+	min := time.Date(2021, 11, 17, 12, 0, 0, 0, time.UTC)
+	max := time.Date(2022, 8, 3, 0, 0, 0, 0, time.UTC)
+	graph := chart.Chart{Series: []chart.Series{chart.TimeSeries{
+		XValues: []time.Time{min, max},
+		YValues: []float64{1, 2},
+	}}}
+	applyCalendarTicks(&graph, "", 1024)
+	ticks := graph.XAxis.Ticks
+	is.Equal(util.Time.ToFloat64(min), ticks[0].Value)            // should keep the start of the data
+	is.Equal(util.Time.ToFloat64(max), ticks[len(ticks)-1].Value) // should keep the end of the data
+	is.Equal("", ticks[0].Label)                                  // should not label the ends
+	is.Equal("Dec 2021", ticks[1].Label)
+}
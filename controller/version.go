@@ -0,0 +1,29 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+
+	"github.com/caarlos0/httperr"
+)
+
+// BuildInfo describes the running build, as injected by the build ldflags.
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	Date      string `json:"date"`
+	BuiltBy   string `json:"built_by"`
+	GoVersion string `json:"go_version"`
+}
+
+// GetVersion returns the given build info as JSON, along with the go runtime
+// version.
+func GetVersion(info BuildInfo) http.Handler {
+	info.GoVersion = runtime.Version()
+	return httperr.NewF(func(w http.ResponseWriter, r *http.Request) error {
+		w.Header().Add("content-type", "application/json")
+		w.Header().Add("cache-control", "no-cache")
+		return json.NewEncoder(w).Encode(info)
+	})
+}
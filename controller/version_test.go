@@ -0,0 +1,34 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestGetVersion(t *testing.T) {
+	is := is.New(t)
+	w := httptest.NewRecorder()
+	GetVersion(BuildInfo{
+		Version: "v1.2.3",
+		Commit:  "abc123",
+		Date:    "2022-03-04T05:06:07Z",
+		BuiltBy: "goreleaser",
+	}).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/version", nil))
+	is.Equal(http.StatusOK, w.Code)
+	is.Equal("application/json", w.Header().Get("content-type"))
+
+	var info BuildInfo
+	is.NoErr(json.NewDecoder(w.Body).Decode(&info))
+	is.Equal(BuildInfo{
+		Version:   "v1.2.3",
+		Commit:    "abc123",
+		Date:      "2022-03-04T05:06:07Z",
+		BuiltBy:   "goreleaser",
+		GoVersion: runtime.Version(),
+	}, info)
+}
@@ -0,0 +1,165 @@
+package controller
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/caarlos0/httperr"
+	chart "github.com/wcharczuk/go-chart"
+	"github.com/wcharczuk/go-chart/util"
+)
+
+// axisRange parses the axis_min and axis_max query parameters, e.g.
+// ?axis_min=2023-01-01&axis_max=2023-04-01, the dates the x axis is forced to
+// start and end at. Either can be left out to keep the data extent on that
+// side.
+func axisRange(r *http.Request) (min, max time.Time, err error) {
+	parse := func(key string) (time.Time, error) {
+		value := r.URL.Query().Get(key)
+		if value == "" {
+			return time.Time{}, nil
+		}
+		t, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			return time.Time{}, httperr.Errorf(http.StatusBadRequest, "invalid %s, expected YYYY-MM-DD: %q", key, value)
+		}
+		return t, nil
+	}
+	if min, err = parse("axis_min"); err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if max, err = parse("axis_max"); err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if !min.IsZero() && !max.IsZero() && !min.Before(max) {
+		return time.Time{}, time.Time{}, httperr.Errorf(http.StatusBadRequest, "axis_min must be before axis_max")
+	}
+	return min, max, nil
+}
+
+// clampXAxis forces the x axis of the graph to span [min, max], leaving empty
+// space where there is no data, and clipping the series outside of it.
+// A zero min or max keeps the data extent on that side.
+func clampXAxis(graph *chart.Chart, min, max time.Time) {
+	if min.IsZero() && max.IsZero() {
+		return
+	}
+	lo, hi, _, _, ok := seriesBounds(graph.Series)
+	if !ok {
+		return
+	}
+	if !min.IsZero() {
+		lo = util.Time.ToFloat64(min)
+	}
+	if !max.IsZero() {
+		hi = util.Time.ToFloat64(max)
+	}
+	if hi <= lo {
+		// the data is all on the other side of the only bound given.
+		return
+	}
+
+	var series []chart.Series
+	for _, s := range graph.Series {
+		if s, ok := clipSeries(s, lo, hi); ok {
+			series = append(series, s)
+		}
+	}
+	if _, _, _, _, ok := seriesBounds(series); !ok {
+		// nothing left to chart.
+		return
+	}
+	graph.Series = series
+	graph.XAxis.Range = &chart.ContinuousRange{Min: lo, Max: hi}
+}
+
+// clipSeries removes the values of the given series outside [lo, hi], telling
+// whether anything is left of it.
+//
+// Time series are step lines, so they keep their value at the bounds they
+// cross.
+func clipSeries(s chart.Series, lo, hi float64) (chart.Series, bool) {
+	switch s := s.(type) {
+	case chart.TimeSeries:
+		clipped := s
+		clipped.XValues, clipped.YValues = nil, nil
+		add := func(x time.Time, y float64) {
+			clipped.XValues = append(clipped.XValues, x)
+			clipped.YValues = append(clipped.YValues, y)
+		}
+		var before *float64
+		for i, t := range s.XValues {
+			x, y := util.Time.ToFloat64(t), s.YValues[i]
+			if x < lo {
+				before = &y
+				continue
+			}
+			if before != nil {
+				add(util.Time.FromFloat64(lo), *before)
+				before = nil
+			}
+			if x > hi {
+				if len(clipped.YValues) > 0 {
+					add(util.Time.FromFloat64(hi), clipped.YValues[len(clipped.YValues)-1])
+				}
+				break
+			}
+			add(t, y)
+		}
+		if before != nil {
+			// every value is before the range.
+			add(util.Time.FromFloat64(lo), *before)
+			add(util.Time.FromFloat64(hi), *before)
+		}
+		return clipped, len(clipped.XValues) > 0
+	case chart.AnnotationSeries:
+		clipped := s
+		clipped.Annotations = nil
+		for _, a := range s.Annotations {
+			if a.XValue >= lo && a.XValue <= hi {
+				clipped.Annotations = append(clipped.Annotations, a)
+			}
+		}
+		return clipped, len(clipped.Annotations) > 0
+	case forecastBand:
+		clipped := forecastBand{color: s.color}
+		for i, x := range s.x {
+			if x >= lo && x <= hi {
+				clipped.x = append(clipped.x, x)
+				clipped.lower = append(clipped.lower, s.lower[i])
+				clipped.upper = append(clipped.upper, s.upper[i])
+			}
+		}
+		return clipped, len(clipped.x) > 0
+	default:
+		return s, true
+	}
+}
+
+// clipPoints returns the given sorted points within [min, max], along with the
+// star count before the first of them. A zero min or max keeps all the points
+// on that side.
+func clipPoints(points []Point, baseline int, min, max time.Time) ([]Point, int) {
+	var clipped []Point
+	for _, p := range points {
+		if !min.IsZero() && p.Date.Before(min) {
+			baseline = p.Stars
+			continue
+		}
+		if !max.IsZero() && p.Date.After(max) {
+			break
+		}
+		clipped = append(clipped, p)
+	}
+	return clipped, baseline
+}
+
+// xBounds returns the extent of the x axis: its range if it is forced, or the
+// data extent otherwise.
+func xBounds(graph *chart.Chart) (min, max float64, ok bool) {
+	if r, isContinuous := graph.XAxis.Range.(*chart.ContinuousRange); isContinuous && !r.IsZero() {
+		return r.Min, r.Max, true
+	}
+	min, max, _, _, ok = seriesBounds(graph.Series)
+	return min, max, ok
+}
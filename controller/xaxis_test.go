@@ -0,0 +1,112 @@
+package controller
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/caarlos0/starcharts/internal/github"
+	"github.com/matryer/is"
+	chart "github.com/wcharczuk/go-chart"
+	"github.com/wcharczuk/go-chart/util"
+)
+
+func TestAxisRange(t *testing.T) {
+	for query, ok := range map[string]bool{
+		"":                    true,
+		"axis_min=2023-01-01": true,
+		"axis_max=2023-01-01": true,
+		"axis_min=2023-01-01&axis_max=2023-04-01": true,
+		"axis_min=2023-04-01&axis_max=2023-01-01": false,
+		"axis_min=2023-01-01&axis_max=2023-01-01": false,
+		"axis_min=yesterday":                      false,
+		"axis_min=2023-01-01&axis_max=2023-13-01": false,
+	} {
+		t.Run(query, func(t *testing.T) {
+			is := is.New(t)
+			_, _, err := axisRange(httptest.NewRequest(http.MethodGet, "/a/b.svg?"+query, nil))
+			is.Equal(ok, err == nil)
+		})
+	}
+}
+
+func TestClampXAxis(t *testing.T) {
+	day := func(d int) time.Time {
+		return time.Date(2023, 1, d, 0, 0, 0, 0, time.UTC)
+	}
+	graph := func() chart.Chart {
+		return chart.Chart{Series: []chart.Series{chart.TimeSeries{
+			XValues: []time.Time{day(5), day(10), day(15)},
+			YValues: []float64{1, 2, 3},
+		}}}
+	}
+
+	t.Run("wider than the data", func(t *testing.T) {
+		is := is.New(t)
+		g := graph()
+		clampXAxis(&g, day(1), day(30))
+		is.Equal(&chart.ContinuousRange{Min: util.Time.ToFloat64(day(1)), Max: util.Time.ToFloat64(day(30))}, g.XAxis.Range)
+		is.Equal(3, g.Series[0].(chart.TimeSeries).Len()) // should keep every value
+	})
+
+	t.Run("narrower than the data", func(t *testing.T) {
+		is := is.New(t)
+		g := graph()
+		clampXAxis(&g, day(7), day(12))
+		series := g.Series[0].(chart.TimeSeries)
+		is.Equal([]float64{1, 2, 2}, series.YValues) // should carry the values to the bounds
+		is.True(series.XValues[0].Equal(day(7)))
+		is.True(series.XValues[2].Equal(day(12)))
+	})
+
+	t.Run("only min", func(t *testing.T) {
+		is := is.New(t)
+		g := graph()
+		clampXAxis(&g, day(1), time.Time{})
+		is.Equal(util.Time.ToFloat64(day(15)), g.XAxis.Range.GetMax()) // should keep the data extent
+	})
+
+	t.Run("no data in range", func(t *testing.T) {
+		is := is.New(t)
+		g := graph()
+		clampXAxis(&g, day(20), day(30))
+		is.Equal([]float64{3, 3}, g.Series[0].(chart.TimeSeries).YValues) // should draw the last value
+	})
+
+	t.Run("unset", func(t *testing.T) {
+		is := is.New(t)
+		g := graph()
+		clampXAxis(&g, time.Time{}, time.Time{})
+		is.Equal(nil, g.XAxis.Range)
+	})
+}
+
+func TestClipPoints(t *testing.T) {
+	is := is.New(t)
+	day := func(d int) time.Time {
+		return time.Date(2023, 1, d, 0, 0, 0, 0, time.UTC)
+	}
+	points := []Point{{day(1), 11}, {day(2), 12}, {day(3), 13}, {day(4), 14}}
+	clipped, baseline := clipPoints(points, 10, day(2), day(3))
+	is.Equal([]Point{{day(2), 12}, {day(3), 13}}, clipped)
+	is.Equal(11, baseline) // should start from the stars before the range
+}
+
+func TestWriteChart_AxisRange(t *testing.T) {
+	is := is.New(t)
+	stargazers := []github.Stargazer{
+		{StarredAt: time.Date(2022, 12, 1, 0, 0, 0, 0, time.UTC)},
+		{StarredAt: time.Date(2023, 1, 10, 0, 0, 0, 0, time.UTC)},
+		{StarredAt: time.Date(2023, 1, 20, 0, 0, 0, 0, time.UTC)},
+	}
+	var buf bytes.Buffer
+	is.NoErr(WriteChart(&buf, stargazers, ChartOptions{
+		AxisMin:   time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+		AxisMax:   time.Date(2023, 4, 1, 0, 0, 0, 0, time.UTC),
+		XTicks:    4,
+		DailyBars: true,
+	}))
+	is.True(bytes.Contains(buf.Bytes(), []byte(">Apr 2023<"))) // should have an axis label at the forced end
+}
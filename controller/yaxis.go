@@ -0,0 +1,129 @@
+package controller
+
+import (
+	"math"
+	"strconv"
+
+	chart "github.com/wcharczuk/go-chart"
+)
+
+// yAxisLeft tells whether the yaxis query parameter asks for the y axis on
+// the left side of the plot. It is on the right side by default.
+func yAxisLeft(value string) bool {
+	return value == "left"
+}
+
+// moveYAxisLeft draws the y axis on the left side of the plot.
+//
+// go-chart always draws the primary y axis on the right side, so the axis is
+// drawn as the secondary one instead, while the series stay on the hidden
+// primary axis. Both axes get their range from the same ticks, so the labels
+// still match the series.
+func moveYAxisLeft(graph *chart.Chart) {
+	if !graph.YAxis.Style.Show {
+		return
+	}
+	graph.YAxisSecondary = graph.YAxis
+	graph.YAxis.Style.Show = false
+	graph.YAxis.NameStyle.Show = false
+}
+
+// yBasePadding is the part of the visible star count span left below the
+// lowest value when the y axis base is auto.
+const yBasePadding = 0.1
+
+// parseYBase parses the ybase query parameter: auto starts the y axis a bit
+// below the lowest visible star count, zero at zero, and a star count at
+// that count. ok is false if it isn't set or isn't valid.
+func parseYBase(value string) (base *int, auto, ok bool) {
+	switch value {
+	case "auto":
+		return nil, true, true
+	case "zero":
+		value = "0"
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return nil, false, false
+	}
+	return &n, false, true
+}
+
+// applyYBase sets about yticks round y axis ticks starting at the given base,
+// or a bit below the lowest value if auto is set, so the grid lines follow
+// it. Values below an explicit base are cut at it.
+// A nil base without auto keeps the ticks of applyTicks.
+func applyYBase(graph *chart.Chart, base *int, auto bool, yticks int) {
+	if base == nil && !auto {
+		return
+	}
+	_, _, minY, maxY, ok := seriesBounds(graph.Series)
+	if !ok {
+		return
+	}
+	if yticks == 0 {
+		yticks = defaultYTicks
+	}
+
+	var values []float64
+	if auto {
+		lo := minY - (maxY-minY)*yBasePadding
+		if minY >= 0 && lo < 0 {
+			lo = 0
+		}
+		values = niceTicks(lo, maxY, yticks)
+	} else {
+		lo := float64(*base)
+		cutSeriesBelow(graph, lo)
+		nice := niceTicks(lo, math.Max(maxY, lo+1), yticks)
+		// the axis starts right at the base, dropping the round ticks too
+		// close to it for their labels not to overlap.
+		values = []float64{lo}
+		step := nice[1] - nice[0]
+		for _, value := range nice {
+			if value > lo+step/2 {
+				values = append(values, value)
+			}
+		}
+	}
+
+	graph.YAxis.Ticks = nil
+	for _, value := range values {
+		graph.YAxis.Ticks = append(graph.YAxis.Ticks, chart.Tick{
+			Value: value,
+			Label: IntValueFormatter(value),
+		})
+	}
+}
+
+// cutSeriesBelow raises the values of the graph series below min to it, so
+// they are drawn along the bottom of the plot rather than outside of it, and
+// drops the annotations below it.
+func cutSeriesBelow(graph *chart.Chart, min float64) {
+	cut := func(values []float64) []float64 {
+		result := make([]float64, len(values))
+		for i, value := range values {
+			result[i] = math.Max(value, min)
+		}
+		return result
+	}
+	for i, s := range graph.Series {
+		switch s := s.(type) {
+		case chart.TimeSeries:
+			s.YValues = cut(s.YValues)
+			graph.Series[i] = s
+		case forecastBand:
+			s.lower, s.upper = cut(s.lower), cut(s.upper)
+			graph.Series[i] = s
+		case chart.AnnotationSeries:
+			var annotations []chart.Value2
+			for _, a := range s.Annotations {
+				if a.YValue >= min {
+					annotations = append(annotations, a)
+				}
+			}
+			s.Annotations = annotations
+			graph.Series[i] = s
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package controller
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/caarlos0/starcharts/internal/github"
+	"github.com/matryer/is"
+	chart "github.com/wcharczuk/go-chart"
+)
+
+func TestParseYBase(t *testing.T) {
+	for value, expected := range map[string]struct {
+		base     int
+		auto, ok bool
+	}{
+		"":      {},
+		"nope":  {},
+		"-1":    {},
+		"auto":  {auto: true, ok: true},
+		"zero":  {base: 0, ok: true},
+		"0":     {base: 0, ok: true},
+		"40000": {base: 40000, ok: true},
+	} {
+		t.Run(value, func(t *testing.T) {
+			is := is.New(t)
+			base, auto, ok := parseYBase(value)
+			is.Equal(expected.ok, ok)
+			is.Equal(expected.auto, auto)
+			is.Equal(expected.ok && !expected.auto, base != nil) // should only have a base if explicit
+			if base != nil {
+				is.Equal(expected.base, *base)
+			}
+		})
+	}
+}
+
+func TestApplyYBase(t *testing.T) {
+	graph := func() chart.Chart {
+		return chart.Chart{Series: []chart.Series{chart.TimeSeries{
+			XValues: []time.Time{time.Unix(0, 0), time.Unix(100, 0), time.Unix(200, 0)},
+			YValues: []float64{40210, 41500, 43980},
+		}}}
+	}
+	ticks := func(g chart.Chart) []float64 {
+		var values []float64
+		for _, tick := range g.YAxis.Ticks {
+			values = append(values, tick.Value)
+		}
+		return values
+	}
+	base := func(n int) *int {
+		return &n
+	}
+
+	t.Run("unset", func(t *testing.T) {
+		is := is.New(t)
+		g := graph()
+		applyTicks(&g, 0, 0)
+		expected := ticks(g)
+		applyYBase(&g, nil, false, 0)
+		is.Equal(expected, ticks(g)) // should keep the ticks
+	})
+
+	t.Run("auto", func(t *testing.T) {
+		is := is.New(t)
+		g := graph()
+		applyYBase(&g, nil, true, 0)
+		is.Equal([]float64{39000, 40000, 41000, 42000, 43000, 44000}, ticks(g))
+	})
+
+	t.Run("zero", func(t *testing.T) {
+		is := is.New(t)
+		g := graph()
+		applyYBase(&g, base(0), false, 0)
+		is.Equal([]float64{0, 10000, 20000, 30000, 40000, 50000}, ticks(g))
+	})
+
+	t.Run("explicit", func(t *testing.T) {
+		is := is.New(t)
+		g := graph()
+		applyYBase(&g, base(41000), false, 0)
+		is.Equal([]float64{41000, 42000, 43000, 44000}, ticks(g))
+		is.Equal([]float64{41000, 41500, 43980}, g.Series[0].(chart.TimeSeries).YValues) // should cut the values below the base
+		is.Equal("41000", g.YAxis.Ticks[0].Label)
+	})
+
+	t.Run("explicit between round ticks", func(t *testing.T) {
+		is := is.New(t)
+		g := graph()
+		applyYBase(&g, base(40900), false, 0)
+		is.Equal([]float64{40900, 42000, 43000, 44000}, ticks(g)) // should drop the tick too close to the base
+	})
+}
+
+func TestWriteChartYBase(t *testing.T) {
+	stargazers := []github.Stargazer{
+		{StarredAt: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{StarredAt: time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{StarredAt: time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	render := func(t *testing.T, opts ChartOptions) string {
+		t.Helper()
+		var b bytes.Buffer
+		if err := WriteChart(&b, stargazers, opts); err != nil {
+			t.Fatal(err)
+		}
+		return b.String()
+	}
+
+	t.Run("auto", func(t *testing.T) {
+		is := is.New(t)
+		svg := render(t, ChartOptions{Baseline: 40000, YBaseAuto: true})
+		is.True(strings.Contains(svg, ">40000</text>"))
+		is.True(!strings.Contains(svg, ">0</text>")) // should not start at zero
+	})
+
+	t.Run("zero", func(t *testing.T) {
+		is := is.New(t)
+		svg := render(t, ChartOptions{Baseline: 40000, YBase: new(int)})
+		is.True(strings.Contains(svg, ">0</text>"))
+		is.True(strings.Contains(svg, ">40000</text>"))
+	})
+}
@@ -0,0 +1,244 @@
+package cache
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apex/log"
+	msgpack "gopkg.in/vmihailenco/msgpack.v2"
+)
+
+// BlobConfig configures a S3-compatible blob store.
+type BlobConfig struct {
+	Endpoint  string
+	Bucket    string
+	Region    string
+	AccessKey string
+	SecretKey string
+	Prefix    string
+}
+
+// Blob is a cache backed by a S3-compatible blob store.
+//
+// It is meant for large entries that rarely change: requests are slower than
+// redis, but storage is a lot cheaper.
+type Blob struct {
+	config BlobConfig
+	client *http.Client
+	now    func() time.Time
+}
+
+// NewBlob creates a new blob store cache.
+func NewBlob(config BlobConfig) *Blob {
+	return &Blob{
+		config: config,
+		client: &http.Client{Timeout: 10 * time.Second},
+		now:    time.Now,
+	}
+}
+
+// expiresHeader holds when an entry expires, as blob stores don't expire
+// objects on their own: expired ones are deleted when read instead.
+const expiresHeader = "X-Amz-Meta-Expires"
+
+// Get from cache by key.
+func (b *Blob) Get(key string, result interface{}) error {
+	resp, err := b.do(http.MethodGet, key, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("blob store get %s failed with status %d", key, resp.StatusCode)
+	}
+	if expires := resp.Header.Get(expiresHeader); expires != "" {
+		unix, err := strconv.ParseInt(expires, 10, 64)
+		if err == nil && b.now().Unix() > unix {
+			if err := b.Delete(key); err != nil {
+				log.WithError(err).WithField("key", key).Warn("failed to delete expired blob")
+			}
+			return ErrNotFound
+		}
+	}
+
+	bts, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if err := msgpack.Unmarshal(bts, result); err != nil {
+		return err
+	}
+	cacheGets.Inc()
+	return nil
+}
+
+// Put on cache.
+func (b *Blob) Put(key string, obj interface{}) error {
+	return b.PutWithTTL(key, obj, 0)
+}
+
+// PutWithTTL puts on cache, expiring the key after the given ttl.
+// A zero ttl means DefaultTTL, and a negative one never expires.
+func (b *Blob) PutWithTTL(key string, obj interface{}, ttl time.Duration) error {
+	bts, err := msgpack.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	return b.putBytes(key, bts, ttl)
+}
+
+func (b *Blob) putBytes(key string, bts []byte, ttl time.Duration) error {
+	headers := map[string]string{}
+	if ttl == 0 {
+		ttl = DefaultTTL
+	}
+	if ttl > 0 {
+		headers[expiresHeader] = strconv.FormatInt(b.now().Add(ttl).Unix(), 10)
+	}
+	resp, err := b.do(http.MethodPut, key, bts, headers)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("blob store put %s failed with status %d", key, resp.StatusCode)
+	}
+	cachePuts.Inc()
+	return nil
+}
+
+// Delete from cache.
+func (b *Blob) Delete(key string) error {
+	resp, err := b.do(http.MethodDelete, key, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("blob store delete %s failed with status %d", key, resp.StatusCode)
+	}
+	cacheDeletes.Inc()
+	return nil
+}
+
+// Close connections.
+func (b *Blob) Close() error {
+	b.client.CloseIdleConnections()
+	return nil
+}
+
+func (b *Blob) do(method, key string, body []byte, headers map[string]string) (*http.Response, error) {
+	u, err := url.Parse(strings.TrimSuffix(b.config.Endpoint, "/"))
+	if err != nil {
+		return nil, err
+	}
+	u.Path = "/" + b.config.Bucket + "/" + b.config.Prefix + key
+	u.RawPath = uriEncode(u.Path)
+
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	b.sign(req, body, b.now().UTC())
+	return b.client.Do(req)
+}
+
+// sign signs the request with AWS signature version 4.
+//
+// See https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-header-based-auth.html
+func (b *Blob) sign(req *http.Request, body []byte, now time.Time) {
+	payloadHash := sha256Hex(body)
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := []string{"host"}
+	values := map[string]string{"host": req.URL.Host}
+	for k := range req.Header {
+		name := strings.ToLower(k)
+		if strings.HasPrefix(name, "x-amz-") {
+			headers = append(headers, name)
+			values[name] = strings.TrimSpace(req.Header.Get(k))
+		}
+	}
+	sort.Strings(headers)
+	var canonicalHeaders strings.Builder
+	for _, name := range headers {
+		canonicalHeaders.WriteString(name + ":" + values[name] + "\n")
+	}
+	signedHeaders := strings.Join(headers, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		uriEncode(req.URL.Path),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + b.config.Region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+b.config.SecretKey), date)
+	key = hmacSHA256(key, b.config.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		b.config.AccessKey, scope, signedHeaders, signature,
+	))
+}
+
+// uriEncode encodes each segment of the given path the way SigV4 expects,
+// percent-encoding everything but unreserved characters, e.g. the @ of page
+// keys.
+func uriEncode(path string) string {
+	var sb strings.Builder
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if c == '/' || c == '-' || c == '_' || c == '.' || c == '~' ||
+			'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' {
+			sb.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&sb, "%%%02X", c)
+	}
+	return sb.String()
+}
+
+func sha256Hex(bts []byte) string {
+	sum := sha256.Sum256(bts)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
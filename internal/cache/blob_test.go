@@ -0,0 +1,157 @@
+package cache
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+// fakeBlobStore is a minimal in-memory S3-compatible server.
+func fakeBlobStore(t *testing.T) (*httptest.Server, map[string][]byte) {
+	t.Helper()
+	var lock sync.Mutex
+	objects := map[string][]byte{}
+	expires := map[string]string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=access/") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if strings.Contains(r.RequestURI, "@") {
+			// signed paths are percent-encoded, the sent ones must be too.
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		switch r.Method {
+		case http.MethodPut:
+			bts, _ := io.ReadAll(r.Body)
+			objects[r.URL.Path] = bts
+			expires[r.URL.Path] = r.Header.Get(expiresHeader)
+		case http.MethodGet:
+			bts, ok := objects[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			if exp := expires[r.URL.Path]; exp != "" {
+				w.Header().Set(expiresHeader, exp)
+			}
+			_, _ = w.Write(bts)
+		case http.MethodDelete:
+			delete(objects, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, objects
+}
+
+func newTestBlob(t *testing.T) (*Blob, map[string][]byte) {
+	t.Helper()
+	srv, objects := fakeBlobStore(t)
+	return NewBlob(BlobConfig{
+		Endpoint:  srv.URL,
+		Bucket:    "bucket",
+		Region:    "us-east-1",
+		AccessKey: "access",
+		SecretKey: "secret",
+		Prefix:    "starcharts/",
+	}), objects
+}
+
+func TestBlob(t *testing.T) {
+	is := is.New(t)
+	blob, objects := newTestBlob(t)
+
+	is.NoErr(blob.Put("foo", []string{"bar"}))
+	is.Equal(len(objects["/bucket/starcharts/foo"]) > 0, true) // should be stored under bucket and prefix
+
+	var result []string
+	is.NoErr(blob.Get("foo", &result))
+	is.Equal([]string{"bar"}, result)
+
+	is.NoErr(blob.Delete("foo"))
+	is.True(blob.Get("foo", &result) != nil) // should be gone
+}
+
+func TestBlobTTL(t *testing.T) {
+	for name, tt := range map[string]struct {
+		ttl     time.Duration
+		expires time.Duration
+	}{
+		"default":  {ttl: 0, expires: DefaultTTL},
+		"positive": {ttl: time.Minute, expires: time.Minute},
+		"negative": {ttl: -time.Minute},
+	} {
+		t.Run(name, func(t *testing.T) {
+			is := is.New(t)
+			blob, objects := newTestBlob(t)
+			now := time.Now()
+			blob.now = func() time.Time { return now }
+
+			is.NoErr(blob.PutWithTTL("foo", "bar", tt.ttl))
+			var result string
+			if tt.expires == 0 {
+				now = now.Add(100 * 365 * 24 * time.Hour)
+				is.NoErr(blob.Get("foo", &result)) // should never expire
+				return
+			}
+			now = now.Add(tt.expires - time.Second)
+			is.NoErr(blob.Get("foo", &result)) // should not have expired yet
+			now = now.Add(2 * time.Second)
+			is.True(blob.Get("foo", &result) != nil) // should have expired
+			_, ok := objects["/bucket/starcharts/foo"]
+			is.True(!ok) // should have deleted the expired object
+		})
+	}
+}
+
+func TestBlobEscapedKey(t *testing.T) {
+	is := is.New(t)
+	blob, objects := newTestBlob(t)
+
+	is.NoErr(blob.Put("caarlos0/starcharts@v2_1", "page"))
+	is.True(len(objects["/bucket/starcharts/caarlos0/starcharts@v2_1"]) > 0) // should be stored under the key
+	var result string
+	is.NoErr(blob.Get("caarlos0/starcharts@v2_1", &result))
+	is.Equal("page", result)
+}
+
+func TestURIEncode(t *testing.T) {
+	for path, expected := range map[string]string{
+		"/bucket/foo":                      "/bucket/foo",
+		"/bucket/caarlos0/starcharts@v2_1": "/bucket/caarlos0/starcharts%40v2_1",
+		"/bucket/a b+c~d.e-f":              "/bucket/a%20b%2Bc~d.e-f",
+		"/bucket/ção":                      "/bucket/%C3%A7%C3%A3o",
+	} {
+		t.Run(path, func(t *testing.T) {
+			is.New(t).Equal(expected, uriEncode(path))
+		})
+	}
+}
+
+func TestTiered(t *testing.T) {
+	is := is.New(t)
+	hot, hotObjects := newTestBlob(t)
+	cold, coldObjects := newTestBlob(t)
+	tiered := NewTiered(hot, cold, 16)
+
+	is.NoErr(tiered.Put("small", "bar"))
+	is.NoErr(tiered.Put("large", strings.Repeat("bar", 100)))
+	is.Equal(len(hotObjects), 1)  // small entry should be on the hot tier
+	is.Equal(len(coldObjects), 1) // large entry should be on the cold tier
+
+	var result string
+	is.NoErr(tiered.Get("small", &result))
+	is.Equal("bar", result)
+	is.NoErr(tiered.Get("large", &result))
+	is.Equal(strings.Repeat("bar", 100), result)
+}
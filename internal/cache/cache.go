@@ -1,59 +1,104 @@
 package cache
 
 import (
+	"errors"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
 	rediscache "github.com/go-redis/cache"
 	"github.com/go-redis/redis"
-	"github.com/prometheus/client_golang/prometheus"
 	msgpack "gopkg.in/vmihailenco/msgpack.v2"
 )
 
-// nolint: gochecknoglobals
-var cacheGets = prometheus.NewCounter(
-	prometheus.CounterOpts{
-		Namespace: "starcharts",
-		Subsystem: "cache",
-		Name:      "gets_total",
-		Help:      "Total number of successful cache gets",
-	},
-)
+// largeEntrySize is the serialized size above which cache entries are
+// logged, to find out what takes up the cache memory.
+const largeEntrySize = 1 << 20
 
+// pageKeyRe matches the keys of stargazers pages, e.g. owner/repo@v2_3.
 // nolint: gochecknoglobals
-var cachePuts = prometheus.NewCounter(
-	prometheus.CounterOpts{
-		Namespace: "starcharts",
-		Subsystem: "cache",
-		Name:      "puts_total",
-		Help:      "Total number of successful cache puts",
-	},
-)
+var pageKeyRe = regexp.MustCompile(`@v\d+_\d+$`)
 
-// nolint: gochecknoglobals
-var cacheDeletes = prometheus.NewCounter(
-	prometheus.CounterOpts{
-		Namespace: "starcharts",
-		Subsystem: "cache",
-		Name:      "deletes_total",
-		Help:      "Total number of successful cache deletes",
-	},
-)
+// entryType classifies the cache entry with the given key, to break down the
+// cache sizes.
+func entryType(key string) string {
+	switch {
+	case strings.HasSuffix(key, "_etag"):
+		return "etag"
+	case pageKeyRe.MatchString(key):
+		return "page"
+	case strings.HasSuffix(key, "_org_repos"):
+		return "list"
+	case strings.HasPrefix(key, "last_chart_"):
+		return "chart"
+	default:
+		return "other"
+	}
+}
+
+// sizedItem is an object put in the cache, along with its key, so its size
+// can be recorded once it is serialized.
+type sizedItem struct {
+	key string
+	obj interface{}
+}
+
+// observeSize records the serialized size of the entry with the given key.
+func observeSize(key string, size int) {
+	cachePutSizes.WithLabelValues(entryType(key)).Observe(float64(size))
+	if size > largeEntrySize {
+		log.WithField("key", key).WithField("size", size).Warn("large cache entry")
+	}
+}
+
+// ErrNotFound happens when the key is not in the cache.
+// Any other error means the cache backend failed.
+var ErrNotFound = errors.New("cache: key not found")
+
+// DefaultTTL is how long entries put with a zero ttl are kept.
+const DefaultTTL = time.Hour
 
-// nolint: gochecknoinits
-func init() {
-	prometheus.MustRegister(cacheGets, cachePuts)
+// Cache stores api responses by key.
+//
+// Every backend reads ttls the same way: a zero ttl means DefaultTTL, and a
+// negative one means the key never expires.
+type Cache interface {
+	Get(key string, result interface{}) error
+	Put(key string, obj interface{}) error
+	PutWithTTL(key string, obj interface{}, ttl time.Duration) error
+	Delete(key string) error
+	Close() error
+}
+
+// Scanner can list the keys of a cache and their sizes.
+type Scanner interface {
+	Keys(pattern string) ([]string, error)
+	Size(key string) (int64, error)
 }
 
 // Redis cache.
 type Redis struct {
-	redis *redis.Client
+	redis redis.UniversalClient
 	codec *rediscache.Codec
 }
 
-// New redis cache.
-func New(redis *redis.Client) *Redis {
+// New redis cache, on a single node, a cluster or a sentinel monitored
+// master.
+func New(redis redis.UniversalClient) *Redis {
 	codec := &rediscache.Codec{
 		Redis: redis,
 		Marshal: func(v interface{}) ([]byte, error) {
-			return msgpack.Marshal(v)
+			item, ok := v.(sizedItem)
+			if !ok {
+				return msgpack.Marshal(v)
+			}
+			bts, err := msgpack.Marshal(item.obj)
+			if err == nil {
+				observeSize(item.key, len(bts))
+			}
+			return bts, err
 		},
 		Unmarshal: func(b []byte, v interface{}) error {
 			return msgpack.Unmarshal(b, v)
@@ -74,6 +119,9 @@ func (c *Redis) Close() error {
 // Get from cache by key.
 func (c *Redis) Get(key string, result interface{}) error {
 	if err := c.codec.Get(key, result); err != nil {
+		if errors.Is(err, rediscache.ErrCacheMiss) {
+			return ErrNotFound
+		}
 		return err
 	}
 	cacheGets.Inc()
@@ -82,9 +130,19 @@ func (c *Redis) Get(key string, result interface{}) error {
 
 // Put on cache.
 func (c *Redis) Put(key string, obj interface{}) error {
+	return c.PutWithTTL(key, obj, 0)
+}
+
+// PutWithTTL puts on cache, expiring the key after the given ttl.
+// A zero ttl means DefaultTTL, and a negative one never expires.
+func (c *Redis) PutWithTTL(key string, obj interface{}, ttl time.Duration) error {
+	if ttl == 0 {
+		ttl = DefaultTTL
+	}
 	if err := c.codec.Set(&rediscache.Item{
-		Key:    key,
-		Object: obj,
+		Key:        key,
+		Object:     sizedItem{key: key, obj: obj},
+		Expiration: ttl,
 	}); err != nil {
 		return err
 	}
@@ -100,3 +158,46 @@ func (c *Redis) Delete(key string) error {
 	cacheDeletes.Inc()
 	return nil
 }
+
+// Keys returns the keys matching the given pattern.
+//
+// It iterates over the keyspace with SCAN, so it doesn't block redis like
+// KEYS would. On a cluster, it scans every master, as each only has the keys
+// of its own slots.
+func (c *Redis) Keys(pattern string) ([]string, error) {
+	cluster, ok := c.redis.(*redis.ClusterClient)
+	if !ok {
+		return scanKeys(c.redis, pattern)
+	}
+	var lock sync.Mutex
+	var keys []string
+	err := cluster.ForEachMaster(func(node *redis.Client) error {
+		nodeKeys, err := scanKeys(node, pattern)
+		lock.Lock()
+		defer lock.Unlock()
+		keys = append(keys, nodeKeys...)
+		return err
+	})
+	return keys, err
+}
+
+func scanKeys(redis redis.Cmdable, pattern string) ([]string, error) {
+	var keys []string
+	var cursor uint64
+	for {
+		page, next, err := redis.Scan(cursor, pattern, 1000).Result()
+		if err != nil {
+			return keys, err
+		}
+		keys = append(keys, page...)
+		if next == 0 {
+			return keys, nil
+		}
+		cursor = next
+	}
+}
+
+// Size returns the size in bytes of the value stored at the given key.
+func (c *Redis) Size(key string) (int64, error) {
+	return c.redis.StrLen(key).Result()
+}
@@ -0,0 +1,83 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis"
+	"github.com/go-redis/redis"
+	"github.com/matryer/is"
+)
+
+func TestRedisNotFound(t *testing.T) {
+	is := is.New(t)
+	mr, _ := miniredis.Run()
+	cache := New(redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	}))
+	defer cache.Close()
+
+	var result string
+	is.Equal(ErrNotFound, cache.Get("foo", &result)) // should be not found
+
+	mr.Close()
+	err := cache.Get("foo", &result)
+	is.True(err != nil)                   // should fail
+	is.True(!errors.Is(err, ErrNotFound)) // should not be mistaken for a miss
+}
+
+func TestEntryType(t *testing.T) {
+	for key, expected := range map[string]string{
+		"caarlos0/starcharts@v2_3":      "page",
+		"caarlos0/starcharts@v2_3_etag": "etag",
+		"caarlos0/starcharts_etag":      "etag",
+		"caarlos0_org_repos":            "list",
+		"last_chart_0123456789abcdef":   "chart",
+		"caarlos0/starcharts_details":   "other",
+	} {
+		t.Run(key, func(t *testing.T) {
+			is := is.New(t)
+			is.Equal(expected, entryType(key))
+		})
+	}
+}
+
+func TestRedisTTL(t *testing.T) {
+	for name, tt := range map[string]struct {
+		ttl      time.Duration
+		expected time.Duration
+	}{
+		"default":  {ttl: 0, expected: DefaultTTL},
+		"positive": {ttl: time.Minute, expected: time.Minute},
+		"negative": {ttl: -time.Minute, expected: 0},
+	} {
+		t.Run(name, func(t *testing.T) {
+			is := is.New(t)
+			mr, _ := miniredis.Run()
+			defer mr.Close()
+			cache := New(redis.NewClient(&redis.Options{
+				Addr: mr.Addr(),
+			}))
+			defer cache.Close()
+
+			is.NoErr(cache.PutWithTTL("foo", "bar", tt.ttl))
+			is.True(mr.Exists("foo"))
+			is.Equal(tt.expected, mr.TTL("foo")) // zero means it never expires
+		})
+	}
+}
+
+func TestRedisPutSize(t *testing.T) {
+	is := is.New(t)
+	mr, _ := miniredis.Run()
+	cache := New(redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	}))
+	defer cache.Close()
+
+	is.NoErr(cache.Put("foo@v2_1", []string{"a", "b"}))
+	var result []string
+	is.NoErr(cache.Get("foo@v2_1", &result))
+	is.Equal([]string{"a", "b"}, result) // should store the object, not its wrapper
+}
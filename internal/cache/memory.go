@@ -0,0 +1,170 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	msgpack "gopkg.in/vmihailenco/msgpack.v2"
+)
+
+// Memory is a small in-process cache in front of another one, usually
+// redis, so hot keys don't need a round trip.
+//
+// Other instances can't invalidate it, so entries are only kept for a short
+// ttl, bounding how stale they can get.
+type Memory struct {
+	next    Cache
+	maxSize int
+	ttl     time.Duration
+	// now is the clock, replaceable in tests.
+	now func() time.Time
+
+	lock    sync.Mutex
+	size    int
+	lru     *list.List
+	entries map[string]*list.Element
+}
+
+type memoryEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// NewMemory creates a new in-process cache in front of next, keeping up to
+// maxSize bytes of entries for up to ttl.
+func NewMemory(next Cache, maxSize int, ttl time.Duration) *Memory {
+	return &Memory{
+		next:    next,
+		maxSize: maxSize,
+		ttl:     ttl,
+		now:     time.Now,
+		lru:     list.New(),
+		entries: map[string]*list.Element{},
+	}
+}
+
+// Get from cache by key, trying the in-process cache first and keeping what
+// is found in the next one.
+func (c *Memory) Get(key string, result interface{}) error {
+	if value, ok := c.get(key); ok {
+		memoryHits.Inc()
+		return msgpack.Unmarshal(value, result)
+	}
+	if err := c.next.Get(key, result); err != nil {
+		return err
+	}
+	if value, err := msgpack.Marshal(result); err == nil {
+		c.set(key, value, c.ttl)
+	}
+	return nil
+}
+
+// Put on cache.
+func (c *Memory) Put(key string, obj interface{}) error {
+	return c.PutWithTTL(key, obj, 0)
+}
+
+// PutWithTTL puts on both caches, expiring the key after the given ttl, or
+// earlier on the in-process cache.
+func (c *Memory) PutWithTTL(key string, obj interface{}, ttl time.Duration) error {
+	if err := c.next.PutWithTTL(key, obj, ttl); err != nil {
+		c.delete(key)
+		return err
+	}
+	value, err := msgpack.Marshal(obj)
+	if err != nil {
+		// still cached on the next cache, just not in-process.
+		c.delete(key)
+		return nil
+	}
+	if ttl <= 0 || ttl > c.ttl {
+		ttl = c.ttl
+	}
+	c.set(key, value, ttl)
+	return nil
+}
+
+// Delete from both caches.
+func (c *Memory) Delete(key string) error {
+	c.delete(key)
+	return c.next.Delete(key)
+}
+
+// Close the next cache.
+func (c *Memory) Close() error {
+	return c.next.Close()
+}
+
+// Keys returns the keys matching the given pattern in the next cache, if it
+// can be scanned.
+func (c *Memory) Keys(pattern string) ([]string, error) {
+	scanner, ok := c.next.(Scanner)
+	if !ok {
+		return nil, nil
+	}
+	return scanner.Keys(pattern)
+}
+
+// Size returns the size in bytes of the value stored at the given key in the
+// next cache, if it can be scanned.
+func (c *Memory) Size(key string) (int64, error) {
+	scanner, ok := c.next.(Scanner)
+	if !ok {
+		return 0, nil
+	}
+	return scanner.Size(key)
+}
+
+func (c *Memory) get(key string) ([]byte, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*memoryEntry)
+	if !c.now().Before(entry.expires) {
+		c.remove(elem)
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+	return entry.value, true
+}
+
+// set keeps the given value, evicting the least recently used entries until
+// everything fits in the max size.
+func (c *Memory) set(key string, value []byte, ttl time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		c.remove(elem)
+	}
+	if len(value) > c.maxSize {
+		return
+	}
+	c.entries[key] = c.lru.PushFront(&memoryEntry{
+		key:     key,
+		value:   value,
+		expires: c.now().Add(ttl),
+	})
+	c.size += len(value)
+	for c.size > c.maxSize {
+		c.remove(c.lru.Back())
+	}
+}
+
+func (c *Memory) delete(key string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		c.remove(elem)
+	}
+}
+
+func (c *Memory) remove(elem *list.Element) {
+	entry := c.lru.Remove(elem).(*memoryEntry)
+	delete(c.entries, entry.key)
+	c.size -= len(entry.value)
+}
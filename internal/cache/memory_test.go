@@ -0,0 +1,118 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis"
+	"github.com/go-redis/redis"
+	"github.com/matryer/is"
+)
+
+// countingCache counts the gets reaching the cache it wraps.
+type countingCache struct {
+	Cache
+	gets int
+}
+
+func (c *countingCache) Get(key string, result interface{}) error {
+	c.gets++
+	return c.Cache.Get(key, result)
+}
+
+func newTestMemory(t *testing.T, maxSize int) (*Memory, *countingCache) {
+	t.Helper()
+	mr, _ := miniredis.Run()
+	t.Cleanup(mr.Close)
+	l2 := &countingCache{Cache: New(redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	}))}
+	t.Cleanup(func() { _ = l2.Close() })
+	return NewMemory(l2, maxSize, time.Minute), l2
+}
+
+func TestMemory(t *testing.T) {
+	t.Run("hits don't touch the next cache", func(t *testing.T) {
+		is := is.New(t)
+		cache, l2 := newTestMemory(t, 1024)
+		is.NoErr(cache.Put("foo", "bar"))
+
+		var result string
+		is.NoErr(cache.Get("foo", &result))
+		is.Equal("bar", result)
+		is.Equal(0, l2.gets) // should be answered in-process
+	})
+
+	t.Run("next cache hits are kept", func(t *testing.T) {
+		is := is.New(t)
+		cache, l2 := newTestMemory(t, 1024)
+		is.NoErr(l2.Put("foo", "bar"))
+
+		var result string
+		is.NoErr(cache.Get("foo", &result))
+		is.NoErr(cache.Get("foo", &result))
+		is.Equal("bar", result)
+		is.Equal(1, l2.gets) // should only ask the next cache once
+	})
+
+	t.Run("delete clears both caches", func(t *testing.T) {
+		is := is.New(t)
+		cache, l2 := newTestMemory(t, 1024)
+		is.NoErr(cache.Put("foo", "bar"))
+		is.NoErr(cache.Delete("foo"))
+
+		var result string
+		is.Equal(ErrNotFound, cache.Get("foo", &result))
+		is.Equal(ErrNotFound, l2.Get("foo", &result))
+	})
+
+	t.Run("misses", func(t *testing.T) {
+		is := is.New(t)
+		cache, l2 := newTestMemory(t, 1024)
+		var result string
+		is.Equal(ErrNotFound, cache.Get("foo", &result))
+		is.Equal(ErrNotFound, cache.Get("foo", &result))
+		is.Equal(2, l2.gets) // should not keep misses
+	})
+
+	t.Run("expires", func(t *testing.T) {
+		is := is.New(t)
+		cache, l2 := newTestMemory(t, 1024)
+		now := time.Now()
+		cache.now = func() time.Time { return now }
+		is.NoErr(cache.PutWithTTL("short", "a", time.Second))
+		is.NoErr(cache.Put("long", "b"))
+
+		now = now.Add(2 * time.Second)
+		var result string
+		is.NoErr(cache.Get("long", &result))
+		is.Equal(0, l2.gets) // should still have the long one
+		is.NoErr(cache.Get("short", &result))
+		is.Equal(1, l2.gets) // should have expired the short one
+
+		now = now.Add(time.Minute)
+		is.NoErr(cache.Get("long", &result))
+		is.Equal(2, l2.gets) // should expire everything after its own ttl
+	})
+
+	t.Run("bounded size", func(t *testing.T) {
+		is := is.New(t)
+		cache, l2 := newTestMemory(t, 24)
+		is.NoErr(cache.Put("a", "0123456789"))
+		is.NoErr(cache.Put("b", "0123456789"))
+		var result string
+		is.NoErr(cache.Get("a", &result)) // a is now the most recently used
+		is.NoErr(cache.Put("c", "0123456789"))
+		is.True(cache.size <= 24)
+
+		is.NoErr(cache.Get("a", &result))
+		is.NoErr(cache.Get("c", &result))
+		is.Equal(0, l2.gets) // should keep the recently used ones
+		is.NoErr(cache.Get("b", &result))
+		is.Equal(1, l2.gets) // should have evicted the least recently used
+
+		is.NoErr(cache.Put("big", "0123456789012345678901234567890123456789"))
+		is.NoErr(cache.Get("big", &result))
+		is.Equal(2, l2.gets) // should not keep entries bigger than the max size
+	})
+}
@@ -0,0 +1,72 @@
+package cache
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// nolint: gochecknoglobals
+var cacheGets = prometheus.NewCounter(
+	prometheus.CounterOpts{
+		Namespace: "starcharts",
+		Subsystem: "cache",
+		Name:      "gets_total",
+		Help:      "Total number of successful cache gets",
+	},
+)
+
+// nolint: gochecknoglobals
+var cachePuts = prometheus.NewCounter(
+	prometheus.CounterOpts{
+		Namespace: "starcharts",
+		Subsystem: "cache",
+		Name:      "puts_total",
+		Help:      "Total number of successful cache puts",
+	},
+)
+
+// nolint: gochecknoglobals
+var cacheDeletes = prometheus.NewCounter(
+	prometheus.CounterOpts{
+		Namespace: "starcharts",
+		Subsystem: "cache",
+		Name:      "deletes_total",
+		Help:      "Total number of successful cache deletes",
+	},
+)
+
+// nolint: gochecknoglobals
+var cachePutSizes = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Namespace: "starcharts",
+		Subsystem: "cache",
+		Name:      "put_size_bytes",
+		Help:      "Size of the serialized cache entries put, by type",
+		Buckets:   prometheus.ExponentialBuckets(64, 4, 9),
+	},
+	[]string{"type"},
+)
+
+// nolint: gochecknoglobals
+var memoryHits = prometheus.NewCounter(
+	prometheus.CounterOpts{
+		Namespace: "starcharts",
+		Subsystem: "cache",
+		Name:      "memory_hits_total",
+		Help:      "Total number of cache gets answered by the in-process cache",
+	},
+)
+
+// metrics are all the cache metrics, named starcharts_cache_*, registered
+// with the default registry, so they are exposed on /metrics along with
+// everything else.
+// nolint: gochecknoglobals
+var metrics = []prometheus.Collector{
+	cacheGets,
+	cachePuts,
+	cacheDeletes,
+	cachePutSizes,
+	memoryHits,
+}
+
+// nolint: gochecknoinits
+func init() {
+	prometheus.MustRegister(metrics...)
+}
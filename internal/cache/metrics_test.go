@@ -0,0 +1,27 @@
+package cache
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestMetricsRegistered(t *testing.T) {
+	nameRe := regexp.MustCompile(`fqName: "([^"]*)"`)
+	for _, collector := range metrics {
+		descs := make(chan *prometheus.Desc, 1)
+		collector.Describe(descs)
+		name := nameRe.FindStringSubmatch((<-descs).String())[1]
+		t.Run(name, func(t *testing.T) {
+			is := is.New(t)
+			is.True(strings.HasPrefix(name, "starcharts_cache_")) // should be named starcharts_cache_*
+			var registered prometheus.AlreadyRegisteredError
+			err := prometheus.Register(collector)
+			is.True(errors.As(err, &registered)) // should be registered with the default registry
+		})
+	}
+}
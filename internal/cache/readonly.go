@@ -0,0 +1,59 @@
+package cache
+
+import "time"
+
+// ReadOnly is a cache that is only read from, e.g. a replica of a cache that
+// another instance writes to. Writes and deletes are silently dropped.
+type ReadOnly struct {
+	next Cache
+}
+
+// NewReadOnly creates a read-only view of the given cache.
+func NewReadOnly(next Cache) *ReadOnly {
+	return &ReadOnly{next: next}
+}
+
+// Get from the next cache.
+func (c *ReadOnly) Get(key string, result interface{}) error {
+	return c.next.Get(key, result)
+}
+
+// Put does nothing.
+func (c *ReadOnly) Put(key string, obj interface{}) error {
+	return nil
+}
+
+// PutWithTTL does nothing.
+func (c *ReadOnly) PutWithTTL(key string, obj interface{}, ttl time.Duration) error {
+	return nil
+}
+
+// Delete does nothing.
+func (c *ReadOnly) Delete(key string) error {
+	return nil
+}
+
+// Close the next cache.
+func (c *ReadOnly) Close() error {
+	return c.next.Close()
+}
+
+// Keys returns the keys matching the given pattern in the next cache, if it
+// can be scanned.
+func (c *ReadOnly) Keys(pattern string) ([]string, error) {
+	scanner, ok := c.next.(Scanner)
+	if !ok {
+		return nil, nil
+	}
+	return scanner.Keys(pattern)
+}
+
+// Size returns the size in bytes of the value stored at the given key in the
+// next cache, if it can be scanned.
+func (c *ReadOnly) Size(key string) (int64, error) {
+	scanner, ok := c.next.(Scanner)
+	if !ok {
+		return 0, nil
+	}
+	return scanner.Size(key)
+}
@@ -0,0 +1,39 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis"
+	"github.com/go-redis/redis"
+	"github.com/matryer/is"
+)
+
+func TestReadOnly(t *testing.T) {
+	is := is.New(t)
+	mr, _ := miniredis.Run()
+	defer mr.Close()
+	next := New(redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	}))
+	defer next.Close()
+	cache := NewReadOnly(next)
+
+	is.NoErr(next.Put("written", "by the writer"))
+	var result string
+	is.NoErr(cache.Get("written", &result))
+	is.Equal("by the writer", result) // should read from the next cache
+
+	is.NoErr(cache.Put("foo", "bar"))
+	is.NoErr(cache.PutWithTTL("bar", "foo", time.Minute))
+	is.True(errors.Is(next.Get("foo", &result), ErrNotFound)) // should not write
+	is.True(errors.Is(next.Get("bar", &result), ErrNotFound)) // should not write
+
+	is.NoErr(cache.Delete("written"))
+	is.NoErr(next.Get("written", &result)) // should not delete
+
+	keys, err := cache.Keys("*")
+	is.NoErr(err)
+	is.Equal([]string{"written"}, keys) // should scan the next cache
+}
@@ -0,0 +1,76 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// RedisConfig configures the connection to redis.
+type RedisConfig struct {
+	// URL is the single node to connect to, e.g. redis://:pass@host:6379/1.
+	// Behind sentinels, only its password, database and tls settings are
+	// used. Clusters only have one database, so it is ignored there too.
+	URL          string
+	PoolSize     int
+	DialTimeout  time.Duration
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	MaxRetries   int
+	// ClusterAddrs are seed nodes of a redis cluster, if not empty.
+	ClusterAddrs []string
+	// SentinelAddrs are the sentinels monitoring SentinelMaster, if not
+	// empty, so the client follows the master when it fails over.
+	SentinelAddrs  []string
+	SentinelMaster string
+}
+
+// NewRedisClient connects to a redis cluster, to the master monitored by the
+// given sentinels, or to a single node by default.
+func NewRedisClient(config RedisConfig) (redis.UniversalClient, error) {
+	options, err := redis.ParseURL(config.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis url: %w", err)
+	}
+	options.PoolSize = config.PoolSize
+	options.DialTimeout = config.DialTimeout
+	options.ReadTimeout = config.ReadTimeout
+	options.WriteTimeout = config.WriteTimeout
+	options.MaxRetries = config.MaxRetries
+
+	switch {
+	case len(config.ClusterAddrs) > 0 && len(config.SentinelAddrs) > 0:
+		return nil, errors.New("redis can't be both a cluster and behind sentinels")
+	case len(config.ClusterAddrs) > 0:
+		return redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:        config.ClusterAddrs,
+			Password:     options.Password,
+			PoolSize:     options.PoolSize,
+			DialTimeout:  options.DialTimeout,
+			ReadTimeout:  options.ReadTimeout,
+			WriteTimeout: options.WriteTimeout,
+			MaxRetries:   options.MaxRetries,
+			TLSConfig:    options.TLSConfig,
+		}), nil
+	case len(config.SentinelAddrs) > 0:
+		if config.SentinelMaster == "" {
+			return nil, errors.New("missing the name of the redis master monitored by the sentinels")
+		}
+		return redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:    config.SentinelMaster,
+			SentinelAddrs: config.SentinelAddrs,
+			Password:      options.Password,
+			DB:            options.DB,
+			PoolSize:      options.PoolSize,
+			DialTimeout:   options.DialTimeout,
+			ReadTimeout:   options.ReadTimeout,
+			WriteTimeout:  options.WriteTimeout,
+			MaxRetries:    options.MaxRetries,
+			TLSConfig:     options.TLSConfig,
+		}), nil
+	default:
+		return redis.NewClient(options), nil
+	}
+}
@@ -0,0 +1,107 @@
+package cache
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/alicebob/miniredis"
+	"github.com/go-redis/redis"
+	"github.com/matryer/is"
+)
+
+func TestNewRedisClient(t *testing.T) {
+	t.Run("single node", func(t *testing.T) {
+		is := is.New(t)
+		mr, _ := miniredis.Run()
+		defer mr.Close()
+		client, err := NewRedisClient(RedisConfig{URL: "redis://" + mr.Addr() + "/2"})
+		is.NoErr(err)
+		defer client.Close()
+		_, ok := client.(*redis.Client)
+		is.True(ok) // should be a single node client
+		is.NoErr(client.Set("foo", "bar", 0).Err())
+		mr.Select(2)
+		is.True(mr.Exists("foo")) // should use the database of the url
+	})
+
+	t.Run("cluster", func(t *testing.T) {
+		is := is.New(t)
+		client, err := NewRedisClient(RedisConfig{
+			URL:          "redis://:@localhost:6379/1",
+			ClusterAddrs: []string{"localhost:7000", "localhost:7001"},
+		})
+		is.NoErr(err)
+		defer client.Close()
+		_, ok := client.(*redis.ClusterClient)
+		is.True(ok) // should be a cluster client
+	})
+
+	t.Run("sentinel", func(t *testing.T) {
+		is := is.New(t)
+		client, err := NewRedisClient(RedisConfig{
+			URL:            "redis://:@localhost:6379/1",
+			SentinelAddrs:  []string{"localhost:26379"},
+			SentinelMaster: "mymaster",
+		})
+		is.NoErr(err)
+		defer client.Close()
+		is.True(client != nil)
+	})
+
+	for name, config := range map[string]RedisConfig{
+		"invalid url": {URL: "http://localhost"},
+		"sentinel without master": {
+			URL:           "redis://:@localhost:6379/1",
+			SentinelAddrs: []string{"localhost:26379"},
+		},
+		"cluster and sentinel": {
+			URL:            "redis://:@localhost:6379/1",
+			ClusterAddrs:   []string{"localhost:7000"},
+			SentinelAddrs:  []string{"localhost:26379"},
+			SentinelMaster: "mymaster",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			is := is.New(t)
+			_, err := NewRedisClient(config)
+			is.True(err != nil)
+		})
+	}
+}
+
+func TestRedisKeysCluster(t *testing.T) {
+	is := is.New(t)
+	first, _ := miniredis.Run()
+	defer first.Close()
+	second, _ := miniredis.Run()
+	defer second.Close()
+	// miniredis doesn't speak the cluster protocol, so the slots are given
+	// upfront, half on each node.
+	cluster := redis.NewClusterClient(&redis.ClusterOptions{
+		ClusterSlots: func() ([]redis.ClusterSlot, error) {
+			return []redis.ClusterSlot{
+				{Start: 0, End: 8191, Nodes: []redis.ClusterNode{{Addr: first.Addr()}}},
+				{Start: 8192, End: 16383, Nodes: []redis.ClusterNode{{Addr: second.Addr()}}},
+			}, nil
+		},
+	})
+	cache := New(cluster)
+	defer cache.Close()
+
+	// miniredis doesn't answer COMMAND either, so the client would route
+	// each key to a random slot: the keys are spread by hand instead.
+	expected := []string{"a/a@v2_1", "b/b@v2_1", "c/c@v2_1", "d/d@v2_1", "e/e@v2_1"}
+	for i, key := range expected {
+		node := first
+		if i%2 == 1 {
+			node = second
+		}
+		is.NoErr(node.Set(key, "page"))
+	}
+	is.NoErr(first.Set("a/a_etag", "etag"))
+
+	keys, err := cache.Keys("*@v2_*")
+	is.NoErr(err)
+	sort.Strings(keys)
+	is.Equal(expected, keys) // should find the keys of every node
+}
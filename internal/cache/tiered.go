@@ -0,0 +1,101 @@
+package cache
+
+import (
+	"errors"
+	"time"
+
+	msgpack "gopkg.in/vmihailenco/msgpack.v2"
+)
+
+// Tiered is a cache that keeps small entries in a hot cache and offloads
+// entries larger than a threshold to a cold one, usually a blob store.
+type Tiered struct {
+	hot     Cache
+	cold    Cache
+	maxSize int
+}
+
+// NewTiered creates a new tiered cache, storing entries bigger than maxSize
+// bytes in cold.
+func NewTiered(hot, cold Cache, maxSize int) *Tiered {
+	return &Tiered{
+		hot:     hot,
+		cold:    cold,
+		maxSize: maxSize,
+	}
+}
+
+// Get from cache by key, trying the hot cache first.
+func (c *Tiered) Get(key string, result interface{}) error {
+	err := c.hot.Get(key, result)
+	if err == nil {
+		return nil
+	}
+	if coldErr := c.cold.Get(key, result); !errors.Is(coldErr, ErrNotFound) {
+		return coldErr
+	}
+	// not in the cold tier either, report the hot tier failure, if any.
+	return err
+}
+
+// Put on cache.
+func (c *Tiered) Put(key string, obj interface{}) error {
+	return c.PutWithTTL(key, obj, 0)
+}
+
+// PutWithTTL puts on the tier matching the entry size, expiring the key
+// after the given ttl.
+func (c *Tiered) PutWithTTL(key string, obj interface{}, ttl time.Duration) error {
+	bts, err := msgpack.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	if len(bts) <= c.maxSize {
+		return c.hot.PutWithTTL(key, obj, ttl)
+	}
+	if err := c.cold.PutWithTTL(key, obj, ttl); err != nil {
+		return err
+	}
+	// the entry might have been small before, drop it so it isn't served
+	// stale from the hot tier.
+	_ = c.hot.Delete(key)
+	return nil
+}
+
+// Delete from both tiers.
+func (c *Tiered) Delete(key string) error {
+	hotErr := c.hot.Delete(key)
+	if err := c.cold.Delete(key); err != nil {
+		return err
+	}
+	return hotErr
+}
+
+// Close both tiers.
+func (c *Tiered) Close() error {
+	hotErr := c.hot.Close()
+	if err := c.cold.Close(); err != nil {
+		return err
+	}
+	return hotErr
+}
+
+// Keys returns the keys matching the given pattern in the hot tier, if it
+// can be scanned.
+func (c *Tiered) Keys(pattern string) ([]string, error) {
+	scanner, ok := c.hot.(Scanner)
+	if !ok {
+		return nil, nil
+	}
+	return scanner.Keys(pattern)
+}
+
+// Size returns the size in bytes of the value stored at the given key in the
+// hot tier, if it can be scanned.
+func (c *Tiered) Size(key string) (int64, error) {
+	scanner, ok := c.hot.(Scanner)
+	if !ok {
+		return 0, nil
+	}
+	return scanner.Size(key)
+}
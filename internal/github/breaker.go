@@ -0,0 +1,83 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ErrCircuitOpen happens when github failed too many times in a row, and we
+// stopped calling it for a while.
+var ErrCircuitOpen = errors.New("github api is unavailable, please try again later")
+
+var circuitOpen = prometheus.NewGauge(prometheus.GaugeOpts{
+	Namespace: "starcharts",
+	Subsystem: "github",
+	Name:      "circuit_open",
+	Help:      "Whether calls to the github api are being short-circuited",
+})
+
+func init() {
+	prometheus.MustRegister(circuitOpen)
+}
+
+// breaker is a circuit breaker: after threshold consecutive failures, it
+// rejects all calls for the cooldown period.
+// Once it is over, calls are let through again, but a single failure opens
+// it again.
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	lock      sync.Mutex
+	failures  int
+	openUntil time.Time
+}
+
+func newBreaker(threshold int, cooldown time.Duration) *breaker {
+	return &breaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       time.Now,
+	}
+}
+
+// allow tells whether a call should go through.
+func (b *breaker) allow() bool {
+	if b == nil || b.threshold <= 0 {
+		return true
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return !b.now().Before(b.openUntil)
+}
+
+// record records the outcome of a call.
+func (b *breaker) record(resp *http.Response, err error) {
+	if b == nil || b.threshold <= 0 {
+		return
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		// our own deadlines say nothing about github's health.
+		return
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if err == nil && resp.StatusCode < http.StatusInternalServerError {
+		b.failures = 0
+		circuitOpen.Set(0)
+		return
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		log.Warnf("github failed %d times in a row, short-circuiting for %s", b.failures, b.cooldown)
+		b.openUntil = b.now().Add(b.cooldown)
+		circuitOpen.Set(1)
+	}
+}
@@ -0,0 +1,47 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestBreaker(t *testing.T) {
+	is := is.New(t)
+	now := time.Now()
+	b := newBreaker(2, time.Minute)
+	b.now = func() time.Time { return now }
+
+	ok := &http.Response{StatusCode: http.StatusOK}
+	failed := &http.Response{StatusCode: http.StatusBadGateway}
+
+	b.record(failed, nil)
+	is.True(b.allow()) // should allow below the threshold
+	b.record(ok, nil)
+	b.record(failed, nil)
+	is.True(b.allow()) // success should reset the failures
+
+	b.record(nil, context.DeadlineExceeded)
+	is.True(b.allow()) // our own timeouts should not count
+
+	b.record(nil, errors.New("connection refused"))
+	is.True(!b.allow()) // should open after threshold failures
+
+	now = now.Add(time.Minute)
+	is.True(b.allow()) // should allow after the cooldown
+	b.record(failed, nil)
+	is.True(!b.allow()) // should open again on the first failure
+}
+
+func TestBreakerDisabled(t *testing.T) {
+	is := is.New(t)
+	b := newBreaker(0, time.Minute)
+	for i := 0; i < 10; i++ {
+		b.record(nil, errors.New("connection refused"))
+	}
+	is.True(b.allow()) // should always allow
+}
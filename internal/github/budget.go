@@ -0,0 +1,67 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var cacheTimeouts = prometheus.NewCounter(prometheus.CounterOpts{
+	Namespace: "starcharts",
+	Subsystem: "github",
+	Name:      "cache_timeouts_total",
+	Help:      "Total number of cache lookups given up on to leave the request deadline to github",
+})
+
+func init() {
+	prometheus.MustRegister(cacheTimeouts)
+}
+
+// ErrCacheTimeout happens when the request deadline runs out while reading
+// the cache, before github was even asked.
+var ErrCacheTimeout = errors.New("timed out reading from the cache")
+
+// cacheGet gets the given key from the cache, giving up with ErrCacheTimeout
+// after the cache timeout or once ctx is done, so a slow cache leaves the
+// rest of the request deadline to github. Callers treat giving up like a
+// miss.
+func (gh *GitHub) cacheGet(ctx context.Context, key string, result interface{}) error {
+	if _, ok := ctx.Deadline(); !ok && gh.cacheTimeout <= 0 {
+		return gh.cache.Get(key, result)
+	}
+	if gh.cacheTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, gh.cacheTimeout)
+		defer cancel()
+	}
+
+	// the lookup can't be interrupted, so it decodes into its own value,
+	// copied into result only if it answers in time.
+	value := reflect.New(reflect.TypeOf(result).Elem())
+	done := make(chan error, 1)
+	go func() {
+		done <- gh.cache.Get(key, value.Interface())
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			reflect.ValueOf(result).Elem().Set(value.Elem())
+		}
+		return err
+	case <-ctx.Done():
+		cacheTimeouts.Inc()
+		return fmt.Errorf("%w: %s", ErrCacheTimeout, key)
+	}
+}
+
+// cacheDeadlineErr attributes the request deadline running out during the
+// cache lookups of the given repository, if it did.
+func cacheDeadlineErr(ctx context.Context, name string) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %s", ErrCacheTimeout, name)
+	}
+	return nil
+}
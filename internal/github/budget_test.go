@@ -0,0 +1,136 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis"
+	"github.com/caarlos0/starcharts/internal/cache"
+	"github.com/go-redis/redis"
+	"github.com/matryer/is"
+)
+
+// slowCache doesn't answer gets until released, as if the backend hung.
+type slowCache struct {
+	cache.Cache
+	release chan struct{}
+}
+
+func (c slowCache) Get(key string, result interface{}) error {
+	<-c.release
+	return c.Cache.Get(key, result)
+}
+
+func TestBudget(t *testing.T) {
+	repo := Repository{FullName: "test/test", StargazersCount: 2}
+	stars := []Stargazer{
+		{StarredAt: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{StarredAt: time.Date(2022, 1, 2, 0, 0, 0, 0, time.UTC)},
+	}
+	fastGitHub := func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") != "1" {
+			_ = json.NewEncoder(w).Encode([]Stargazer{})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(stars)
+	}
+	slowGitHub := func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}
+
+	setup := func(t *testing.T, slow bool, handler http.HandlerFunc) (*GitHub, cache.Cache) {
+		t.Helper()
+		mr, err := miniredis.Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(mr.Close)
+		var c cache.Cache = cache.New(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+		if slow {
+			release := make(chan struct{})
+			t.Cleanup(func() { close(release) })
+			c = slowCache{Cache: c, release: release}
+		}
+		t.Cleanup(func() { _ = c.Close() })
+		gt := New(testConfig(), c)
+		gt.client = handlerDoer(handler)
+		return gt, c
+	}
+	budget := func(t *testing.T, timeout time.Duration) context.Context {
+		t.Helper()
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		t.Cleanup(cancel)
+		return ctx
+	}
+
+	t.Run("slow cache leaves time to github", func(t *testing.T) {
+		is := is.New(t)
+		gt, c := setup(t, true, fastGitHub)
+		gt.cacheTimeout = 10 * time.Millisecond
+		is.NoErr(c.Put(pageKey(repo.FullName, 1), []Stargazer{{}}))
+
+		result, err := gt.Stargazers(budget(t, 5*time.Second), repo)
+		is.NoErr(err)
+		is.Equal(stars, result) // should have fetched the stars from github
+	})
+
+	t.Run("slow cache runs out the deadline", func(t *testing.T) {
+		is := is.New(t)
+		gt, _ := setup(t, true, fastGitHub)
+
+		_, err := gt.Stargazers(budget(t, 50*time.Millisecond), repo)
+		is.True(errors.Is(err, ErrCacheTimeout)) // should blame the cache
+		is.True(!errors.Is(err, ErrTimeout))
+
+		_, err = gt.RepoDetails(budget(t, 50*time.Millisecond), repo.FullName)
+		is.True(errors.Is(err, ErrCacheTimeout)) // should blame the cache
+	})
+
+	t.Run("slow github runs out the deadline", func(t *testing.T) {
+		is := is.New(t)
+		gt, _ := setup(t, false, slowGitHub)
+		gt.cacheTimeout = 10 * time.Millisecond
+
+		_, err := gt.Stargazers(budget(t, 50*time.Millisecond), repo)
+		is.True(errors.Is(err, ErrTimeout)) // should blame github
+		is.True(!errors.Is(err, ErrCacheTimeout))
+
+		_, err = gt.RepoDetails(budget(t, 50*time.Millisecond), repo.FullName)
+		is.True(errors.Is(err, ErrTimeout)) // should blame github
+	})
+}
+
+func TestCacheGet(t *testing.T) {
+	mr, _ := miniredis.Run()
+	defer mr.Close()
+	redisCache := cache.New(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	defer redisCache.Close()
+	if err := redisCache.Put("foo", "bar"); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("in time", func(t *testing.T) {
+		is := is.New(t)
+		gt := New(testConfig(), redisCache)
+		gt.cacheTimeout = time.Second
+		var result string
+		is.NoErr(gt.cacheGet(context.Background(), "foo", &result))
+		is.Equal("bar", result)
+		is.True(errors.Is(gt.cacheGet(context.Background(), "nope", &result), cache.ErrNotFound))
+	})
+
+	t.Run("too slow", func(t *testing.T) {
+		is := is.New(t)
+		release := make(chan struct{})
+		defer close(release)
+		gt := New(testConfig(), slowCache{Cache: redisCache, release: release})
+		gt.cacheTimeout = 10 * time.Millisecond
+		result := "untouched"
+		is.True(errors.Is(gt.cacheGet(context.Background(), "foo", &result), ErrCacheTimeout))
+		is.Equal("untouched", result) // should not be written once given up on
+	})
+}
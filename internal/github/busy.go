@@ -0,0 +1,41 @@
+package github
+
+import "sync"
+
+// coldFetches tracks the repositories whose stargazers are being fetched with
+// nothing cached yet, which can take a while for big ones.
+type coldFetches struct {
+	lock  sync.Mutex
+	repos map[string]int
+}
+
+// start records a cold fetch of the given repository, returning a function to
+// call once it is done.
+func (c *coldFetches) start(name string) func() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.repos == nil {
+		c.repos = map[string]int{}
+	}
+	c.repos[name]++
+	return func() {
+		c.lock.Lock()
+		defer c.lock.Unlock()
+		if c.repos[name]--; c.repos[name] <= 0 {
+			delete(c.repos, name)
+		}
+	}
+}
+
+func (c *coldFetches) has(name string) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.repos[name] > 0
+}
+
+// Fetching tells whether the stargazers of the given repository are being
+// fetched for the first time, with nothing cached yet, so a request for them
+// now would take a while.
+func (gh *GitHub) Fetching(name string) bool {
+	return gh.cold.has(name)
+}
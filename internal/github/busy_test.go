@@ -0,0 +1,23 @@
+package github
+
+import (
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestColdFetches(t *testing.T) {
+	is := is.New(t)
+	var cold coldFetches
+	is.True(!cold.has("a/b"))
+
+	done1 := cold.start("a/b")
+	done2 := cold.start("a/b")
+	is.True(cold.has("a/b"))
+	is.True(!cold.has("a/c"))
+
+	done1()
+	is.True(cold.has("a/b")) // should still be fetched by the other one
+	done2()
+	is.True(!cold.has("a/b"))
+}
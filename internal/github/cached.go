@@ -0,0 +1,104 @@
+package github
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/apex/log"
+	"github.com/caarlos0/starcharts/internal/cache"
+)
+
+var errCacheNotScannable = errors.New("cache does not support listing keys")
+
+// CachedRepo is a repository with stargazers in the cache.
+type CachedRepo struct {
+	Name  string `json:"name"`
+	Stars int    `json:"stars"`
+	Size  int64  `json:"size"`
+}
+
+// CachedRepos lists the repositories that have stargazers in the cache,
+// along with their cached star count and the approximate size of their
+// cached pages.
+func (gh *GitHub) CachedRepos() ([]CachedRepo, error) {
+	return gh.cachedRepos(true)
+}
+
+// CachedRepoStars lists the repositories that have stargazers in the cache,
+// along with their cached star count, but not their size, which takes a
+// request per cached page.
+func (gh *GitHub) CachedRepoStars() ([]CachedRepo, error) {
+	return gh.cachedRepos(false)
+}
+
+func (gh *GitHub) cachedRepos(sizes bool) ([]CachedRepo, error) {
+	scanner, ok := gh.cache.(cache.Scanner)
+	if !ok {
+		return nil, errCacheNotScannable
+	}
+	// a single scan of every page, grouped by repository, as scanning the
+	// keyspace once per repository would block redis for too long.
+	keys, err := scanner.Keys(pagePrefix("*") + "*")
+	if err != nil {
+		return nil, err
+	}
+	pages := map[string][]string{}
+	listed := map[string]bool{}
+	for _, key := range keys {
+		name, page, ok := parsePageKey(key)
+		if !ok {
+			continue
+		}
+		pages[name] = append(pages[name], key)
+		if page == 1 {
+			listed[name] = true
+		}
+	}
+
+	repos := make([]CachedRepo, 0, len(listed))
+	for name := range listed {
+		log := log.WithField("repo", name)
+
+		// pages are keyed by the name as github spells it, but details by
+		// the normalized name they are requested with.
+		repo := CachedRepo{Name: name}
+		var details Repository
+		detailsKey := strings.ToLower(name)
+		if err := gh.cache.Get(detailsKey, &details); err != nil {
+			log.WithError(err).Warnf("failed to get %s from cache", detailsKey)
+		}
+		repo.Stars = details.StargazersCount
+		if sizes {
+			for _, key := range pages[name] {
+				size, err := scanner.Size(key)
+				if err != nil {
+					log.WithError(err).Warnf("failed to get %s size", key)
+					continue
+				}
+				repo.Size += size
+			}
+		}
+		repos = append(repos, repo)
+	}
+
+	sort.Slice(repos, func(i, j int) bool {
+		return repos[i].Name < repos[j].Name
+	})
+	return repos, nil
+}
+
+// parsePageKey returns the repository and page of the cache key of a
+// stargazers page or its etag, telling whether it is one.
+func parsePageKey(key string) (string, int, bool) {
+	i := strings.LastIndex(key, pagePrefix(""))
+	if i < 0 {
+		return "", 0, false
+	}
+	page, err := strconv.Atoi(strings.TrimSuffix(key[i+len(pagePrefix("")):], "_etag"))
+	if err != nil {
+		return "", 0, false
+	}
+	return key[:i], page, true
+}
@@ -0,0 +1,102 @@
+package github
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis"
+	"github.com/caarlos0/starcharts/internal/cache"
+	"github.com/go-redis/redis"
+	"github.com/matryer/is"
+)
+
+func TestCachedRepos(t *testing.T) {
+	is := is.New(t)
+
+	mr, _ := miniredis.Run()
+	rc := redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	})
+
+	config := testConfig()
+	cache := cache.New(rc)
+	defer cache.Close()
+	gt := New(config, cache)
+
+	stars := []Stargazer{{StarredAt: time.Now()}, {StarredAt: time.Now()}}
+	is.NoErr(cache.Put("test/test", Repository{FullName: "test/test", StargazersCount: 3}))
+	is.NoErr(cache.Put(pageKey("test/test", 1), stars))
+	is.NoErr(cache.Put(pageEtagKey("test/test", 1), "asd"))
+	is.NoErr(cache.Put(pageKey("test/test", 2), stars[:1]))
+	is.NoErr(cache.Put(pageKey("test/test_foo", 1), stars))
+	is.NoErr(cache.Put("test/other", Repository{FullName: "test/other", StargazersCount: 10}))
+	is.NoErr(cache.Put("microsoft/vscode", Repository{FullName: "Microsoft/vscode", StargazersCount: 7}))
+	is.NoErr(cache.Put(pageKey("Microsoft/vscode", 1), stars))
+	is.NoErr(cache.Put(pageKey("test/unlisted", 2), stars))
+	is.NoErr(cache.Put("test/test@v2_last", "not a page"))
+
+	repos, err := gt.CachedRepos()
+	is.NoErr(err)
+	is.Equal(3, len(repos)) // should list repos with cached pages only
+	is.Equal("Microsoft/vscode", repos[0].Name)
+	is.Equal(7, repos[0].Stars) // should find the details under the normalized name
+	repos = repos[1:]
+	is.Equal("test/test", repos[0].Name)
+	is.Equal(3, repos[0].Stars)
+	var size int64
+	for _, key := range []string{pageKey("test/test", 1), pageEtagKey("test/test", 1), pageKey("test/test", 2)} {
+		n, err := cache.Size(key)
+		is.NoErr(err)
+		size += n
+	}
+	is.True(size > 0)
+	is.Equal(size, repos[0].Size) // should sum the size of the cached pages
+	is.Equal("test/test_foo", repos[1].Name)
+	is.Equal(0, repos[1].Stars) // details not cached
+
+	counts, err := gt.CachedRepoStars()
+	is.NoErr(err)
+	is.Equal(3, len(counts))
+	is.Equal(7, counts[0].Stars)          // should find the details under the normalized name
+	is.Equal(int64(0), counts[1].Size)    // should not scan the pages
+	is.Equal("test/test", counts[1].Name) // should still list by name
+
+	scans := &keysCounter{Redis: cache}
+	_, err = New(config, scans).CachedRepos()
+	is.NoErr(err)
+	is.Equal(1, scans.calls) // should scan the keyspace once
+}
+
+// keysCounter counts the scans of the keyspace.
+type keysCounter struct {
+	*cache.Redis
+	calls int
+}
+
+func (c *keysCounter) Keys(pattern string) ([]string, error) {
+	c.calls++
+	return c.Redis.Keys(pattern)
+}
+
+func TestParsePageKey(t *testing.T) {
+	for key, tt := range map[string]struct {
+		name string
+		page int
+		ok   bool
+	}{
+		pageKey("a/b", 1):        {"a/b", 1, true},
+		pageEtagKey("a/b", 12):   {"a/b", 12, true},
+		pageKey("a/b_foo", 3):    {"a/b_foo", 3, true},
+		"a/b":                    {},
+		"a/b@v2_last":            {},
+		pageKey("a/b", 1) + "_x": {},
+	} {
+		t.Run(key, func(t *testing.T) {
+			is := is.New(t)
+			name, page, ok := parsePageKey(key)
+			is.Equal(tt.ok, ok)
+			is.Equal(tt.name, name)
+			is.Equal(tt.page, page)
+		})
+	}
+}
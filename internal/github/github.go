@@ -6,26 +6,104 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"time"
 
 	"github.com/apex/log"
 	"github.com/caarlos0/starcharts/config"
 	"github.com/caarlos0/starcharts/internal/cache"
+	"github.com/caarlos0/starcharts/internal/overrides"
 	"github.com/caarlos0/starcharts/internal/roundrobin"
 	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sync/singleflight"
 )
 
 // ErrRateLimit happens when we rate limit github API.
 var ErrRateLimit = errors.New("rate limited, please try again later")
 
+// ErrNoTokensConfigured happens when the instance has no github tokens to
+// make requests with.
+var ErrNoTokensConfigured = roundrobin.ErrNoTokensConfigured
+
+// ErrNotYetAvailable happens in read-only mode when something isn't cached
+// yet, until the instance that fetches from github caches it.
+var ErrNotYetAvailable = errors.New("not available yet, please try again later")
+
 // ErrGitHubAPI happens when github responds with something other than a 2xx.
 var ErrGitHubAPI = errors.New("failed to talk with github api")
 
+// ErrTimeout happens when fetching all the stargazers takes longer than the
+// configured deadline.
+var ErrTimeout = errors.New("timed out fetching stargazers from github")
+
+// ErrBodyTooLarge happens when github responds with a body larger than we
+// are willing to read.
+var ErrBodyTooLarge = errors.New("github api response is too large")
+
+// defaultMaxBodySize bounds the size of the github api response bodies, so a
+// misbehaving proxy can't make us run out of memory.
+// A page of stargazers is usually well under 1MB.
+const defaultMaxBodySize = 16 << 20
+
+// ErrOverloaded happens when too many stargazers fetches are already in
+// flight.
+var ErrOverloaded = errors.New("too many requests in flight, please try again later")
+
+// doer does http requests, like *http.Client.
+type doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
 // GitHub client struct.
 type GitHub struct {
+	// client does the requests to github, replaceable in tests.
+	client          doer
 	tokens          roundrobin.RoundRobiner
 	pageSize        int
-	cache           *cache.Redis
+	cache           cache.Cache
 	maxRateUsagePct int
+	fetchTimeout    time.Duration
+	cacheTimeout    time.Duration
+	repoTTL         time.Duration
+	etagTTL         time.Duration
+	userAgent       string
+	starsMediaType  string
+	inFlight        chan struct{}
+	breaker         *breaker
+	refreshInterval time.Duration
+	refreshScale    int
+	refreshMax      time.Duration
+	dedupe          bool
+	background      singleflight.Group
+	warmups         warmups
+	cold            coldFetches
+	warmupCooldown  time.Duration
+	retryBudget     int
+	tokenSlots      *tokenSlots
+	maxBodySize     int64
+	repoConcurrency int
+	// maxStars is the most stars a repo can have to fetch all of them, if
+	// positive.
+	maxStars int
+	// precheck checks the repo details can be fetched before fetching its
+	// stargazers, see checkRepo.
+	precheck bool
+	// refetchLast fetches the last page of stargazers without its etag, as
+	// it is the only one that grows.
+	refetchLast bool
+	// samplePages is how many pages StargazersSample fetches, sampling
+	// disabled if zero.
+	samplePages int
+	// pageCapWarnPct is the percentage of the page cap from which fetches
+	// are counted as close to it, disabled if zero.
+	pageCapWarnPct int
+	// readOnly never calls github, serving only what is already cached,
+	// see ErrNotYetAvailable.
+	readOnly bool
+	// overrides are the settings of specific repos, taking precedence over
+	// the ones above.
+	overrides *overrides.Overrides
+	// now is the clock, replaceable in tests.
+	now func() time.Time
 }
 
 var rateLimits = prometheus.NewCounter(prometheus.CounterOpts{
@@ -58,30 +136,156 @@ var rateLimiters = prometheus.NewGaugeVec(prometheus.GaugeOpts{
 	Name:      "rate_limit_remaining",
 }, []string{"token"})
 
+var inFlightFetches = prometheus.NewGauge(prometheus.GaugeOpts{
+	Namespace: "starcharts",
+	Subsystem: "github",
+	Name:      "in_flight_fetches",
+})
+
+var pageRetries = prometheus.NewCounter(prometheus.CounterOpts{
+	Namespace: "starcharts",
+	Subsystem: "github",
+	Name:      "page_retries_total",
+})
+
 func init() {
-	prometheus.MustRegister(rateLimits, effectiveEtags, invalidatedTokens, tokensCount, rateLimiters)
+	prometheus.MustRegister(rateLimits, effectiveEtags, invalidatedTokens, tokensCount, rateLimiters, inFlightFetches, pageRetries)
 }
 
+// DefaultUserAgent is the User-Agent sent to github if none is configured.
+const DefaultUserAgent = "starcharts (+https://github.com/caarlos0/starcharts)"
+
 // New github client.
-func New(config config.Config, cache *cache.Redis) *GitHub {
-	tokensCount.Set(float64(len(config.GitHubTokens)))
+func New(config config.Config, cache cache.Cache) *GitHub {
+	tokens, err := roundrobin.NewFromSource(tokenSource(config), config.GitHubTokensReload)
+	if err != nil {
+		log.WithError(err).Error("failed to load tokens")
+	}
+	tokensCount.Set(float64(len(tokens.Tokens())))
+	if len(tokens.Tokens()) == 0 && !config.ReadOnly {
+		log.Warn("no github tokens configured, charts will fail until GITHUB_TOKENS or GITHUB_TOKENS_FILE is set")
+	}
+	repoOverrides, err := overrides.Load(config.RepoOverridesFile)
+	if err != nil {
+		log.WithError(err).Error("failed to load repo overrides")
+	}
+	userAgent := config.GitHubUserAgent
+	if userAgent == "" {
+		userAgent = DefaultUserAgent
+	}
+	starsMediaType := config.GitHubStarsMediaType
+	if starsMediaType == "" {
+		starsMediaType = defaultStarsMediaType
+	}
+	repoConcurrency := config.GitHubRepoConcurrency
+	if repoConcurrency < 1 {
+		repoConcurrency = 1
+	}
+	var inFlight chan struct{}
+	if config.GitHubMaxInFlight > 0 {
+		inFlight = make(chan struct{}, config.GitHubMaxInFlight)
+	}
 	return &GitHub{
-		tokens:   roundrobin.New(config.GitHubTokens),
-		pageSize: config.GitHubPageSize,
-		cache:    cache,
+		client:          http.DefaultClient,
+		tokens:          tokens,
+		pageSize:        config.GitHubPageSize,
+		cache:           cache,
+		fetchTimeout:    config.GitHubFetchTimeout,
+		cacheTimeout:    config.CacheLookupTimeout,
+		repoTTL:         config.GitHubRepoTTL,
+		etagTTL:         config.GitHubEtagTTL,
+		userAgent:       userAgent,
+		starsMediaType:  starsMediaType,
+		inFlight:        inFlight,
+		breaker:         newBreaker(config.GitHubBreakerFailures, config.GitHubBreakerCooldown),
+		refreshInterval: config.GitHubRefreshInterval,
+		refreshScale:    config.GitHubRefreshScale,
+		refreshMax:      config.GitHubRefreshMax,
+		dedupe:          config.GitHubDedupeStars,
+		warmupCooldown:  config.GitHubWarmupCooldown,
+		retryBudget:     config.GitHubRetryBudget,
+		tokenSlots:      newTokenSlots(config.GitHubTokenMaxConc),
+		maxBodySize:     defaultMaxBodySize,
+		repoConcurrency: repoConcurrency,
+		maxStars:        config.GitHubMaxStars,
+		precheck:        config.GitHubRepoPrecheck,
+		refetchLast:     config.GitHubRefetchLastPage,
+		samplePages:     config.GitHubSamplePages,
+		pageCapWarnPct:  config.GitHubPageCapWarnPct,
+		readOnly:        config.ReadOnly,
+		overrides:       repoOverrides,
+		now:             time.Now,
+	}
+}
+
+// Close stops reloading the tokens, if they are reloaded.
+func (gh *GitHub) Close() error {
+	if closer, ok := gh.tokens.(io.Closer); ok {
+		return closer.Close()
 	}
+	return nil
+}
+
+// RepoConcurrency is how many repositories operations on several of them,
+// like comparisons, fetch at once.
+// Each of them still fetches its own pages concurrently.
+func (gh *GitHub) RepoConcurrency() int {
+	return gh.repoConcurrency
+}
+
+// readBody reads the given response body, failing with ErrBodyTooLarge if it
+// is larger than the max body size.
+func (gh *GitHub) readBody(body io.Reader) ([]byte, error) {
+	bts, err := io.ReadAll(io.LimitReader(body, gh.maxBodySize+1))
+	if err != nil {
+		return bts, err
+	}
+	if int64(len(bts)) > gh.maxBodySize {
+		return nil, fmt.Errorf("%w: more than %d bytes", ErrBodyTooLarge, gh.maxBodySize)
+	}
+	return bts, nil
+}
+
+// tokenSource returns the source of the github tokens: the tokens file, if
+// any, or the GITHUB_TOKENS environment variable.
+func tokenSource(config config.Config) roundrobin.TokenSource {
+	if config.GitHubTokensFile != "" {
+		return roundrobin.File(config.GitHubTokensFile)
+	}
+	return roundrobin.Static(config.GitHubTokens)
 }
 
 const maxTries = 3
 
 func (gh *GitHub) authorizedDo(req *http.Request, try int) (*http.Response, error) {
+	if gh.readOnly {
+		return nil, ErrNotYetAvailable
+	}
 	if try > maxTries {
 		return nil, fmt.Errorf("couldn't find a valid token")
 	}
+	if !gh.breaker.allow() {
+		return nil, ErrCircuitOpen
+	}
+	req.Header.Set("User-Agent", gh.userAgent)
+	if hint := tokenHint(req.Context()); hint != "" {
+		return gh.hintedDo(req, hint)
+	}
+	if try == 0 {
+		if token := gh.pinnedToken(req); token != nil {
+			return gh.tokenDo(req, token)
+		}
+	}
 	token, err := gh.tokens.Pick()
+	if errors.Is(err, ErrNoTokensConfigured) {
+		// unauthorized requests are rate limited too soon to chart anything.
+		return nil, err
+	}
 	if err != nil || token == nil {
 		log.WithError(err).Error("couldn't get a valid token")
-		return http.DefaultClient.Do(req) // try unauthorized request
+		resp, err := gh.client.Do(req) // try unauthorized request
+		gh.breaker.record(resp, err)
+		return resp, err
 	}
 
 	if err := gh.checkToken(token); err != nil {
@@ -90,12 +294,54 @@ func (gh *GitHub) authorizedDo(req *http.Request, try int) (*http.Response, erro
 	}
 
 	// got a valid token, use it
+	return gh.tokenDo(req, token)
+}
+
+// tokenDo does the request with the given token, once it has a slot.
+func (gh *GitHub) tokenDo(req *http.Request, token *roundrobin.Token) (*http.Response, error) {
+	release, err := gh.tokenSlots.acquire(req.Context(), token.Key())
+	if err != nil {
+		return nil, err
+	}
 	req.Header.Add("Authorization", fmt.Sprintf("token %s", token.Key()))
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := gh.client.Do(req)
+	gh.breaker.record(resp, err)
+	return holdSlot(resp, err, release)
+}
+
+// servesCached tells whether the given request error is one to serve the
+// cached data instead of failing, if there is any: github is unavailable, or
+// we are read-only.
+func servesCached(err error) bool {
+	return errors.Is(err, ErrCircuitOpen) || errors.Is(err, ErrNotYetAvailable)
+}
+
+// hintedDo does the request with the hinted token, skipping the rate limit
+// checks, as the hint is meant to see how github treats that token.
+func (gh *GitHub) hintedDo(req *http.Request, hint string) (*http.Response, error) {
+	token, err := gh.hintedToken(hint)
 	if err != nil {
-		return resp, err
+		return nil, err
+	}
+	log.WithField("token", token.String()).Debug("using hinted token")
+	return gh.tokenDo(req, token)
+}
+
+// ValidateTokens checks all tokens against the rate limit api, invalidating
+// the ones github rejects, and returns how many are usable.
+func (gh *GitHub) ValidateTokens() int {
+	var valid int
+	for _, token := range gh.tokens.Tokens() {
+		if err := gh.checkToken(token); err != nil {
+			log.WithError(err).Warnf("token '...%s' failed validation", token)
+		}
+		if token.OK() {
+			valid++
+		}
 	}
-	return resp, err
+	tokensCount.Set(float64(valid))
+	log.Infof("%d/%d tokens are valid", valid, len(gh.tokens.Tokens()))
+	return valid
 }
 
 func (gh *GitHub) checkToken(token *roundrobin.Token) error {
@@ -103,8 +349,9 @@ func (gh *GitHub) checkToken(token *roundrobin.Token) error {
 	if err != nil {
 		return err
 	}
+	req.Header.Set("User-Agent", gh.userAgent)
 	req.Header.Add("Authorization", fmt.Sprintf("token %s", token.Key()))
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := gh.client.Do(req)
 	if err != nil {
 		return err
 	}
@@ -120,7 +367,7 @@ func (gh *GitHub) checkToken(token *roundrobin.Token) error {
 		return fmt.Errorf("request failed with status %d", resp.StatusCode)
 	}
 
-	bts, err := io.ReadAll(resp.Body)
+	bts, err := gh.readBody(resp.Body)
 	if err != nil {
 		return err
 	}
@@ -1,9 +1,15 @@
 package github
 
 import (
+	"context"
 	"testing"
 
+	"github.com/alicebob/miniredis"
+	"github.com/caarlos0/starcharts/config"
+	"github.com/caarlos0/starcharts/internal/cache"
+	"github.com/go-redis/redis"
 	"github.com/matryer/is"
+	"gopkg.in/h2non/gock.v1"
 )
 
 func TestIsRateAboveLimit(t *testing.T) {
@@ -29,3 +35,72 @@ func TestIsRateAboveLimit(t *testing.T) {
 		Limit:     5000,
 	}, 80))
 }
+
+func TestUserAgent(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("https://api.github.com").
+		Get("/rate_limit").
+		MatchHeader("User-Agent", "^starcharts/test$").
+		Reply(200).
+		JSON(rateLimit{rate{Limit: 5000, Remaining: 4000}})
+
+	gock.New("https://api.github.com").
+		Get("/repos/test/test").
+		MatchHeader("User-Agent", "^starcharts/test$").
+		Reply(200).
+		JSON(Repository{FullName: "test/test"})
+
+	mr, _ := miniredis.Run()
+	rc := redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	})
+
+	config := testConfig()
+	config.GitHubUserAgent = "starcharts/test"
+	cache := cache.New(rc)
+	defer cache.Close()
+	gt := New(config, cache)
+
+	is := is.New(t)
+	_, err := gt.RepoDetails(context.TODO(), "test/test")
+	is.NoErr(err)          // should send the user agent
+	is.True(gock.IsDone()) // all requests should have the user agent
+}
+
+func TestDefaultUserAgent(t *testing.T) {
+	is := is.New(t)
+	config := testConfig()
+	config.GitHubUserAgent = ""
+	is.Equal(DefaultUserAgent, New(config, nil).userAgent)
+}
+
+func TestValidateTokens(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("https://api.github.com").
+		Get("/rate_limit").
+		MatchHeader("Authorization", "^token good-token$").
+		Reply(200).
+		JSON(rateLimit{rate{Limit: 5000, Remaining: 4000}})
+
+	gock.New("https://api.github.com").
+		Get("/rate_limit").
+		MatchHeader("Authorization", "^token bad-token$").
+		Reply(401)
+
+	is := is.New(t)
+	config := testConfig()
+	config.GitHubTokens = []string{"good-token", "bad-token"}
+	gt := New(config, nil)
+	is.Equal(1, gt.ValidateTokens()) // should have only one valid token
+	is.True(gock.IsDone())           // should have checked all tokens
+}
+
+// testConfig is the default config with a github token, as github is mocked
+// and requests without tokens fail early.
+func testConfig() config.Config {
+	cfg := config.Get()
+	cfg.GitHubTokens = []string{"XXX"}
+	return cfg
+}
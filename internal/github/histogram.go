@@ -0,0 +1,95 @@
+package github
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// StarHistogram counts stars per bucket of time.
+//
+// Unlike a list of stargazers, its size depends on how old the repository is
+// rather than on how many stars it has, so it is a cheap way to chart huge
+// repositories.
+type StarHistogram struct {
+	bucket time.Duration
+	counts map[int64]int
+	// invalid counts the stars dropped for an invalid starred at time.
+	invalid int
+	now     func() time.Time
+}
+
+// NewStarHistogram creates a new, empty, StarHistogram with the given bucket
+// size.
+func NewStarHistogram(bucket time.Duration) *StarHistogram {
+	return &StarHistogram{
+		bucket: bucket,
+		counts: map[int64]int{},
+		now:    time.Now,
+	}
+}
+
+func (h *StarHistogram) add(stars []Stargazer) {
+	now := h.now()
+	for _, star := range stars {
+		if !validStarredAt(star.StarredAt, now) {
+			h.invalid++
+			continue
+		}
+		h.counts[star.StarredAt.Truncate(h.bucket).Unix()]++
+	}
+}
+
+func (h *StarHistogram) reset() {
+	h.counts = map[int64]int{}
+	h.invalid = 0
+}
+
+// HistogramPoint is the cumulative star count at a given time.
+type HistogramPoint struct {
+	Time  time.Time
+	Total int
+}
+
+// Points returns the cumulative star count at the start of each bucket with
+// stars, oldest first.
+func (h *StarHistogram) Points() []HistogramPoint {
+	buckets := make([]int64, 0, len(h.counts))
+	for bucket := range h.counts {
+		buckets = append(buckets, bucket)
+	}
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i] < buckets[j] })
+
+	points := make([]HistogramPoint, 0, len(buckets))
+	var total int
+	for _, bucket := range buckets {
+		total += h.counts[bucket]
+		points = append(points, HistogramPoint{
+			Time:  time.Unix(bucket, 0).UTC(),
+			Total: total,
+		})
+	}
+	return points
+}
+
+// StargazersHistogram returns the stars of a given repo counted per bucket of
+// time.
+//
+// Stars are counted as their pages arrive and then discarded, so memory stays
+// bounded no matter how many stars the repo has.
+func (gh *GitHub) StargazersHistogram(ctx context.Context, repo Repository, bucket time.Duration) (*StarHistogram, error) {
+	hist := NewStarHistogram(bucket)
+	hist.now = gh.now
+	if err := gh.checkMaxStars(repo); err != nil {
+		return hist, err
+	}
+	if err := gh.checkRepo(ctx, repo); err != nil {
+		return hist, err
+	}
+	if gh.totalPages(repo) > gh.maxPagesFor(repo) {
+		return hist, ErrTooManyStars
+	}
+	err := gh.collectPages(ctx, repo, 1, gh.lastPage(repo), hist)
+	logInvalidStars(repo, hist.invalid)
+	return hist, err
+}
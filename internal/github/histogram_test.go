@@ -0,0 +1,50 @@
+package github
+
+import (
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestStarHistogram(t *testing.T) {
+	is := is.New(t)
+	day := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	hist := NewStarHistogram(24 * time.Hour)
+	hist.add([]Stargazer{
+		{StarredAt: day.Add(48 * time.Hour)},
+		{StarredAt: day.Add(time.Hour)},
+		{StarredAt: day.Add(2 * time.Hour)},
+	})
+	is.Equal([]HistogramPoint{
+		{Time: day, Total: 2},
+		{Time: day.Add(48 * time.Hour), Total: 3},
+	}, hist.Points())
+
+	hist.reset()
+	is.Equal(0, len(hist.Points())) // should be empty after a reset
+}
+
+// benchmarkPages simulates collecting a huge repo, a page at a time.
+func benchmarkPages(b *testing.B, sink func() starSink) {
+	start := time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC)
+	page := make([]Stargazer, 100)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		s := sink()
+		for p := 0; p < 400; p++ {
+			for j := range page {
+				page[j].StarredAt = start.Add(time.Duration(p*len(page)+j) * time.Hour)
+			}
+			s.add(page)
+		}
+	}
+}
+
+func BenchmarkCollect_List(b *testing.B) {
+	benchmarkPages(b, func() starSink { return &starList{} })
+}
+
+func BenchmarkCollect_Histogram(b *testing.B) {
+	benchmarkPages(b, func() starSink { return NewStarHistogram(24 * time.Hour) })
+}
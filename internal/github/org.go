@@ -0,0 +1,135 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/apex/log"
+	"golang.org/x/sync/errgroup"
+)
+
+// maxOrgPages bounds how many pages of repositories are listed for an
+// organization.
+const maxOrgPages = 10
+
+// OrgRepos returns the public repositories of the given organization that
+// have at least one star, sorted by star count, most starred first.
+//
+// The list is cached for as long as repository details are.
+func (gh *GitHub) OrgRepos(ctx context.Context, org string) ([]Repository, error) {
+	var repos []Repository
+	key := org + "_org_repos"
+	if err := gh.cache.Get(key, &repos); err == nil {
+		return repos, nil
+	}
+
+	for page := 1; page <= maxOrgPages; page++ {
+		result, err := gh.getOrgReposPage(ctx, org, page)
+		if err != nil {
+			return repos, err
+		}
+		for _, repo := range result {
+			if repo.StargazersCount > 0 {
+				repos = append(repos, repo)
+			}
+		}
+		if len(result) < orgReposPageSize {
+			break
+		}
+	}
+	sort.SliceStable(repos, func(i, j int) bool {
+		return repos[i].StargazersCount > repos[j].StargazersCount
+	})
+
+	if gh.repoTTL > 0 {
+		if err := gh.cache.PutWithTTL(key, repos, gh.repoTTL); err != nil {
+			log.WithError(err).WithField("org", org).Warnf("failed to cache %s", key)
+		}
+	}
+	return repos, nil
+}
+
+// orgReposPageSize is the page size used to list organization repositories,
+// the maximum github allows.
+const orgReposPageSize = 100
+
+func (gh *GitHub) getOrgReposPage(ctx context.Context, org string, page int) ([]Repository, error) {
+	url := fmt.Sprintf(
+		"https://api.github.com/orgs/%s/repos?type=public&page=%d&per_page=%d",
+		org,
+		page,
+		orgReposPageSize,
+	)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := gh.authorizedDo(req, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	bts, err := gh.readBody(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusForbidden:
+		rateLimits.Inc()
+		log.WithField("org", org).Warn("rate limit hit")
+		return nil, ErrRateLimit
+	case http.StatusOK:
+		var repos []Repository
+		if err := json.Unmarshal(bts, &repos); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidResponse, err)
+		}
+		return repos, nil
+	default:
+		return nil, fmt.Errorf("%w: %v", ErrGitHubAPI, string(bts))
+	}
+}
+
+// AggregateStargazers returns the stargazers of all the given repositories
+// together, sorted by the time they were starred.
+//
+// Repositories are fetched a few at a time, see RepoConcurrency, and the
+// total amount of pages is bound by the same limit as a single repository,
+// failing with ErrTooManyStars if it is exceeded.
+func (gh *GitHub) AggregateStargazers(ctx context.Context, repos []Repository) ([]Stargazer, error) {
+	var pages int
+	for _, repo := range repos {
+		pages += gh.totalPages(repo)
+	}
+	if pages > maxPages {
+		return nil, ErrTooManyStars
+	}
+
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(gh.repoConcurrency)
+	var lock sync.Mutex
+	var stars []Stargazer
+	for _, repo := range repos {
+		repo := repo
+		g.Go(func() error {
+			result, err := gh.Stargazers(gctx, repo)
+			if err != nil {
+				return fmt.Errorf("%s: %w", repo.FullName, err)
+			}
+			lock.Lock()
+			defer lock.Unlock()
+			stars = append(stars, result...)
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	sortStargazers(stars)
+	return stars, nil
+}
@@ -0,0 +1,165 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis"
+	"github.com/caarlos0/starcharts/internal/cache"
+	"github.com/go-redis/redis"
+	"github.com/matryer/is"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestOrgStargazers(t *testing.T) {
+	defer gock.Off()
+
+	mr, _ := miniredis.Run()
+	rc := redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	})
+
+	config := testConfig()
+	cache := cache.New(rc)
+	defer cache.Close()
+	gt := New(config, cache)
+	gt.refreshInterval = 0
+
+	t.Run("list org repos", func(t *testing.T) {
+		is := is.New(t)
+		gock.New("https://api.github.com").
+			Get("/rate_limit").
+			Reply(200).
+			JSON(rateLimit{rate{Limit: 5000, Remaining: 4000}})
+		gock.New("https://api.github.com").
+			Get("/orgs/test/repos").
+			MatchParam("type", "public").
+			MatchParam("page", "1").
+			Reply(200).
+			JSON([]Repository{
+				{FullName: "test/a", StargazersCount: 1},
+				{FullName: "test/empty", StargazersCount: 0},
+				{FullName: "test/b", StargazersCount: 2},
+			})
+		repos, err := gt.OrgRepos(context.TODO(), "test")
+		is.NoErr(err) // should not have errored
+		is.Equal([]Repository{
+			{FullName: "test/b", StargazersCount: 2},
+			{FullName: "test/a", StargazersCount: 1},
+		}, repos)
+		is.True(gock.IsDone()) // should have listed a single page
+	})
+
+	t.Run("aggregate stargazers", func(t *testing.T) {
+		is := is.New(t)
+		now := time.Now().UTC().Truncate(time.Second)
+		gock.New("https://api.github.com").
+			Get("/rate_limit").
+			Times(2).
+			Reply(200).
+			JSON(rateLimit{rate{Limit: 5000, Remaining: 4000}})
+		gock.New("https://api.github.com").
+			Get("/repos/test/a/stargazers").
+			Reply(200).
+			JSON([]Stargazer{{StarredAt: now.Add(-time.Hour)}})
+		gock.New("https://api.github.com").
+			Get("/repos/test/b/stargazers").
+			Reply(200).
+			JSON([]Stargazer{{StarredAt: now.Add(-2 * time.Hour)}, {StarredAt: now}})
+		stars, err := gt.AggregateStargazers(context.TODO(), []Repository{
+			{FullName: "test/b", StargazersCount: 2},
+			{FullName: "test/a", StargazersCount: 1},
+		})
+		is.NoErr(err) // should not have errored
+		is.Equal(3, len(stars))
+		is.True(stars[0].StarredAt.Before(stars[1].StarredAt)) // should be sorted
+		is.True(stars[1].StarredAt.Before(stars[2].StarredAt)) // should be sorted
+	})
+
+	t.Run("too many stars", func(t *testing.T) {
+		is := is.New(t)
+		_, err := gt.AggregateStargazers(context.TODO(), []Repository{
+			{FullName: "test/c", StargazersCount: 300 * gt.pageSize},
+			{FullName: "test/d", StargazersCount: 300 * gt.pageSize},
+		})
+		is.True(errors.Is(err, ErrTooManyStars)) // should respect the page limit
+	})
+}
+
+// inFlightTransport tracks the most stargazers requests in flight at once.
+type inFlightTransport struct {
+	next     http.RoundTripper
+	lock     sync.Mutex
+	inFlight int
+	max      int
+}
+
+func (t *inFlightTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !strings.HasSuffix(req.URL.Path, "/stargazers") {
+		return t.next.RoundTrip(req)
+	}
+	t.lock.Lock()
+	t.inFlight++
+	if t.inFlight > t.max {
+		t.max = t.inFlight
+	}
+	t.lock.Unlock()
+	defer func() {
+		t.lock.Lock()
+		t.inFlight--
+		t.lock.Unlock()
+	}()
+	return t.next.RoundTrip(req)
+}
+
+func TestAggregateStargazers_RepoConcurrency(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("https://api.github.com").
+		Get("/rate_limit").
+		Persist().
+		Reply(200).
+		JSON(rateLimit{rate{Limit: 5000, Remaining: 4000}})
+
+	var repos []Repository
+	for i := 0; i < 6; i++ {
+		name := fmt.Sprintf("test/repo%d", i)
+		repos = append(repos, Repository{
+			FullName:        name,
+			CreatedAt:       "2008-02-28T20:40:04Z",
+			StargazersCount: 1,
+		})
+		gock.New("https://api.github.com").
+			Get("/repos/" + name + "/stargazers").
+			Reply(200).
+			Delay(20 * time.Millisecond).
+			JSON([]Stargazer{{StarredAt: time.Now().Add(-time.Hour)}})
+	}
+
+	transport := &inFlightTransport{next: http.DefaultTransport}
+	http.DefaultClient.Transport = transport
+	defer func() { http.DefaultClient.Transport = nil }()
+
+	mr, _ := miniredis.Run()
+	rc := redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	})
+
+	config := testConfig()
+	config.GitHubRepoConcurrency = 2
+	cache := cache.New(rc)
+	defer cache.Close()
+	gt := New(config, cache)
+
+	is := is.New(t)
+	stars, err := gt.AggregateStargazers(context.Background(), repos)
+	is.NoErr(err)
+	is.Equal(len(stars), 6)
+	is.Equal(transport.max, 2) // should fetch only 2 repositories at once
+}
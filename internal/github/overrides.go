@@ -0,0 +1,70 @@
+package github
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/caarlos0/starcharts/internal/overrides"
+	"github.com/caarlos0/starcharts/internal/roundrobin"
+)
+
+// Overrides returns the settings of specific repos, e.g. to allow or deny
+// charting them.
+func (gh *GitHub) Overrides() *overrides.Overrides {
+	return gh.overrides
+}
+
+// maxPagesFor returns the most pages of stargazers fetched for the given
+// repo. Overrides can only lower it, as github doesn't list more.
+func (gh *GitHub) maxPagesFor(repo Repository) int {
+	if pages := gh.overrides.For(repo.FullName).MaxPages; pages > 0 && pages < maxPages {
+		return pages
+	}
+	return maxPages
+}
+
+// repoTTLFor returns how long the details of the given repo are cached.
+func (gh *GitHub) repoTTLFor(name string) time.Duration {
+	if ttl := gh.overrides.For(name).RepoTTL; ttl > 0 {
+		return ttl
+	}
+	return gh.repoTTL
+}
+
+type pinnedTokenKey struct{}
+
+// pinToken makes the requests for the given repo done with the returned
+// context use its pinned token, if any.
+func (gh *GitHub) pinToken(ctx context.Context, name string) context.Context {
+	if token := gh.overrides.For(name).Token; token != "" {
+		return context.WithValue(ctx, pinnedTokenKey{}, token)
+	}
+	return ctx
+}
+
+// pinnedToken returns the token pinned for the request, if any and usable.
+// Unlike hinted tokens, pinned ones go through the same checks as the round
+// robin ones, which are used instead if it fails them.
+func (gh *GitHub) pinnedToken(req *http.Request) *roundrobin.Token {
+	pin, _ := req.Context().Value(pinnedTokenKey{}).(string)
+	if pin == "" {
+		return nil
+	}
+	log := log.WithField("token", pin)
+	token, err := gh.hintedToken(pin)
+	if err != nil {
+		log.WithError(err).Warn("pinned token not found, using the other tokens")
+		return nil
+	}
+	if !token.OK() {
+		log.Warn("pinned token is invalid, using the other tokens")
+		return nil
+	}
+	if err := gh.checkToken(token); err != nil {
+		log.WithError(err).Warn("pinned token is unusable, using the other tokens")
+		return nil
+	}
+	return token
+}
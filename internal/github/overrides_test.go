@@ -0,0 +1,176 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis"
+	"github.com/caarlos0/starcharts/internal/cache"
+	"github.com/caarlos0/starcharts/internal/overrides"
+	"github.com/go-redis/redis"
+	"github.com/matryer/is"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestOverrides(t *testing.T) {
+	repoOverrides, err := overrides.Parse(strings.NewReader(`[
+		{"repo": "myorg/*", "max_pages": 10, "repo_ttl": "1h"},
+		{"repo": "myorg/huge", "max_pages": 50, "token": "bbb"},
+		{"repo": "myorg/unlisted", "max_pages": 1000}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	setup := func(t *testing.T, handler http.HandlerFunc) (*GitHub, *miniredis.Miniredis) {
+		t.Helper()
+		mr, err := miniredis.Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(mr.Close)
+		cache := cache.New(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+		t.Cleanup(func() { _ = cache.Close() })
+		config := testConfig()
+		config.GitHubTokens = []string{"token-aaa", "token-bbb"}
+		config.GitHubRepoTTL = time.Minute
+		gt := New(config, cache)
+		gt.overrides = repoOverrides
+		gt.client = handlerDoer(handler)
+		return gt, mr
+	}
+
+	t.Run("max pages", func(t *testing.T) {
+		is := is.New(t)
+		gt, _ := setup(t, nil)
+		is.Equal(gt.maxPagesFor(Repository{FullName: "caarlos0/starcharts"}), maxPages) // should use the default
+		is.Equal(gt.maxPagesFor(Repository{FullName: "myorg/small"}), 10)               // should use the org override
+		is.Equal(gt.maxPagesFor(Repository{FullName: "myorg/huge"}), 50)                // should use the repo override
+		is.Equal(gt.maxPagesFor(Repository{FullName: "myorg/unlisted"}), maxPages)      // should not go past what github lists
+	})
+
+	t.Run("too many stars for the override", func(t *testing.T) {
+		is := is.New(t)
+		gt, _ := setup(t, nil)
+		repo := Repository{FullName: "myorg/small", StargazersCount: 11 * gt.pageSize}
+		_, err := gt.Stargazers(context.Background(), repo)
+		is.True(err != nil) // should be over the overridden page limit
+	})
+
+	t.Run("pinned token and ttl", func(t *testing.T) {
+		for name, tt := range map[string]struct {
+			repo  string
+			token string
+			ttl   time.Duration
+		}{
+			"default":  {repo: "caarlos0/starcharts", ttl: time.Minute},
+			"org wide": {repo: "myorg/small", ttl: time.Hour},
+			"pinned":   {repo: "myorg/huge", token: "token token-bbb", ttl: time.Hour},
+		} {
+			t.Run(name, func(t *testing.T) {
+				is := is.New(t)
+				gt, mr := setup(t, func(w http.ResponseWriter, r *http.Request) {
+					if tt.token != "" {
+						is.Equal(r.Header.Get("Authorization"), tt.token) // should use the pinned token
+					}
+					_ = json.NewEncoder(w).Encode(Repository{FullName: tt.repo})
+				})
+				_, err := gt.RepoDetails(context.Background(), tt.repo)
+				is.NoErr(err)
+				is.Equal(mr.TTL(tt.repo+"_details"), tt.ttl) // should cache with the overridden ttl
+			})
+		}
+	})
+}
+
+func TestPinnedToken(t *testing.T) {
+	repoOverrides, err := overrides.Parse(strings.NewReader(`[
+		{"repo": "myorg/huge", "token": "bbb"},
+		{"repo": "myorg/gone", "token": "zzz"}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	do := func(t *testing.T, repo string) (*GitHub, error) {
+		t.Helper()
+		config := testConfig()
+		config.GitHubTokens = []string{"token-aaa", "token-bbb"}
+		gt := New(config, nil)
+		gt.overrides = repoOverrides
+		gt.maxRateUsagePct = 50
+		ctx := gt.pinToken(context.Background(), repo)
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://api.github.com/repos/"+repo, nil)
+		if err != nil {
+			return gt, err
+		}
+		resp, err := gt.authorizedDo(req, 0)
+		if err != nil {
+			return gt, err
+		}
+		return gt, resp.Body.Close()
+	}
+	rateLimitFor := func(token string, status, remaining int) {
+		gock.New("https://api.github.com").
+			Get("/rate_limit").
+			MatchHeader("Authorization", "^token "+token+"$").
+			Persist().
+			Reply(status).
+			JSON(rateLimit{rate{Limit: 5000, Remaining: remaining}})
+	}
+	repoWith := func(repo, token string) {
+		gock.New("https://api.github.com").
+			Get("/repos/"+repo).
+			MatchHeader("Authorization", "^token "+token+"$").
+			Reply(200)
+	}
+
+	t.Run("pinned", func(t *testing.T) {
+		defer gock.Off()
+		is := is.New(t)
+		gock.New("https://api.github.com").
+			Get("/rate_limit").
+			MatchHeader("Authorization", "^token token-bbb$").
+			Reply(200).
+			JSON(rateLimit{rate{Limit: 5000, Remaining: 4000}})
+		repoWith("myorg/huge", "token-bbb")
+		_, err := do(t, "myorg/huge")
+		is.NoErr(err)          // should have used the pinned token
+		is.True(gock.IsDone()) // should have checked its rate limit first
+	})
+
+	t.Run("exhausted", func(t *testing.T) {
+		defer gock.Off()
+		is := is.New(t)
+		rateLimitFor("token-aaa", 200, 4000)
+		rateLimitFor("token-bbb", 200, 10)
+		repoWith("myorg/huge", "token-aaa")
+		_, err := do(t, "myorg/huge")
+		is.NoErr(err) // should have fallen back to the other token
+	})
+
+	t.Run("revoked", func(t *testing.T) {
+		defer gock.Off()
+		is := is.New(t)
+		rateLimitFor("token-aaa", 200, 4000)
+		rateLimitFor("token-bbb", 401, 0)
+		repoWith("myorg/huge", "token-aaa")
+		gt, err := do(t, "myorg/huge")
+		is.NoErr(err)                        // should have fallen back to the other token
+		is.True(!gt.tokens.Tokens()[1].OK()) // should have invalidated the pinned token
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		defer gock.Off()
+		is := is.New(t)
+		rateLimitFor("token-aaa", 200, 4000)
+		rateLimitFor("token-bbb", 200, 4000)
+		gock.New("https://api.github.com").
+			Get("/repos/myorg/gone").
+			Reply(200)
+		_, err := do(t, "myorg/gone")
+		is.NoErr(err) // should have fallen back to the round robin tokens
+	})
+}
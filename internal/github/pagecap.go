@@ -0,0 +1,39 @@
+package github
+
+import (
+	"github.com/apex/log"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var pageCapWarnings = prometheus.NewCounter(prometheus.CounterOpts{
+	Namespace: "starcharts",
+	Subsystem: "github",
+	Name:      "page_cap_warnings_total",
+	Help:      "Total number of stargazers fetches of repos close to the page limit, about to fail with too many stars",
+})
+
+func init() {
+	prometheus.MustRegister(pageCapWarnings)
+}
+
+// nearPageCap tells whether the given number of pages is within the warning
+// percentage of the page cap, without being over it.
+func (gh *GitHub) nearPageCap(pages, limit int) bool {
+	if gh.pageCapWarnPct <= 0 || pages > limit {
+		return false
+	}
+	return pages*100 >= limit*gh.pageCapWarnPct
+}
+
+// warnPageCap counts fetches of repos that will soon hit ErrTooManyStars,
+// so operators can be alerted before users are.
+func (gh *GitHub) warnPageCap(repo Repository, pages, limit int) {
+	if !gh.nearPageCap(pages, limit) {
+		return
+	}
+	pageCapWarnings.Inc()
+	log.WithField("repo", repo.FullName).
+		WithField("pages", pages).
+		WithField("cap", limit).
+		Debug("repo is close to the page cap")
+}
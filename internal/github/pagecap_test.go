@@ -0,0 +1,31 @@
+package github
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestNearPageCap(t *testing.T) {
+	for _, tt := range []struct {
+		pct      int
+		pages    int
+		limit    int
+		expected bool
+	}{
+		{pct: 90, pages: 1, limit: 400, expected: false},
+		{pct: 90, pages: 359, limit: 400, expected: false},
+		{pct: 90, pages: 360, limit: 400, expected: true},
+		{pct: 90, pages: 400, limit: 400, expected: true},
+		{pct: 90, pages: 401, limit: 400, expected: false},
+		{pct: 50, pages: 5, limit: 10, expected: true},
+		{pct: 0, pages: 400, limit: 400, expected: false},
+	} {
+		t.Run(fmt.Sprintf("%d%% %d/%d", tt.pct, tt.pages, tt.limit), func(t *testing.T) {
+			is := is.New(t)
+			gh := &GitHub{pageCapWarnPct: tt.pct}
+			is.Equal(gh.nearPageCap(tt.pages, tt.limit), tt.expected)
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package github
+
+import (
+	"context"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var refreshesSuppressed = prometheus.NewCounter(prometheus.CounterOpts{
+	Namespace: "starcharts",
+	Subsystem: "github",
+	Name:      "refreshes_suppressed_total",
+	Help:      "Total number of stargazers fetches served from cache because the repo was just refreshed",
+})
+
+func init() {
+	prometheus.MustRegister(refreshesSuppressed)
+}
+
+func refreshedKey(repo Repository) string {
+	return repo.FullName + "_refreshed"
+}
+
+// markRefreshed records that the stargazers of the given repo were just
+// fetched, up to the given page.
+func (gh *GitHub) markRefreshed(repo Repository, last int) {
+	interval := gh.refreshIntervalFor(repo)
+	if interval <= 0 || last < 1 {
+		return
+	}
+	if err := gh.cache.PutWithTTL(refreshedKey(repo), last, interval); err != nil {
+		log.WithError(err).WithField("repo", repo.FullName).Warn("failed to mark repo as refreshed")
+	}
+}
+
+// refreshIntervalFor returns how long the stargazers of the given repo are
+// served from the cache after a refresh.
+//
+// Small repos rarely get new stars, so they are refreshed less often: repos
+// with at least refreshScale stars use the refresh interval, and smaller ones
+// get it scaled up by how many times smaller they are, up to refreshMax.
+// Nothing is refreshed more often than the refresh interval, unless the repo
+// overrides it.
+func (gh *GitHub) refreshIntervalFor(repo Repository) time.Duration {
+	if interval := gh.overrides.For(repo.FullName).RefreshInterval; interval > 0 {
+		return interval
+	}
+	interval := gh.refreshInterval
+	if interval <= 0 || gh.refreshScale <= 0 || repo.StargazersCount >= gh.refreshScale {
+		return interval
+	}
+	stars := repo.StargazersCount
+	if stars < 1 {
+		stars = 1
+	}
+	scaled := interval * time.Duration(gh.refreshScale) / time.Duration(stars)
+	if gh.refreshMax > interval && scaled > gh.refreshMax {
+		return gh.refreshMax
+	}
+	return scaled
+}
+
+// recentlyRefreshedPages gets the pages from first on straight from the cache
+// if the repo was refreshed less than the refresh interval ago, so repeated
+// requests don't fetch the same repo over and over.
+// If it returns false, nothing was added to the sink.
+func (gh *GitHub) recentlyRefreshedPages(ctx context.Context, repo Repository, first int, sink starSink) bool {
+	if gh.refreshIntervalFor(repo) <= 0 {
+		return false
+	}
+	var last int
+	if err := gh.cacheGet(ctx, refreshedKey(repo), &last); err != nil {
+		return false
+	}
+	if next := gh.cachedRange(ctx, repo, first, last, sink); next <= last {
+		sink.reset()
+		return false
+	}
+	refreshesSuppressed.Inc()
+	log.WithField("repo", repo.FullName).Info("refreshed recently, serving from cache")
+	return true
+}
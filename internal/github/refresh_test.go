@@ -0,0 +1,39 @@
+package github
+
+import (
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestRefreshIntervalFor(t *testing.T) {
+	gh := &GitHub{
+		refreshInterval: time.Minute,
+		refreshScale:    1000,
+		refreshMax:      time.Hour,
+	}
+	for stars, expected := range map[int]time.Duration{
+		100000: time.Minute,
+		1000:   time.Minute,
+		500:    2 * time.Minute,
+		100:    10 * time.Minute,
+		10:     time.Hour,
+		0:      time.Hour,
+	} {
+		is := is.New(t)
+		is.Equal(expected, gh.refreshIntervalFor(Repository{StargazersCount: stars}))
+	}
+
+	t.Run("no scaling", func(t *testing.T) {
+		is := is.New(t)
+		gh := &GitHub{refreshInterval: time.Minute}
+		is.Equal(time.Minute, gh.refreshIntervalFor(Repository{StargazersCount: 1}))
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		is := is.New(t)
+		gh := &GitHub{refreshScale: 1000, refreshMax: time.Hour}
+		is.Equal(time.Duration(0), gh.refreshIntervalFor(Repository{StargazersCount: 1}))
+	})
+}
@@ -3,13 +3,19 @@ package github
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
+	"time"
 
 	"github.com/apex/log"
+	"github.com/caarlos0/starcharts/internal/cache"
 )
 
+// ErrRepoNotFound happens when the repository doesn't exist, or the tokens
+// can't see it, e.g. because it is private.
+var ErrRepoNotFound = errors.New("repository not found or not accessible")
+
 // Repository details.
 type Repository struct {
 	FullName        string `json:"full_name"`
@@ -18,28 +24,104 @@ type Repository struct {
 }
 
 // RepoDetails gets the given repository details.
+//
+// Details are cached for a short while, so rendering the same repository
+// several times in a row doesn't hit the api every time.
 func (gh *GitHub) RepoDetails(ctx context.Context, name string) (Repository, error) {
+	var repo Repository
+	if err := gh.cacheGet(ctx, name+"_details", &repo); err == nil {
+		return repo, nil
+	}
+	if err := cacheDeadlineErr(ctx, name); err != nil {
+		return repo, err
+	}
+	repo, err := gh.fetchRepoDetails(ctx, name, true)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return repo, fmt.Errorf("%w: %s", ErrTimeout, name)
+	}
+	return repo, err
+}
+
+// CachedRepoDetails gets the given repository details from the cache only,
+// returning cache.ErrNotFound if they were never fetched.
+func (gh *GitHub) CachedRepoDetails(name string) (Repository, error) {
+	var repo Repository
+	if err := gh.cache.Get(name+"_details", &repo); err == nil {
+		return repo, nil
+	}
+	err := gh.cache.Get(name, &repo)
+	return repo, err
+}
+
+// backgroundFetchTimeout bounds fetches that no request is waiting on.
+const backgroundFetchTimeout = 30 * time.Second
+
+// FetchInBackground fetches the given repository details without waiting for
+// them, so they are cached for the next request.
+// Concurrent calls for the same repository share a single fetch.
+func (gh *GitHub) FetchInBackground(name string) {
+	go func() {
+		_, err, _ := gh.background.Do(name, func() (interface{}, error) {
+			ctx, cancel := context.WithTimeout(context.Background(), backgroundFetchTimeout)
+			defer cancel()
+			return gh.RepoDetails(ctx, name)
+		})
+		if err != nil {
+			log.WithError(err).WithField("repo", name).Warn("background fetch failed")
+		}
+	}()
+}
+
+// checkRepo fails with ErrRepoNotFound if the given repository details can't
+// be fetched, if the precheck is enabled, so stargazers of repositories the
+// tokens can't see aren't paginated for nothing.
+//
+// The details are usually cached by then, so it rarely costs a request.
+// Other errors are left for the stargazers fetch to deal with.
+func (gh *GitHub) checkRepo(ctx context.Context, repo Repository) error {
+	if !gh.precheck {
+		return nil
+	}
+	_, err := gh.RepoDetails(ctx, repo.FullName)
+	if errors.Is(err, ErrRepoNotFound) {
+		return err
+	}
+	if err != nil {
+		log.WithError(err).WithField("repo", repo.FullName).Warn("failed to precheck repo")
+	}
+	return nil
+}
+
+// nolint: funlen
+func (gh *GitHub) fetchRepoDetails(ctx context.Context, name string, revalidate bool) (Repository, error) {
 	var repo Repository
 	log := log.WithField("repo", name)
+	detailsKey := name + "_details"
 
 	var etag string
 	etagKey := name + "_etag"
 
-	if err := gh.cache.Get(etagKey, &etag); err != nil {
-		log.WithError(err).Warnf("failed to get %s from cache", etagKey)
+	if revalidate {
+		if err := gh.cache.Get(etagKey, &etag); err != nil && !errors.Is(err, cache.ErrNotFound) {
+			log.WithError(err).Warnf("failed to get %s from cache", etagKey)
+		}
 	}
 	// 请求github官方接口 https://api.github.com/repos/{name}
 	resp, err := gh.makeRepoRequest(ctx, name, etag)
+	if servesCached(err) && gh.cache.Get(name, &repo) == nil {
+		log.Warn("github is unavailable, serving stale details")
+		return repo, nil
+	}
 	if err != nil {
 		return repo, err
 	}
 
 	// 解析响应体
-	bts, err := io.ReadAll(resp.Body)
+	defer resp.Body.Close()
+	bts, err := gh.readBody(resp.Body)
 	if err != nil {
 		return repo, err
 	}
-	defer resp.Body.Close()
 
 	// 用switch。。。
 	switch resp.StatusCode {
@@ -49,16 +131,24 @@ func (gh *GitHub) RepoDetails(ctx context.Context, name string) (Repository, err
 		err := gh.cache.Get(name, &repo)
 		if err != nil {
 			log.WithError(err).Warnf("failed to get %s from cache", name)
-			if err := gh.cache.Delete(etagKey); err != nil {
-				log.WithError(err).Warnf("failed to delete %s from cache", etagKey)
+			if errors.Is(err, cache.ErrNotFound) {
+				if err := gh.cache.Delete(etagKey); err != nil {
+					log.WithError(err).Warnf("failed to delete %s from cache", etagKey)
+				}
 			}
-			return gh.RepoDetails(ctx, name)
+			// the refetch needs a token slot of its own, so this one must
+			// be released first.
+			resp.Body.Close()
+			return gh.fetchRepoDetails(ctx, name, false)
 		}
+		gh.cacheDetails(log, detailsKey, repo)
 		return repo, err
 	case http.StatusForbidden:
 		rateLimits.Inc()
 		log.Warn("rate limit hit")
 		return repo, ErrRateLimit
+	case http.StatusNotFound:
+		return repo, fmt.Errorf("%w: %s", ErrRepoNotFound, name)
 	//	不是200 都是有问题的
 	case http.StatusOK:
 		if err := json.Unmarshal(bts, &repo); err != nil {
@@ -67,13 +157,9 @@ func (gh *GitHub) RepoDetails(ctx context.Context, name string) (Repository, err
 		if err := gh.cache.Put(name, repo); err != nil {
 			log.WithError(err).Warnf("failed to cache %s", name)
 		}
+		gh.cacheDetails(log, detailsKey, repo)
 
-		etag = resp.Header.Get("etag")
-		if etag != "" {
-			if err := gh.cache.Put(etagKey, etag); err != nil {
-				log.WithError(err).Warnf("failed to cache %s", etagKey)
-			}
-		}
+		gh.cacheEtag(log, etagKey, resp.Header.Get("etag"))
 
 		return repo, nil
 	default:
@@ -81,10 +167,34 @@ func (gh *GitHub) RepoDetails(ctx context.Context, name string) (Repository, err
 	}
 }
 
+func (gh *GitHub) cacheDetails(log log.Interface, key string, repo Repository) {
+	ttl := gh.repoTTLFor(repo.FullName)
+	if ttl <= 0 {
+		return
+	}
+	if err := gh.cache.PutWithTTL(key, repo, ttl); err != nil {
+		log.WithError(err).Warnf("failed to cache %s", key)
+	}
+}
+
+// cacheEtag caches the given etag, if any, for the etag ttl.
+//
+// Etags expire on their own, unlike the data they validate: an expired etag
+// just means the next request is unconditional, and a 304 whose data is gone
+// is refetched anyway.
+func (gh *GitHub) cacheEtag(log log.Interface, key, etag string) {
+	if etag == "" {
+		return
+	}
+	if err := gh.cache.PutWithTTL(key, etag, gh.etagTTL); err != nil {
+		log.WithError(err).Warnf("failed to cache %s", key)
+	}
+}
+
 // 请求github官方接口
 func (gh *GitHub) makeRepoRequest(ctx context.Context, name, etag string) (*http.Response, error) {
 	url := fmt.Sprintf("https://api.github.com/repos/%s", name)
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	req, err := http.NewRequestWithContext(gh.pinToken(ctx, name), http.MethodGet, url, nil)
 	if err != nil {
 		return nil, err
 	}
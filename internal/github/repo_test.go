@@ -2,10 +2,12 @@ package github
 
 import (
 	"context"
+	"errors"
+	"net/http"
 	"testing"
+	"time"
 
 	"github.com/alicebob/miniredis"
-	"github.com/caarlos0/starcharts/config"
 	"github.com/caarlos0/starcharts/internal/cache"
 	"github.com/caarlos0/starcharts/internal/roundrobin"
 	"github.com/go-redis/redis"
@@ -27,13 +29,15 @@ func TestRepoDetails(t *testing.T) {
 		Addr: mr.Addr(),
 	})
 
-	config := config.Get()
+	config := testConfig()
 	cache := cache.New(rc)
 	defer cache.Close()
 	gt := New(config, cache)
+	gt.repoTTL = 0
 
 	gock.New("https://api.github.com").
 		Get("/rate_limit").
+		Persist().
 		Reply(200).
 		JSON(rateLimit{rate{Limit: 5000, Remaining: 4000}})
 
@@ -81,7 +85,7 @@ func TestRepoDetails_APIfailure(t *testing.T) {
 		Addr: mr.Addr(),
 	})
 
-	config := config.Get()
+	config := testConfig()
 	cache := cache.New(rc)
 	defer cache.Close()
 	gt := New(config, cache)
@@ -89,7 +93,7 @@ func TestRepoDetails_APIfailure(t *testing.T) {
 	t.Run("set error if api return 404", func(t *testing.T) {
 		is := is.New(t)
 		_, err := gt.RepoDetails(context.TODO(), "test/test")
-		is.True(err != nil) // Expected error
+		is.True(errors.Is(err, ErrRepoNotFound)) // Expected error
 	})
 	t.Run("set error if api return 403", func(t *testing.T) {
 		is := is.New(t)
@@ -122,7 +126,7 @@ func TestRepoDetails_WithAuthToken(t *testing.T) {
 		Addr: mr.Addr(),
 	})
 
-	config := config.Get()
+	config := testConfig()
 	cache := cache.New(rc)
 	defer cache.Close()
 	gt := New(config, cache)
@@ -134,3 +138,154 @@ func TestRepoDetails_WithAuthToken(t *testing.T) {
 		is.NoErr(err) // should not fail to get from api with auth token
 	})
 }
+
+func TestRepoDetails_CachedDetails(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("https://api.github.com").
+		Get("/rate_limit").
+		Reply(200).
+		JSON(rateLimit{rate{Limit: 5000, Remaining: 4000}})
+
+	repo := Repository{
+		FullName:        "test/test",
+		CreatedAt:       "2008-02-28T20:40:04Z",
+		StargazersCount: 3811,
+	}
+
+	gock.New("https://api.github.com").
+		Get("/repos/test/test").
+		Times(1).
+		Reply(200).
+		JSON(repo)
+
+	mr, _ := miniredis.Run()
+	rc := redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	})
+
+	config := testConfig()
+	cache := cache.New(rc)
+	defer cache.Close()
+	gt := New(config, cache)
+	gt.repoTTL = time.Minute
+
+	is := is.New(t)
+	first, err := gt.RepoDetails(context.TODO(), "test/test")
+	is.NoErr(err) // should not fail to get from api
+	second, err := gt.RepoDetails(context.TODO(), "test/test")
+	is.NoErr(err)           // should not fail to get from cache
+	is.Equal(first, second) // should return the same details
+	is.True(gock.IsDone())  // should have hit the api only once
+
+	mr.FastForward(2 * time.Minute)
+	repo.StargazersCount = 3812
+	gock.New("https://api.github.com").
+		Get("/rate_limit").
+		Reply(200).
+		JSON(rateLimit{rate{Limit: 5000, Remaining: 4000}})
+	gock.New("https://api.github.com").
+		Get("/repos/test/test").
+		Reply(200).
+		JSON(repo)
+	third, err := gt.RepoDetails(context.TODO(), "test/test")
+	is.NoErr(err)
+	is.Equal(3812, third.StargazersCount) // should get the new details
+	is.True(gock.IsDone())                // should hit the api again after the ttl expires
+}
+
+func TestStargazers_PrivateRepoNoAccess(t *testing.T) {
+	// e.g. listed by an org, or cached from when the tokens could see it.
+	repo := Repository{FullName: "private/private", StargazersCount: 500}
+
+	setup := func(t *testing.T, precheck bool) (*GitHub, *int) {
+		t.Helper()
+		mr, err := miniredis.Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(mr.Close)
+		cache := cache.New(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+		t.Cleanup(func() { _ = cache.Close() })
+		config := testConfig()
+		config.GitHubRepoPrecheck = precheck
+		gt := New(config, cache)
+		var pages int
+		gt.client = handlerDoer(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/repos/private/private/stargazers" {
+				pages++
+			}
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"message":"Not Found"}`))
+		})
+		return gt, &pages
+	}
+
+	t.Run("precheck", func(t *testing.T) {
+		is := is.New(t)
+		gt, pages := setup(t, true)
+		_, err := gt.Stargazers(context.Background(), repo)
+		is.True(errors.Is(err, ErrRepoNotFound)) // should tell the repo can't be seen
+		_, err = gt.RecentStargazers(context.Background(), repo, 10)
+		is.True(errors.Is(err, ErrRepoNotFound)) // should tell the repo can't be seen
+		is.Equal(0, *pages)                      // should not fetch any page
+	})
+
+	t.Run("no precheck", func(t *testing.T) {
+		is := is.New(t)
+		gt, pages := setup(t, false)
+		_, err := gt.Stargazers(context.Background(), repo)
+		is.True(errors.Is(err, ErrGitHubAPI))
+		is.True(*pages > 0) // should have fetched the pages
+	})
+}
+
+func TestReadOnly(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mr.Close()
+	cache := cache.New(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	defer cache.Close()
+	config := testConfig()
+	config.ReadOnly = true
+	config.GitHubPageSize = 2
+	gt := New(config, cache)
+	gt.client = handlerDoer(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("should not call github, called %s", r.URL)
+	})
+
+	// as cached by the instance that fetches from github.
+	repo := Repository{FullName: "test/test", StargazersCount: 4}
+	stars := []Stargazer{
+		{StarredAt: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{StarredAt: time.Date(2022, 1, 2, 0, 0, 0, 0, time.UTC)},
+	}
+	if err := cache.Put(repo.FullName, repo); err != nil {
+		t.Fatal(err)
+	}
+	for page := 1; page <= 2; page++ {
+		if err := cache.Put(pageKey(repo.FullName, page), stars); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("cached", func(t *testing.T) {
+		is := is.New(t)
+		details, err := gt.RepoDetails(context.Background(), repo.FullName)
+		is.NoErr(err)
+		is.Equal(repo, details)
+		result, err := gt.Stargazers(context.Background(), details)
+		is.NoErr(err)
+		is.Equal(4, len(result)) // should not need the empty page past the last one
+	})
+
+	t.Run("not cached", func(t *testing.T) {
+		is := is.New(t)
+		_, err := gt.RepoDetails(context.Background(), "test/other")
+		is.True(errors.Is(err, ErrNotYetAvailable))
+		_, err = gt.Stargazers(context.Background(), Repository{FullName: "test/other", StargazersCount: 1})
+		is.True(errors.Is(err, ErrNotYetAvailable))
+	})
+}
@@ -0,0 +1,77 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/apex/log"
+)
+
+// ErrRetryBudgetExhausted happens when a fetch retried failed pages more
+// times than its retry budget allows.
+var ErrRetryBudgetExhausted = errors.New("too many failed requests to github, please try again later")
+
+const (
+	// maxPageTries bounds the attempts to fetch a single page.
+	maxPageTries = 3
+	// retryBackoff is how long to wait before retrying a page, multiplied by
+	// the attempt number.
+	retryBackoff = 100 * time.Millisecond
+)
+
+// retryBudget bounds the retries of all the pages of a single fetch, so a
+// flaky repo can't retry every page and burn the rate limit.
+type retryBudget struct {
+	left int64
+}
+
+// newRetryBudget creates a budget of n retries, or nil if retries are
+// disabled.
+func newRetryBudget(n int) *retryBudget {
+	if n <= 0 {
+		return nil
+	}
+	return &retryBudget{left: int64(n)}
+}
+
+// take uses a retry from the budget, returning false if there are none left.
+func (b *retryBudget) take() bool {
+	return atomic.AddInt64(&b.left, -1) >= 0
+}
+
+// isRetryable tells whether the page fetch failed for a reason that might go
+// away on its own, e.g. a github 5xx or a network error.
+func isRetryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var netErr net.Error
+	return errors.Is(err, ErrGitHubAPI) ||
+		errors.Is(err, ErrInvalidResponse) ||
+		errors.As(err, &netErr)
+}
+
+// getStargazersPageWithRetry gets a page of stargazers, retrying transient
+// failures while the fetch retry budget allows.
+func (gh *GitHub) getStargazersPageWithRetry(ctx context.Context, repo Repository, page int, budget *retryBudget) ([]Stargazer, error) {
+	for try := 1; ; try++ {
+		stars, err := gh.getStargazersPage(ctx, repo, page)
+		if err == nil || budget == nil || try >= maxPageTries || !isRetryable(err) {
+			return stars, err
+		}
+		if !budget.take() {
+			return stars, fmt.Errorf("%w: %v", ErrRetryBudgetExhausted, err)
+		}
+		pageRetries.Inc()
+		log.WithError(err).WithField("repo", repo.FullName).WithField("page", page).Warn("retrying page")
+		select {
+		case <-ctx.Done():
+			return stars, err
+		case <-time.After(retryBackoff * time.Duration(try)):
+		}
+	}
+}
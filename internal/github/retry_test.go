@@ -0,0 +1,94 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis"
+	"github.com/caarlos0/starcharts/internal/cache"
+	"github.com/go-redis/redis"
+	"github.com/matryer/is"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestStargazers_Retry(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("https://api.github.com").
+		Get("/rate_limit").
+		Persist().
+		Reply(200).
+		JSON(rateLimit{rate{Limit: 5000, Remaining: 4000}})
+
+	repo := Repository{
+		FullName:        "test/test",
+		CreatedAt:       "2008-02-28T20:40:04Z",
+		StargazersCount: 2,
+	}
+
+	mr, _ := miniredis.Run()
+	rc := redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	})
+	cache := cache.New(rc)
+	defer cache.Close()
+	gt := New(testConfig(), cache)
+	gt.refreshInterval = 0
+
+	t.Run("transient failure", func(t *testing.T) {
+		is := is.New(t)
+		gt.retryBudget = 1
+		gock.New("https://api.github.com").
+			Get("/repos/test/test/stargazers").
+			Reply(502)
+		gock.New("https://api.github.com").
+			Get("/repos/test/test/stargazers").
+			Reply(200).
+			JSON([]Stargazer{{StarredAt: time.Now()}, {StarredAt: time.Now()}})
+		stars, err := gt.Stargazers(context.TODO(), repo)
+		is.NoErr(err) // should have retried
+		is.Equal(2, len(stars))
+	})
+
+	t.Run("budget exhausted", func(t *testing.T) {
+		is := is.New(t)
+		mr.FlushAll()
+		gt.retryBudget = 1
+		gock.New("https://api.github.com").
+			Get("/repos/test/test/stargazers").
+			Times(2).
+			Reply(502)
+		_, err := gt.Stargazers(context.TODO(), repo)
+		is.True(errors.Is(err, ErrRetryBudgetExhausted)) // should give up
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		is := is.New(t)
+		mr.FlushAll()
+		gt.retryBudget = 0
+		gock.New("https://api.github.com").
+			Get("/repos/test/test/stargazers").
+			Reply(502)
+		_, err := gt.Stargazers(context.TODO(), repo)
+		is.True(errors.Is(err, ErrGitHubAPI)) // should not retry
+	})
+}
+
+func TestRetryBudget(t *testing.T) {
+	is := is.New(t)
+	is.True(newRetryBudget(0) == nil) // should disable retries
+	budget := newRetryBudget(2)
+	is.True(budget.take())
+	is.True(budget.take())
+	is.True(!budget.take())
+}
+
+func TestIsRetryable(t *testing.T) {
+	is := is.New(t)
+	is.True(isRetryable(ErrGitHubAPI))
+	is.True(isRetryable(ErrInvalidResponse))
+	is.True(!isRetryable(ErrRateLimit))
+	is.True(!isRetryable(context.DeadlineExceeded))
+}
@@ -0,0 +1,124 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// Sampling tells whether repos with too many stargazers to list are sampled
+// with StargazersSample instead of only failing with ErrTooManyStars.
+func (gh *GitHub) Sampling() bool {
+	return gh.samplePages > 0
+}
+
+// ListedStars returns how many of the stars of the given repo github lists,
+// oldest first, the others being past the pages it allows listing.
+func (gh *GitHub) ListedStars(repo Repository) int {
+	listed := gh.maxPagesFor(repo) * gh.pageSize
+	if repo.StargazersCount < listed {
+		return repo.StargazersCount
+	}
+	return listed
+}
+
+// sampledPages returns n pages evenly spread over [1, last], both included,
+// or all of them if there are no more than n.
+func sampledPages(last, n int) []int {
+	if n >= last {
+		n = last
+	}
+	if n < 2 {
+		return []int{1}
+	}
+	pages := make([]int, 0, n)
+	for i := 0; i < n; i++ {
+		page := 1 + (i*(last-1)+(n-1)/2)/(n-1)
+		if len(pages) == 0 || pages[len(pages)-1] != page {
+			pages = append(pages, page)
+		}
+	}
+	return pages
+}
+
+// StargazersSample approximates the cumulative star count over time of a
+// repo with too many stargazers to list, from a strided sample of the pages
+// github allows listing.
+//
+// Pages are in starring order and all but the last are full, so the stars
+// before each sampled page are counted without fetching them. The first and
+// last star of each page are exact points of the curve, which ends at the
+// current star count. Charting straight lines between them interpolates the
+// rest.
+//
+// Github only lists stargazers oldest first, and not past its max pages, so
+// only the first ListedStars stars are sampled: the curve goes straight from
+// the last of them to the current star count.
+func (gh *GitHub) StargazersSample(ctx context.Context, repo Repository) ([]HistogramPoint, error) {
+	if err := gh.checkMaxStars(repo); err != nil {
+		return nil, err
+	}
+	if err := gh.checkRepo(ctx, repo); err != nil {
+		return nil, err
+	}
+	last := gh.totalPages(repo)
+	if max := gh.maxPagesFor(repo); last > max {
+		last = max
+	}
+	release, err := gh.acquireFetch()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	if gh.fetchTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, gh.fetchTimeout)
+		defer cancel()
+	}
+
+	var lock sync.Mutex
+	var points []HistogramPoint
+	budget := newRetryBudget(gh.retryBudget)
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(4)
+	for _, page := range sampledPages(last, gh.samplePages) {
+		page := page
+		g.Go(func() error {
+			stars, err := gh.getStargazersPageWithRetry(gctx, repo, page, budget)
+			if err != nil {
+				return err
+			}
+			stars, dropped := dropInvalidStars(stars, gh.now())
+			logInvalidStars(repo, dropped)
+			if len(stars) == 0 {
+				return nil
+			}
+			sortStargazers(stars)
+			before := (page - 1) * gh.pageSize
+			lock.Lock()
+			defer lock.Unlock()
+			points = append(points,
+				HistogramPoint{Time: stars[0].StarredAt.UTC(), Total: before + 1},
+				HistogramPoint{Time: stars[len(stars)-1].StarredAt.UTC(), Total: before + len(stars)})
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("%w: %s", ErrTimeout, repo.FullName)
+		}
+		return nil, err
+	}
+
+	sort.Slice(points, func(i, j int) bool {
+		return points[i].Total < points[j].Total
+	})
+	if len(points) == 0 || points[len(points)-1].Total < repo.StargazersCount {
+		points = append(points, HistogramPoint{Time: gh.now().UTC(), Total: repo.StargazersCount})
+	}
+	return points, nil
+}
@@ -0,0 +1,126 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis"
+	"github.com/caarlos0/starcharts/internal/cache"
+	"github.com/go-redis/redis"
+	"github.com/matryer/is"
+)
+
+func TestSampledPages(t *testing.T) {
+	for name, tt := range map[string]struct {
+		last, n  int
+		expected []int
+	}{
+		"strided":      {400, 5, []int{1, 101, 201, 300, 400}},
+		"ends":         {400, 2, []int{1, 400}},
+		"all":          {3, 10, []int{1, 2, 3}},
+		"single page":  {1, 5, []int{1}},
+		"single":       {10, 1, []int{1}},
+		"every page":   {4, 4, []int{1, 2, 3, 4}},
+		"close to all": {5, 4, []int{1, 2, 4, 5}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			is := is.New(t)
+			is.Equal(tt.expected, sampledPages(tt.last, tt.n))
+		})
+	}
+}
+
+func TestStargazersSample(t *testing.T) {
+	is := is.New(t)
+	mr, _ := miniredis.Run()
+	defer mr.Close()
+	cache := cache.New(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	defer cache.Close()
+
+	config := testConfig()
+	config.GitHubSamplePages = 5
+	gt := New(config, cache)
+	is.True(gt.Sampling())
+	now := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	gt.now = func() time.Time { return now }
+
+	// the k-th star was starred k-1 hours after the first one.
+	first := time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC)
+	starredAt := func(k int) time.Time {
+		return first.Add(time.Duration(k-1) * time.Hour)
+	}
+	repo := Repository{FullName: "test/test", StargazersCount: 45000}
+	var lock sync.Mutex
+	var requested []int
+	gt.client = handlerDoer(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		lock.Lock()
+		requested = append(requested, page)
+		lock.Unlock()
+		stars := make([]Stargazer, 0, 100)
+		for i := 1; i <= 100; i++ {
+			stars = append(stars, Stargazer{StarredAt: starredAt((page-1)*100 + i)})
+		}
+		_ = json.NewEncoder(w).Encode(stars)
+	})
+
+	_, err := gt.Stargazers(context.Background(), repo)
+	is.True(errors.Is(err, ErrTooManyStars)) // should not list all the stars
+
+	points, err := gt.StargazersSample(context.Background(), repo)
+	is.NoErr(err)
+	is.Equal(len(requested), 5) // should only fetch the sampled pages
+
+	is.Equal(len(points), 11)
+	is.Equal(points[0], HistogramPoint{Time: first, Total: 1})                            // should start at the first star
+	is.Equal(points[len(points)-1], HistogramPoint{Time: now, Total: 45000})              // should end at the star count
+	is.Equal(points[len(points)-2], HistogramPoint{Time: starredAt(40000), Total: 40000}) // should reach the last listable star
+	is.True(contains(points, HistogramPoint{Time: starredAt(20001), Total: 20001}))       // should start the middle page on the true curve
+	is.True(contains(points, HistogramPoint{Time: starredAt(20100), Total: 20100}))       // should end the middle page on the true curve
+	for _, p := range points[:len(points)-1] {
+		is.Equal(p.Time, starredAt(p.Total)) // should be on the true curve
+	}
+	is.Equal(gt.ListedStars(repo), 40000) // should only sample the stars github lists
+	for i := 1; i < len(points); i++ {
+		is.True(points[i].Total >= points[i-1].Total) // should grow
+		is.True(!points[i].Time.Before(points[i-1].Time))
+	}
+}
+
+func TestStargazersSampleSmallRepo(t *testing.T) {
+	is := is.New(t)
+	mr, _ := miniredis.Run()
+	defer mr.Close()
+	cache := cache.New(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	defer cache.Close()
+
+	config := testConfig()
+	config.GitHubSamplePages = 10
+	gt := New(config, cache)
+	starred := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	gt.client = handlerDoer(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode([]Stargazer{{StarredAt: starred}, {StarredAt: starred.Add(time.Hour)}})
+	})
+
+	points, err := gt.StargazersSample(context.Background(), Repository{FullName: "test/test", StargazersCount: 2})
+	is.NoErr(err)
+	is.Equal(points, []HistogramPoint{
+		{Time: starred, Total: 1},
+		{Time: starred.Add(time.Hour), Total: 2},
+	}) // should not add a point past the star count
+}
+
+func contains(points []HistogramPoint, point HistogramPoint) bool {
+	for _, p := range points {
+		if p == point {
+			return true
+		}
+	}
+	return false
+}
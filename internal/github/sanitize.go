@@ -0,0 +1,35 @@
+package github
+
+import (
+	"time"
+
+	"github.com/apex/log"
+)
+
+// maxClockSkew is how far in the future a star can be before it is
+// considered bogus.
+const maxClockSkew = time.Hour
+
+// validStarredAt tells whether the given starred at time makes sense: not
+// zero and not in the future, give or take some clock skew.
+func validStarredAt(t, now time.Time) bool {
+	return !t.IsZero() && !t.After(now.Add(maxClockSkew))
+}
+
+// dropInvalidStars drops the stars with a zero or future starred at time, as
+// they would blow up the chart time axis, returning how many were dropped.
+func dropInvalidStars(stars []Stargazer, now time.Time) ([]Stargazer, int) {
+	result := stars[:0]
+	for _, star := range stars {
+		if validStarredAt(star.StarredAt, now) {
+			result = append(result, star)
+		}
+	}
+	return result, len(stars) - len(result)
+}
+
+func logInvalidStars(repo Repository, dropped int) {
+	if dropped > 0 {
+		log.WithField("repo", repo.FullName).Warnf("dropped %d stargazers with an invalid starred_at", dropped)
+	}
+}
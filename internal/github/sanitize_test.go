@@ -0,0 +1,72 @@
+package github
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis"
+	"github.com/caarlos0/starcharts/internal/cache"
+	"github.com/go-redis/redis"
+	"github.com/matryer/is"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestDropInvalidStars(t *testing.T) {
+	is := is.New(t)
+	now := time.Now()
+	stars, dropped := dropInvalidStars([]Stargazer{
+		{StarredAt: now.Add(-time.Hour)},
+		{},
+		{StarredAt: now.Add(24 * 365 * time.Hour)},
+		{StarredAt: now.Add(time.Minute)},
+	}, now)
+	is.Equal(2, dropped)
+	is.Equal([]Stargazer{
+		{StarredAt: now.Add(-time.Hour)},
+		{StarredAt: now.Add(time.Minute)},
+	}, stars)
+}
+
+func TestStarHistogramInvalidStars(t *testing.T) {
+	is := is.New(t)
+	hist := NewStarHistogram(24 * time.Hour)
+	hist.add([]Stargazer{
+		{StarredAt: time.Now().Add(-time.Hour)},
+		{},
+		{StarredAt: time.Now().Add(24 * 365 * time.Hour)},
+	})
+	is.Equal(2, hist.invalid)
+	is.Equal(1, len(hist.Points()))
+}
+
+func TestStargazers_Clock(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("https://api.github.com").
+		Get("/rate_limit").
+		Reply(200).
+		JSON(rateLimit{rate{Limit: 5000, Remaining: 4000}})
+	starredAt := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	gock.New("https://api.github.com").
+		Get("/repos/test/test/stargazers").
+		Reply(200).
+		JSON([]Stargazer{{StarredAt: starredAt}, {StarredAt: starredAt.Add(24 * time.Hour)}})
+
+	mr, _ := miniredis.Run()
+	rc := redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	})
+	cache := cache.New(rc)
+	defer cache.Close()
+	gt := New(testConfig(), cache)
+	gt.now = func() time.Time { return starredAt }
+
+	is := is.New(t)
+	stars, err := gt.Stargazers(context.TODO(), Repository{
+		FullName:        "test/test",
+		StargazersCount: 2,
+	})
+	is.NoErr(err)
+	is.Equal(1, len(stars)) // should drop the star in the future of the clock
+}
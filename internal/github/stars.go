@@ -1,70 +1,404 @@
 package github
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"net/http"
 	"sort"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/apex/log"
+	"github.com/caarlos0/starcharts/internal/cache"
 	"golang.org/x/sync/errgroup"
 )
 
 var (
 	errNoMorePages  = errors.New("no more pages to get")
 	ErrTooManyStars = errors.New("repo has too many stargazers, github won't allow us to list all stars")
+	// ErrInvalidResponse happens when github responds with something we
+	// can't make sense of.
+	ErrInvalidResponse = errors.New("invalid response from github api")
+	// ErrAboveMaxStars happens when a repo has more stars than this instance
+	// is configured to fetch.
+	ErrAboveMaxStars = errors.New("repo has more stargazers than this instance allows")
+	// ErrRecentTooFar happens when the most recent stargazers of a repo are
+	// past the last page github allows listing.
+	ErrRecentTooFar = errors.New("repo has too many stargazers, github won't allow us to list the most recent ones")
+	// ErrRecentListable comes along with ErrAboveMaxStars when the most
+	// recent stargazers of the repo can still be listed with
+	// RecentStargazers.
+	ErrRecentListable = errors.New("the most recent stargazers can still be listed")
 )
 
+// maxPages is the most pages of stargazers fetched for a single chart.
+const maxPages = 400
+
+// starsSchemaVersion is the version of the cached stargazers pages, part of
+// their cache keys.
+// Bump it whenever the cached shape of Stargazer changes, so pages cached
+// with the old shape are fetched again instead of being served partially.
+const starsSchemaVersion = 2
+
+// pagePrefix is the prefix of the cache keys of the stargazers pages of a
+// repository.
+func pagePrefix(name string) string {
+	return fmt.Sprintf("%s@v%d_", name, starsSchemaVersion)
+}
+
+// pageKey is the cache key of the given page of stargazers of a repository.
+func pageKey(name string, page int) string {
+	return pagePrefix(name) + strconv.Itoa(page)
+}
+
+// pageEtagKey is the cache key of the etag of the given page of stargazers
+// of a repository.
+func pageEtagKey(name string, page int) string {
+	return pageKey(name, page) + "_etag"
+}
+
 // Stargazer is a star at a given time.
 // 记录的每个star的时间
 type Stargazer struct {
 	StarredAt time.Time `json:"starred_at"`
+	// User is who starred, if github sent it.
+	//
+	// Pages cached before users were captured don't have it, so it is
+	// optional.
+	User *User `json:"user,omitempty" msgpack:",omitempty"`
+}
+
+// User is a github user.
+type User struct {
+	Login string `json:"login"`
+	ID    int64  `json:"id"`
 }
 
 // Stargazers returns all the stargazers of a given repo.
+//
+// The whole fetch is bound by the client's fetch timeout, in which case
+// ErrTimeout is returned.
 func (gh *GitHub) Stargazers(ctx context.Context, repo Repository) (stars []Stargazer, err error) {
-	sem := make(chan bool, 4)
-
-	if gh.totalPages(repo) > 400 {
+	if err := gh.checkMaxStars(repo); err != nil {
+		return stars, err
+	}
+	if err := gh.checkRepo(ctx, repo); err != nil {
+		return stars, err
+	}
+	pages, limit := gh.totalPages(repo), gh.maxPagesFor(repo)
+	if pages > limit {
 		// 做了限制，star的总页数超过400就不展示了？
 		// 是不是可以继续做？
 		return stars, ErrTooManyStars
 	}
+	gh.warnPageCap(repo, pages, limit)
+	return gh.pages(ctx, repo, 1, gh.lastPage(repo))
+}
+
+// checkMaxStars fails with ErrAboveMaxStars if the repo has more stars than
+// the configured max, if any, along with ErrRecentListable if its most recent
+// stars can still be listed.
+func (gh *GitHub) checkMaxStars(repo Repository) error {
+	if gh.maxStars <= 0 || repo.StargazersCount <= gh.maxStars {
+		return nil
+	}
+	if gh.totalPages(repo) > gh.maxPagesFor(repo) {
+		return fmt.Errorf("%w: %d > %d", ErrAboveMaxStars, repo.StargazersCount, gh.maxStars)
+	}
+	return fmt.Errorf("%w: %d > %d: %w", ErrAboveMaxStars, repo.StargazersCount, gh.maxStars, ErrRecentListable)
+}
+
+// RecentStargazers returns the last n stargazers of a given repo.
+//
+// Only the last pages are fetched, but they must be within the pages github
+// allows listing, failing with ErrRecentTooFar otherwise, so it doesn't work
+// for repos whose full history would hit ErrTooManyStars.
+func (gh *GitHub) RecentStargazers(ctx context.Context, repo Repository, n int) ([]Stargazer, error) {
+	limit := gh.maxPagesFor(repo)
+	if gh.totalPages(repo) > limit {
+		return nil, fmt.Errorf("%w: %s", ErrRecentTooFar, repo.FullName)
+	}
+	if err := gh.checkRepo(ctx, repo); err != nil {
+		return nil, err
+	}
+	last := gh.lastPage(repo)
+	if last > limit {
+		// the page after a full last one only catches new stars.
+		last = limit
+	}
+	first := last - (n+gh.pageSize-1)/gh.pageSize
+	if first < 1 {
+		first = 1
+	}
+	stars, err := gh.pages(ctx, repo, first, last)
+	if errors.Is(err, ErrTooManyStars) {
+		// the star count was off, and the latest stars are past the max.
+		return nil, fmt.Errorf("%w: %s", ErrRecentTooFar, repo.FullName)
+	}
+	if len(stars) > n {
+		stars = stars[len(stars)-n:]
+	}
+	return stars, err
+}
+
+// pages fetches the stargazers of the pages in [first, last], sorted by the
+// time they were starred.
+// Stars with an invalid starred at time are dropped.
+func (gh *GitHub) pages(ctx context.Context, repo Repository, first, last int) ([]Stargazer, error) {
+	var stars starList
+	err := gh.collectPages(ctx, repo, first, last, &stars)
+	stars, dropped := dropInvalidStars(stars, gh.now())
+	logInvalidStars(repo, dropped)
+	sortStargazers(stars)
+	if gh.dedupe {
+		return dedupeStargazers(stars), err
+	}
+	return stars, err
+}
+
+// dedupeStargazers drops the stars of users who already starred earlier, as
+// happens if a page is collected twice.
+// Stars without a user are kept, as the same starred at time alone doesn't
+// mean it is the same star.
+// The stargazers must be sorted.
+func dedupeStargazers(stars []Stargazer) []Stargazer {
+	seen := map[string]bool{}
+	result := stars[:0]
+	for _, star := range stars {
+		if star.User != nil && star.User.Login != "" {
+			if seen[star.User.Login] {
+				continue
+			}
+			seen[star.User.Login] = true
+		}
+		result = append(result, star)
+	}
+	return result
+}
+
+// collectPages fetches the pages in [first, last], handing the stargazers of
+// each page to the sink as they arrive, in no particular order.
+//
+// The last page comes from the repository star count, which github sometimes
+// under-reports, so pages after it are fetched as well while the last one is
+// full, failing with ErrTooManyStars past the max pages github lists.
+//
+// Fetches of pages that were never cached count against the in-flight limit,
+// failing with ErrOverloaded when it is reached.
+// Failed pages are retried while the fetch retry budget allows, failing with
+// ErrRetryBudgetExhausted once it runs out.
+func (gh *GitHub) collectPages(ctx context.Context, repo Repository, first, last int, sink starSink) (err error) {
+	if gh.recentlyRefreshedPages(ctx, repo, first, sink) {
+		return nil
+	}
+	if !gh.isCached(ctx, repo, first) {
+		release, err := gh.acquireFetch()
+		if err != nil {
+			return err
+		}
+		defer release()
+		defer gh.cold.start(repo.FullName)()
+	}
+
+	next := gh.cachedPages(ctx, repo, first, last, sink)
+	if err := cacheDeadlineErr(ctx, repo.FullName); err != nil {
+		return err
+	}
+	checkpoint := newCheckpoint(next - 1)
+	// the checkpoint can only move if all pages before next were fetched.
+	track := first == 1 || next > first
+
+	sem := make(chan bool, 4)
+
+	if gh.fetchTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, gh.fetchTimeout)
+		defer cancel()
+	}
 
-	var g errgroup.Group
+	budget := newRetryBudget(gh.retryBudget)
 	var lock sync.Mutex
-	for page := 1; page <= gh.lastPage(repo); page++ {
-		sem <- true
-		page := page
-		g.Go(func() error {
-			defer func() { <-sem }()
-			result, err := gh.getStargazersPage(ctx, repo, page)
-			if errors.Is(err, errNoMorePages) {
-				return nil
+	lastWithStars := next - 1
+	// pages before the checkpoint are always full.
+	lastFull := next > first
+	fetch := func(from, to int) error {
+		g, gctx := errgroup.WithContext(ctx)
+		for page := from; page <= to; page++ {
+			// stop spinning up fetches that would fail right away, and don't
+			// block on the semaphore once the fetch is cancelled.
+			if gctx.Err() != nil {
+				break
 			}
-			if err != nil {
-				return err
+			select {
+			case sem <- true:
+			case <-gctx.Done():
 			}
-			lock.Lock()
-			defer lock.Unlock()
-			//将切片 result 中的元素追加到切片 stars 的末尾。
-			//在Go语言中，append() 函数用于向切片中追加元素。
-			//它接受一个切片作为第一个参数，并将要追加的元素作为后续参数传入。在这个特殊的语法中，...
-			//表示将切片 result 拆分为单独的元素，然后将这些元素追加到 stars 切片中。
-			stars = append(stars, result...)
-			return nil
-		})
-	}
-	err = g.Wait()
+			if gctx.Err() != nil {
+				break
+			}
+			page := page
+			g.Go(func() error {
+				defer func() { <-sem }()
+				result, err := gh.getStargazersPageWithRetry(gctx, repo, page, budget)
+				if errors.Is(err, errNoMorePages) {
+					return nil
+				}
+				if err != nil {
+					return err
+				}
+				lock.Lock()
+				defer lock.Unlock()
+				sink.add(result)
+				if page > lastWithStars {
+					lastWithStars = page
+					lastFull = len(result) == gh.pageSize
+				}
+				// only full pages are final, the last one still gets new stars.
+				if track && len(result) == gh.pageSize && checkpoint.done(page) {
+					gh.saveCheckpoint(repo, checkpoint.page)
+				}
+				return nil
+			})
+		}
+		return g.Wait()
+	}
+
+	err = fetch(next, last)
+	for to := last; err == nil && lastFull && lastWithStars == to; {
+		// github doesn't list pages past the max, wherever the fetch started.
+		if to >= gh.maxPagesFor(repo) {
+			return ErrTooManyStars
+		}
+		log.WithField("repo", repo.FullName).WithField("page", to).
+			Info("last page is full, star count is off")
+		from := to + 1
+		to += cap(sem)
+		err = fetch(from, to)
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %s", ErrTimeout, repo.FullName)
+	}
+	if err == nil {
+		// the loop stops early without an error if the caller cancelled.
+		err = ctx.Err()
+	}
+	if err == nil {
+		gh.markRefreshed(repo, lastWithStars)
+	}
+	return err
+}
+
+// starSink receives stargazers as their pages are fetched.
+type starSink interface {
+	add(stars []Stargazer)
+	// reset drops everything added so far.
+	reset()
+}
+
+// starList keeps every stargazer.
+type starList []Stargazer
+
+func (l *starList) add(stars []Stargazer) { *l = append(*l, stars...) }
+func (l *starList) reset()                { *l = nil }
+
+func sortStargazers(stars []Stargazer) {
 	sort.Slice(stars, func(i, j int) bool {
 		return stars[i].StarredAt.Before(stars[j].StarredAt)
 	})
-	return
+}
+
+// checkpointTTL is how long the pages up to a fetch checkpoint are trusted
+// without revalidating them with github.
+const checkpointTTL = 24 * time.Hour
+
+func checkpointKey(repo Repository) string {
+	return repo.FullName + "_checkpoint"
+}
+
+// cachedPages gets the pages in [first, last] up to the fetch checkpoint
+// straight from the cache, so interrupted fetches resume where they left off.
+// It returns the next page to fetch.
+func (gh *GitHub) cachedPages(ctx context.Context, repo Repository, first, last int, sink starSink) int {
+	var checkpoint int
+	if err := gh.cacheGet(ctx, checkpointKey(repo), &checkpoint); err != nil {
+		return first
+	}
+	if checkpoint > last {
+		checkpoint = last
+	}
+	return gh.cachedRange(ctx, repo, first, checkpoint, sink)
+}
+
+// cachedRange gets the pages in [first, last] from the cache, stopping at the
+// first page not cached.
+// It returns the next page to fetch.
+func (gh *GitHub) cachedRange(ctx context.Context, repo Repository, first, last int, sink starSink) int {
+	for page := first; page <= last; page++ {
+		var result []Stargazer
+		if err := gh.cacheGet(ctx, pageKey(repo.FullName, page), &result); err != nil {
+			return page
+		}
+		sink.add(result)
+	}
+	return last + 1
+}
+
+func (gh *GitHub) saveCheckpoint(repo Repository, page int) {
+	if err := gh.cache.PutWithTTL(checkpointKey(repo), page, checkpointTTL); err != nil {
+		log.WithError(err).WithField("repo", repo.FullName).Warn("failed to save fetch checkpoint")
+	}
+}
+
+// checkpoint tracks the highest contiguous page fetched.
+type checkpoint struct {
+	page    int
+	fetched map[int]bool
+}
+
+func newCheckpoint(page int) *checkpoint {
+	return &checkpoint{page: page, fetched: map[int]bool{}}
+}
+
+// done marks the given page as fetched, returning whether the checkpoint
+// moved.
+func (c *checkpoint) done(page int) bool {
+	c.fetched[page] = true
+	moved := false
+	for c.fetched[c.page+1] {
+		delete(c.fetched, c.page+1)
+		c.page++
+		moved = true
+	}
+	return moved
+}
+
+// isCached tells whether the given page was already fetched, in which case
+// fetching it again is just a revalidation.
+func (gh *GitHub) isCached(ctx context.Context, repo Repository, page int) bool {
+	var etag string
+	return gh.cacheGet(ctx, pageEtagKey(repo.FullName, page), &etag) == nil
+}
+
+// acquireFetch takes a slot from the in-flight limiter, returning a function
+// to release it.
+func (gh *GitHub) acquireFetch() (func(), error) {
+	if gh.inFlight == nil {
+		return func() {}, nil
+	}
+	select {
+	case gh.inFlight <- struct{}{}:
+		inFlightFetches.Inc()
+		return func() {
+			<-gh.inFlight
+			inFlightFetches.Dec()
+		}, nil
+	default:
+		return nil, ErrOverloaded
+	}
 }
 
 // 缓存设计
@@ -79,32 +413,61 @@ func (gh *GitHub) Stargazers(ctx context.Context, repo Repository) (stars []Star
 //     - if succeeds, cache and return both the api and header
 //     - if fails, return error
 
+// getStargazersPage gets the given page of stargazers, revalidating it with
+// its etag, unless it is the last page and refetchLast is set: new stars only
+// ever land on the last page, so it is fetched as is, while the full pages
+// before it keep getting 304s.
+func (gh *GitHub) getStargazersPage(ctx context.Context, repo Repository, page int) ([]Stargazer, error) {
+	revalidate := !gh.refetchLast || page < gh.lastPage(repo)
+	return gh.fetchStargazersPage(ctx, repo, page, revalidate)
+}
+
 // nolint: funlen
 // TODO: refactor.
-func (gh *GitHub) getStargazersPage(ctx context.Context, repo Repository, page int) ([]Stargazer, error) {
+func (gh *GitHub) fetchStargazersPage(ctx context.Context, repo Repository, page int, revalidate bool) ([]Stargazer, error) {
 	log := log.WithField("repo", repo.FullName).WithField("page", page)
 	defer log.Trace("get page").Stop(nil)
 
 	var stars []Stargazer
-	key := fmt.Sprintf("%s_%d", repo.FullName, page)
-	etagKey := fmt.Sprintf("%s_%d", repo.FullName, page) + "_etag"
+	key := pageKey(repo.FullName, page)
+	etagKey := pageEtagKey(repo.FullName, page)
 
 	// 读缓存，没命中就发请求
 	var etag string
-	if err := gh.cache.Get(etagKey, &etag); err != nil {
-		log.WithError(err).Warnf("failed to get %s from cache", etagKey)
+	if revalidate {
+		if err := gh.cacheGet(ctx, etagKey, &etag); err != nil && !errors.Is(err, cache.ErrNotFound) {
+			log.WithError(err).Warnf("failed to get %s from cache", etagKey)
+		}
 	}
 
-	resp, err := gh.makeStarPageRequest(ctx, repo, page, etag)
+	mediaType := gh.starsMediaType
+	resp, err := gh.makeStarPageRequest(ctx, repo, page, etag, mediaType)
+	if servesCached(err) && gh.cache.Get(key, &stars) == nil {
+		log.Warn("github is unavailable, serving stale page")
+		return stars, nil
+	}
+	if errors.Is(err, ErrNotYetAvailable) && page > gh.totalPages(repo) {
+		// empty pages are never cached, so past the counted stars a missing
+		// page means there are no more stars.
+		return stars, errNoMorePages
+	}
 	if err != nil {
 		return stars, err
 	}
+	if isMediaTypeRejected(resp) && mediaType != defaultStarsMediaType {
+		log.Warnf("github rejected media type %q, falling back to %q", mediaType, defaultStarsMediaType)
+		resp.Body.Close()
+		resp, err = gh.makeStarPageRequest(ctx, repo, page, etag, defaultStarsMediaType)
+		if err != nil {
+			return stars, err
+		}
+	}
 
-	bts, err := io.ReadAll(resp.Body)
+	defer resp.Body.Close()
+	bts, err := gh.readBody(resp.Body)
 	if err != nil {
 		return stars, err
 	}
-	defer resp.Body.Close()
 
 	switch resp.StatusCode {
 	// 304（未修改）自从上次请求后，请求的网页未修改过，直接拿缓存，这样就不会拿到过期数据
@@ -114,11 +477,18 @@ func (gh *GitHub) getStargazersPage(ctx context.Context, repo Repository, page i
 		err := gh.cache.Get(key, &stars)
 		if err != nil {
 			log.WithError(err).Warnf("failed to get %s from cache", key)
-			if err := gh.cache.Delete(etagKey); err != nil {
-				log.WithError(err).Warnf("failed to delete %s from cache", etagKey)
+			// only drop the etag if the page is really gone, a cache backend
+			// failure doesn't mean it is stale.
+			if errors.Is(err, cache.ErrNotFound) {
+				if err := gh.cache.Delete(etagKey); err != nil {
+					log.WithError(err).Warnf("failed to delete %s from cache", etagKey)
+				}
 			}
+			// the refetch needs a token slot of its own, so this one must
+			// be released first.
+			resp.Body.Close()
 			// 从缓存里拿
-			return gh.getStargazersPage(ctx, repo, page)
+			return gh.fetchStargazersPage(ctx, repo, page, false)
 		}
 		return stars, err
 	case http.StatusForbidden:
@@ -127,12 +497,16 @@ func (gh *GitHub) getStargazersPage(ctx context.Context, repo Repository, page i
 		return stars, ErrRateLimit
 	case http.StatusOK:
 		// 使用json.Unmarshal函数对一个字节切片进行反序列化，并将结果存储到stars变量中
-		if err := json.Unmarshal(bts, &stars); err != nil {
+		stars, err := parseStargazersPage(bts)
+		if err != nil {
 			return stars, err
 		}
 		if len(stars) == 0 {
 			return stars, errNoMorePages
 		}
+		if missing := missingStarredAt(stars); missing > 0 {
+			log.Warnf("%d stargazers without starred_at, make sure the %q media type is supported", missing, mediaType)
+		}
 		// 放在缓存里
 		if err := gh.cache.Put(key, stars); err != nil {
 			log.WithError(err).Warnf("failed to cache %s", key)
@@ -144,12 +518,7 @@ func (gh *GitHub) getStargazersPage(ctx context.Context, repo Repository, page i
 		//并将上次获取的ETag值作为其值。服务器在收到这个请求后，会检查资源的ETag值是否与客户端提供的值匹配。如果匹配，服务器会返回
 		//一个特殊的304 Not Modified响应，表示资源未发生变化，客户端可以使用缓存的版本。如果ETag值不匹配，服务器会返回资源的最新版本，
 		//并更新ETag的值。
-		etag = resp.Header.Get("etag")
-		if etag != "" {
-			if err := gh.cache.Put(etagKey, etag); err != nil {
-				log.WithError(err).Warnf("failed to cache %s", etagKey)
-			}
-		}
+		gh.cacheEtag(log, etagKey, resp.Header.Get("etag"))
 
 		return stars, nil
 	default:
@@ -157,15 +526,61 @@ func (gh *GitHub) getStargazersPage(ctx context.Context, repo Repository, page i
 	}
 }
 
+// parseStargazersPage parses a page of stargazers as returned by the github
+// api, erroring with ErrInvalidResponse if it isn't a list of stargazers.
+func parseStargazersPage(bts []byte) ([]Stargazer, error) {
+	var stars []Stargazer
+	body := bytes.TrimSpace(bts)
+	if len(body) == 0 || body[0] != '[' {
+		return stars, fmt.Errorf("%w: expected a list of stargazers, got %q", ErrInvalidResponse, truncate(body, 64))
+	}
+	if err := json.Unmarshal(body, &stars); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidResponse, err)
+	}
+	return stars, nil
+}
+
+func truncate(bts []byte, n int) []byte {
+	if len(bts) > n {
+		return bts[:n]
+	}
+	return bts
+}
+
+// totalPages is how many pages the stars of the given repo fill, the last one
+// maybe partially.
 func (gh *GitHub) totalPages(repo Repository) int {
-	return repo.StargazersCount / gh.pageSize
+	return (repo.StargazersCount + gh.pageSize - 1) / gh.pageSize
 }
 
+// lastPage is the last page of stargazers to fetch for the given repo: the
+// last one its stars fill, or the one after it if they fill it up exactly, to
+// catch stars that landed after the count was fetched.
+// At most that one page comes back empty.
 func (gh *GitHub) lastPage(repo Repository) int {
-	return gh.totalPages(repo) + 1
+	return repo.StargazersCount/gh.pageSize + 1
+}
+
+// defaultStarsMediaType is the media type that makes github include the
+// starred_at field in the stargazers list.
+const defaultStarsMediaType = "application/vnd.github.v3.star+json"
+
+func isMediaTypeRejected(resp *http.Response) bool {
+	return resp.StatusCode == http.StatusUnsupportedMediaType ||
+		resp.StatusCode == http.StatusNotAcceptable
+}
+
+func missingStarredAt(stars []Stargazer) int {
+	var missing int
+	for _, star := range stars {
+		if star.StarredAt.IsZero() {
+			missing++
+		}
+	}
+	return missing
 }
 
-func (gh *GitHub) makeStarPageRequest(ctx context.Context, repo Repository, page int, etag string) (*http.Response, error) {
+func (gh *GitHub) makeStarPageRequest(ctx context.Context, repo Repository, page int, etag, mediaType string) (*http.Response, error) {
 	url := fmt.Sprintf(
 		"https://api.github.com/repos/%s/stargazers?page=%d&per_page=%d",
 		repo.FullName,
@@ -173,12 +588,12 @@ func (gh *GitHub) makeStarPageRequest(ctx context.Context, repo Repository, page
 		gh.pageSize,
 	)
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	req, err := http.NewRequestWithContext(gh.pinToken(ctx, repo.FullName), http.MethodGet, url, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	req.Header.Add("Accept", "application/vnd.github.v3.star+json")
+	req.Header.Add("Accept", mediaType)
 	if etag != "" {
 		req.Header.Add("If-None-Match", etag)
 	}
@@ -2,11 +2,19 @@ package github
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/alicebob/miniredis"
-	"github.com/caarlos0/starcharts/config"
 	"github.com/caarlos0/starcharts/internal/cache"
 	"github.com/caarlos0/starcharts/internal/roundrobin"
 	"github.com/go-redis/redis"
@@ -19,6 +27,7 @@ func TestStargazers(t *testing.T) {
 
 	gock.New("https://api.github.com").
 		Get("/rate_limit").
+		Persist().
 		Reply(200).
 		JSON(rateLimit{rate{Limit: 5000, Remaining: 4000}})
 
@@ -38,10 +47,11 @@ func TestStargazers(t *testing.T) {
 		Addr: mr.Addr(),
 	})
 
-	config := config.Get()
+	config := testConfig()
 	cache := cache.New(rc)
 	defer cache.Close()
 	gt := New(config, cache)
+	gt.refreshInterval = 0
 
 	t.Run("get stargazers from api", func(t *testing.T) {
 		is := is.New(t)
@@ -55,7 +65,7 @@ func TestStargazers(t *testing.T) {
 
 	t.Run("get stargazers from cache", func(t *testing.T) {
 		is := is.New(t)
-		is.NoErr(cache.Put(repo.FullName+"_1_etag", "asdasd"))
+		is.NoErr(cache.Put(pageEtagKey(repo.FullName, 1), "asdasd"))
 		gock.New("https://api.github.com").
 			Get("/repos/test/test/stargazers").
 			MatchHeader("If-None-Match", "asdasd").
@@ -109,7 +119,7 @@ func TestStargazers_EmptyResponseOnPagination(t *testing.T) {
 		Addr: mr.Addr(),
 	})
 
-	config := config.Get()
+	config := testConfig()
 	cache := cache.New(rc)
 	defer cache.Close()
 	gt := New(config, cache)
@@ -160,7 +170,7 @@ func TestStargazers_APIFailure(t *testing.T) {
 		Addr: mr.Addr(),
 	})
 
-	config := config.Get()
+	config := testConfig()
 	cache := cache.New(rc)
 	defer cache.Close()
 	gt := New(config, cache)
@@ -176,3 +186,1131 @@ func TestStargazers_APIFailure(t *testing.T) {
 		is.True(err != nil) // should not have errored
 	})
 }
+
+func TestStargazers_Timeout(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("https://api.github.com").
+		Get("/rate_limit").
+		Persist().
+		Reply(200).
+		JSON(rateLimit{rate{Limit: 5000, Remaining: 4000}})
+
+	gock.New("https://api.github.com").
+		Get("/repos/test/test/stargazers").
+		Persist().
+		Reply(200).
+		Delay(200 * time.Millisecond).
+		JSON([]Stargazer{{StarredAt: time.Now()}})
+
+	repo := Repository{
+		FullName:        "test/test",
+		CreatedAt:       "2008-02-28T20:40:04Z",
+		StargazersCount: 2,
+	}
+
+	mr, _ := miniredis.Run()
+	rc := redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	})
+
+	config := testConfig()
+	cache := cache.New(rc)
+	defer cache.Close()
+	gt := New(config, cache)
+	gt.fetchTimeout = 10 * time.Millisecond
+
+	is := is.New(t)
+	_, err := gt.Stargazers(context.TODO(), repo)
+	is.True(errors.Is(err, ErrTimeout)) // should have timed out
+}
+
+func TestStargazers_Cancel(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("https://api.github.com").
+		Get("/rate_limit").
+		Persist().
+		Reply(200).
+		JSON(rateLimit{rate{Limit: 5000, Remaining: 4000}})
+
+	var fetched int32
+	gock.New("https://api.github.com").
+		Get("/repos/test/test/stargazers").
+		AddMatcher(func(*http.Request, *gock.Request) (bool, error) {
+			atomic.AddInt32(&fetched, 1)
+			return true, nil
+		}).
+		Persist().
+		Reply(200).
+		Delay(50 * time.Millisecond).
+		JSON([]Stargazer{{StarredAt: time.Now()}})
+
+	mr, _ := miniredis.Run()
+	rc := redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	})
+
+	config := testConfig()
+	cache := cache.New(rc)
+	defer cache.Close()
+	gt := New(config, cache)
+	repo := Repository{
+		FullName:        "test/test",
+		CreatedAt:       "2008-02-28T20:40:04Z",
+		StargazersCount: 100 * gt.pageSize,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	is := is.New(t)
+	_, err := gt.Stargazers(ctx, repo)
+	is.True(errors.Is(err, context.Canceled)) // should have been cancelled
+	is.True(atomic.LoadInt32(&fetched) <= 4)  // should not fetch pages after cancelling
+}
+
+func TestStargazers_Overloaded(t *testing.T) {
+	defer gock.Off()
+
+	repo := Repository{
+		FullName:        "test/test",
+		CreatedAt:       "2008-02-28T20:40:04Z",
+		StargazersCount: 2,
+	}
+
+	mr, _ := miniredis.Run()
+	rc := redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	})
+
+	config := testConfig()
+	config.GitHubMaxInFlight = 1
+	cache := cache.New(rc)
+	defer cache.Close()
+	gt := New(config, cache)
+	gt.inFlight <- struct{}{}
+
+	t.Run("rejects cold fetches", func(t *testing.T) {
+		is := is.New(t)
+		_, err := gt.Stargazers(context.TODO(), repo)
+		is.True(errors.Is(err, ErrOverloaded)) // should be overloaded
+	})
+
+	t.Run("allows cached fetches", func(t *testing.T) {
+		is := is.New(t)
+		gock.New("https://api.github.com").
+			Get("/rate_limit").
+			Reply(200).
+			JSON(rateLimit{rate{Limit: 5000, Remaining: 4000}})
+		is.NoErr(cache.Put(pageEtagKey(repo.FullName, 1), "asdasd"))
+		is.NoErr(cache.Put(pageKey(repo.FullName, 1), []Stargazer{{StarredAt: time.Now()}}))
+		gock.New("https://api.github.com").
+			Get("/repos/test/test/stargazers").
+			MatchHeader("If-None-Match", "asdasd").
+			Reply(304)
+		_, err := gt.Stargazers(context.TODO(), repo)
+		is.NoErr(err) // should not have errored
+	})
+}
+
+func TestStargazers_Resume(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("https://api.github.com").
+		Get("/rate_limit").
+		Times(4).
+		Reply(200).
+		JSON(rateLimit{rate{Limit: 5000, Remaining: 4000}})
+
+	repo := Repository{
+		FullName:        "test/test",
+		CreatedAt:       "2008-02-28T20:40:04Z",
+		StargazersCount: 5,
+	}
+	page := func(n int) []Stargazer {
+		var stars []Stargazer
+		for i := 0; i < n; i++ {
+			stars = append(stars, Stargazer{StarredAt: time.Now()})
+		}
+		return stars
+	}
+
+	mr, _ := miniredis.Run()
+	rc := redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	})
+
+	config := testConfig()
+	config.GitHubPageSize = 2
+	cache := cache.New(rc)
+	defer cache.Close()
+	gt := New(config, cache)
+
+	t.Run("interrupted", func(t *testing.T) {
+		is := is.New(t)
+		for _, n := range []string{"1", "2"} {
+			gock.New("https://api.github.com").
+				Get("/repos/test/test/stargazers").
+				MatchParam("page", n).
+				Reply(200).
+				JSON(page(2))
+		}
+		gock.New("https://api.github.com").
+			Get("/repos/test/test/stargazers").
+			MatchParam("page", "3").
+			Reply(403).
+			Delay(100 * time.Millisecond)
+		_, err := gt.Stargazers(context.TODO(), repo)
+		is.True(errors.Is(err, ErrRateLimit)) // should have been interrupted
+
+		var checkpoint int
+		is.NoErr(cache.Get(checkpointKey(repo), &checkpoint))
+		is.Equal(2, checkpoint) // should have checkpointed the full pages
+	})
+
+	t.Run("resumed", func(t *testing.T) {
+		is := is.New(t)
+		gock.New("https://api.github.com").
+			Get("/repos/test/test/stargazers").
+			MatchParam("page", "3").
+			Reply(200).
+			JSON(page(1))
+		stars, err := gt.Stargazers(context.TODO(), repo)
+		is.NoErr(err)           // should not have errored
+		is.Equal(5, len(stars)) // should have all stars
+		is.True(gock.IsDone())  // should have fetched only the last page
+	})
+}
+
+func TestStargazers_UnderReportedCount(t *testing.T) {
+	defer gock.Off()
+	is := is.New(t)
+
+	gock.New("https://api.github.com").
+		Get("/rate_limit").
+		Persist().
+		Reply(200).
+		JSON(rateLimit{rate{Limit: 5000, Remaining: 4000}})
+
+	// github says 2 stars, but there are 11 spread over 6 pages.
+	repo := Repository{
+		FullName:        "test/test",
+		CreatedAt:       "2008-02-28T20:40:04Z",
+		StargazersCount: 2,
+	}
+	for n := 1; n <= 6; n++ {
+		stars := []Stargazer{{StarredAt: time.Now()}, {StarredAt: time.Now()}}
+		if n == 6 {
+			stars = stars[:1]
+		}
+		gock.New("https://api.github.com").
+			Get("/repos/test/test/stargazers").
+			MatchParam("page", fmt.Sprintf("^%d$", n)).
+			Reply(200).
+			JSON(stars)
+	}
+
+	mr, _ := miniredis.Run()
+	rc := redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	})
+
+	config := testConfig()
+	config.GitHubPageSize = 2
+	cache := cache.New(rc)
+	defer cache.Close()
+	gt := New(config, cache)
+
+	stars, err := gt.Stargazers(context.TODO(), repo)
+	is.NoErr(err)            // should not have errored
+	is.Equal(11, len(stars)) // should have fetched past the reported count
+}
+
+func TestStargazers_MaxStars(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("https://api.github.com").
+		Get("/rate_limit").
+		Persist().
+		Reply(200).
+		JSON(rateLimit{rate{Limit: 5000, Remaining: 4000}})
+
+	mr, _ := miniredis.Run()
+	rc := redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	})
+
+	config := testConfig()
+	config.GitHubMaxStars = 2
+	cache := cache.New(rc)
+	defer cache.Close()
+	gt := New(config, cache)
+
+	t.Run("at the max", func(t *testing.T) {
+		is := is.New(t)
+		gock.New("https://api.github.com").
+			Get("/repos/test/test/stargazers").
+			Reply(200).
+			JSON([]Stargazer{{StarredAt: time.Now()}, {StarredAt: time.Now()}})
+		stars, err := gt.Stargazers(context.TODO(), Repository{
+			FullName:        "test/test",
+			StargazersCount: 2,
+		})
+		is.NoErr(err)           // should not have errored
+		is.Equal(2, len(stars)) // should have all stars
+	})
+
+	t.Run("above the max", func(t *testing.T) {
+		is := is.New(t)
+		_, err := gt.Stargazers(context.TODO(), Repository{
+			FullName:        "test/big",
+			StargazersCount: 3,
+		})
+		is.True(errors.Is(err, ErrAboveMaxStars))  // should have refused the repo
+		is.True(!errors.Is(err, ErrTooManyStars))  // should be a different error
+		is.True(errors.Is(err, ErrRecentListable)) // its latest stars are still listed
+	})
+
+	t.Run("above the max and the pages github lists", func(t *testing.T) {
+		is := is.New(t)
+		_, err := gt.Stargazers(context.TODO(), Repository{
+			FullName:        "test/huge",
+			StargazersCount: maxPages*gt.pageSize + 1,
+		})
+		is.True(errors.Is(err, ErrAboveMaxStars))   // should have refused the repo
+		is.True(!errors.Is(err, ErrRecentListable)) // its latest stars aren't listed
+	})
+}
+
+func TestStargazers_RefreshSuppressed(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("https://api.github.com").
+		Get("/rate_limit").
+		Reply(200).
+		JSON(rateLimit{rate{Limit: 5000, Remaining: 4000}})
+
+	gock.New("https://api.github.com").
+		Get("/repos/test/test/stargazers").
+		Reply(200).
+		JSON([]Stargazer{{StarredAt: time.Now()}, {StarredAt: time.Now()}})
+
+	repo := Repository{
+		FullName:        "test/test",
+		CreatedAt:       "2008-02-28T20:40:04Z",
+		StargazersCount: 2,
+	}
+
+	mr, _ := miniredis.Run()
+	rc := redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	})
+
+	config := testConfig()
+	config.GitHubRefreshInterval = time.Minute
+	config.GitHubRefreshScale = 0
+	cache := cache.New(rc)
+	defer cache.Close()
+	gt := New(config, cache)
+
+	is := is.New(t)
+	stars, err := gt.Stargazers(context.TODO(), repo)
+	is.NoErr(err)
+	is.Equal(2, len(stars))
+	is.True(gock.IsDone()) // should have fetched from github
+
+	stars, err = gt.Stargazers(context.TODO(), repo)
+	is.NoErr(err)           // should not have hit github again
+	is.Equal(2, len(stars)) // should serve the just fetched stars
+
+	mr.FastForward(time.Minute)
+	_, err = gt.Stargazers(context.TODO(), repo)
+	is.True(err != nil) // should try to hit github again after the interval
+}
+
+// failingCache fails to get the given key, as if the backend was down.
+type failingCache struct {
+	cache.Cache
+	key string
+}
+
+func (c failingCache) Get(key string, result interface{}) error {
+	if key == c.key {
+		return errors.New("connection refused")
+	}
+	return c.Cache.Get(key, result)
+}
+
+func TestStargazers_CacheFailure(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("https://api.github.com").
+		Get("/rate_limit").
+		Times(2).
+		Reply(200).
+		JSON(rateLimit{rate{Limit: 5000, Remaining: 4000}})
+
+	gock.New("https://api.github.com").
+		Get("/repos/test/test/stargazers").
+		MatchHeader("If-None-Match", "asdasd").
+		Reply(304)
+
+	gock.New("https://api.github.com").
+		Get("/repos/test/test/stargazers").
+		Reply(200).
+		JSON([]Stargazer{{StarredAt: time.Now()}, {StarredAt: time.Now()}})
+
+	repo := Repository{
+		FullName:        "test/test",
+		CreatedAt:       "2008-02-28T20:40:04Z",
+		StargazersCount: 2,
+	}
+
+	mr, _ := miniredis.Run()
+	rc := redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	})
+
+	config := testConfig()
+	redisCache := cache.New(rc)
+	defer redisCache.Close()
+	gt := New(config, failingCache{Cache: redisCache, key: pageKey("test/test", 1)})
+
+	is := is.New(t)
+	is.NoErr(redisCache.Put(pageEtagKey("test/test", 1), "asdasd"))
+	stars, err := gt.Stargazers(context.TODO(), repo)
+	is.NoErr(err)           // should have fetched the page again
+	is.Equal(2, len(stars)) // should have the stars
+	is.True(gock.IsDone())  // should not have looped
+
+	var etag string
+	is.NoErr(redisCache.Get(pageEtagKey("test/test", 1), &etag)) // should have kept the etag
+}
+
+func TestStargazers_ExpiredEtag(t *testing.T) {
+	defer gock.Off()
+
+	repo := Repository{
+		FullName:        "test/test",
+		CreatedAt:       "2008-02-28T20:40:04Z",
+		StargazersCount: 2,
+	}
+	stargazers := []Stargazer{{StarredAt: time.Now()}, {StarredAt: time.Now()}}
+
+	gock.New("https://api.github.com").
+		Get("/rate_limit").
+		Times(2).
+		Reply(200).
+		JSON(rateLimit{rate{Limit: 5000, Remaining: 4000}})
+
+	gock.New("https://api.github.com").
+		Get("/repos/test/test/stargazers").
+		Reply(200).
+		SetHeader("etag", "asdasd").
+		JSON(stargazers)
+
+	gock.New("https://api.github.com").
+		Get("/repos/test/test/stargazers").
+		AddMatcher(func(req *http.Request, _ *gock.Request) (bool, error) {
+			return req.Header.Get("If-None-Match") == "", nil
+		}).
+		Reply(200).
+		JSON(stargazers)
+
+	mr, _ := miniredis.Run()
+	rc := redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	})
+
+	config := testConfig()
+	cache := cache.New(rc)
+	defer cache.Close()
+	gt := New(config, cache)
+	gt.refreshInterval = 0
+	gt.etagTTL = time.Minute
+
+	is := is.New(t)
+	_, err := gt.Stargazers(context.TODO(), repo)
+	is.NoErr(err)                                              // should not have errored
+	is.Equal(time.Minute, mr.TTL(pageEtagKey("test/test", 1))) // should expire the etag
+	is.Equal(time.Hour, mr.TTL(pageKey("test/test", 1)))       // should keep the data for the default ttl
+
+	mr.FastForward(2 * time.Minute)
+	is.True(!mr.Exists(pageEtagKey("test/test", 1))) // should have expired the etag
+	is.True(mr.Exists(pageKey("test/test", 1)))      // should have kept the data
+
+	stars, err := gt.Stargazers(context.TODO(), repo)
+	is.NoErr(err)           // should have fetched the page unconditionally
+	is.Equal(2, len(stars)) // should have the stars
+	is.True(gock.IsDone())  // should have made both requests
+}
+
+func TestStargazers_ExpiredData(t *testing.T) {
+	defer gock.Off()
+
+	repo := Repository{
+		FullName:        "test/test",
+		CreatedAt:       "2008-02-28T20:40:04Z",
+		StargazersCount: 2,
+	}
+	stargazers := []Stargazer{{StarredAt: time.Now()}, {StarredAt: time.Now()}}
+
+	gock.New("https://api.github.com").
+		Get("/rate_limit").
+		Times(3).
+		Reply(200).
+		JSON(rateLimit{rate{Limit: 5000, Remaining: 4000}})
+
+	gock.New("https://api.github.com").
+		Get("/repos/test/test/stargazers").
+		Reply(200).
+		SetHeader("etag", "asdasd").
+		JSON(stargazers)
+
+	gock.New("https://api.github.com").
+		Get("/repos/test/test/stargazers").
+		MatchHeader("If-None-Match", "asdasd").
+		Reply(304)
+
+	gock.New("https://api.github.com").
+		Get("/repos/test/test/stargazers").
+		AddMatcher(func(req *http.Request, _ *gock.Request) (bool, error) {
+			return req.Header.Get("If-None-Match") == "", nil
+		}).
+		Reply(200).
+		JSON(stargazers)
+
+	mr, _ := miniredis.Run()
+	rc := redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	})
+
+	config := testConfig()
+	cache := cache.New(rc)
+	defer cache.Close()
+	gt := New(config, cache)
+	gt.refreshInterval = 0
+	gt.etagTTL = 24 * time.Hour
+
+	is := is.New(t)
+	_, err := gt.Stargazers(context.TODO(), repo)
+	is.NoErr(err) // should not have errored
+
+	mr.FastForward(2 * time.Hour)
+	is.True(mr.Exists(pageEtagKey("test/test", 1))) // should have kept the etag
+	is.True(!mr.Exists(pageKey("test/test", 1)))    // should have expired the data
+
+	stars, err := gt.Stargazers(context.TODO(), repo)
+	is.NoErr(err)           // should have refetched the page after the 304
+	is.Equal(2, len(stars)) // should have the stars
+	is.True(gock.IsDone())  // should have made all the requests
+}
+
+func TestRecentStargazers(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("https://api.github.com").
+		Get("/rate_limit").
+		Times(2).
+		Reply(200).
+		JSON(rateLimit{rate{Limit: 5000, Remaining: 4000}})
+
+	repo := Repository{
+		FullName:        "test/test",
+		CreatedAt:       "2008-02-28T20:40:04Z",
+		StargazersCount: 5,
+	}
+
+	now := time.Now()
+	gock.New("https://api.github.com").
+		Get("/repos/test/test/stargazers").
+		MatchParam("page", "2").
+		Reply(200).
+		JSON([]Stargazer{
+			{StarredAt: now.Add(-3 * time.Hour)},
+			{StarredAt: now.Add(-2 * time.Hour)},
+		})
+
+	gock.New("https://api.github.com").
+		Get("/repos/test/test/stargazers").
+		MatchParam("page", "3").
+		Reply(200).
+		JSON([]Stargazer{
+			{StarredAt: now.Add(-1 * time.Hour)},
+		})
+
+	mr, _ := miniredis.Run()
+	rc := redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	})
+
+	config := testConfig()
+	cache := cache.New(rc)
+	defer cache.Close()
+	gt := New(config, cache)
+	gt.pageSize = 2
+
+	is := is.New(t)
+	stars, err := gt.RecentStargazers(context.TODO(), repo, 2)
+	is.NoErr(err)           // should not have errored
+	is.Equal(2, len(stars)) // should return only the last stars
+	is.True(stars[0].StarredAt.Equal(now.Add(-2 * time.Hour)))
+	is.True(stars[1].StarredAt.Equal(now.Add(-1 * time.Hour)))
+	is.True(gock.IsDone()) // should not have fetched the first page
+}
+
+func TestRecentStargazers_PageLimit(t *testing.T) {
+	setup := func(t *testing.T, pages *[]int, full bool) *GitHub {
+		t.Helper()
+		mr, err := miniredis.Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(mr.Close)
+		cache := cache.New(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+		t.Cleanup(func() { _ = cache.Close() })
+		gt := New(testConfig(), cache)
+		var lock sync.Mutex
+		gt.client = handlerDoer(func(w http.ResponseWriter, r *http.Request) {
+			page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+			lock.Lock()
+			*pages = append(*pages, page)
+			lock.Unlock()
+			stars := make([]Stargazer, gt.pageSize)
+			if page == maxPages && !full {
+				stars = stars[1:]
+			}
+			for i := range stars {
+				stars[i].StarredAt = time.Now().Add(-time.Hour)
+			}
+			_ = json.NewEncoder(w).Encode(stars)
+		})
+		return gt
+	}
+
+	t.Run("past the limit", func(t *testing.T) {
+		is := is.New(t)
+		var pages []int
+		gt := setup(t, &pages, true)
+		repo := Repository{FullName: "test/test", StargazersCount: maxPages*gt.pageSize + 1}
+		_, err := gt.RecentStargazers(context.Background(), repo, 10)
+		is.True(errors.Is(err, ErrRecentTooFar)) // should not try pages github refuses
+		is.Equal(0, len(pages))                  // should not have fetched anything
+	})
+
+	t.Run("up to the limit", func(t *testing.T) {
+		is := is.New(t)
+		var pages []int
+		gt := setup(t, &pages, false)
+		repo := Repository{FullName: "test/test", StargazersCount: maxPages*gt.pageSize - 1}
+		stars, err := gt.RecentStargazers(context.Background(), repo, 10)
+		is.NoErr(err)
+		is.Equal(10, len(stars))
+		sort.Ints(pages)
+		is.Equal([]int{maxPages - 1, maxPages}, pages) // should only fetch the last pages
+	})
+
+	t.Run("count off at the limit", func(t *testing.T) {
+		is := is.New(t)
+		var pages []int
+		gt := setup(t, &pages, true)
+		repo := Repository{FullName: "test/test", StargazersCount: maxPages * gt.pageSize}
+		_, err := gt.RecentStargazers(context.Background(), repo, 10)
+		is.True(errors.Is(err, ErrRecentTooFar)) // should not look for new stars past the limit
+		sort.Ints(pages)
+		is.Equal(maxPages, pages[len(pages)-1]) // should stop at the last page github lists
+	})
+}
+
+// handlerDoer does the requests with the given handler, instead of going to
+// github.
+type handlerDoer http.HandlerFunc
+
+func (h handlerDoer) Do(req *http.Request) (*http.Response, error) {
+	if req.URL.Path == "/rate_limit" {
+		return jsonResponse(http.StatusOK, rateLimit{rate{Limit: 5000, Remaining: 4000}}), nil
+	}
+	w := httptest.NewRecorder()
+	h(w, req)
+	return w.Result(), nil
+}
+
+// sameStars tells whether both lists have stars at the same times, whatever
+// their locations.
+func sameStars(a, b []Stargazer) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].StarredAt.Equal(b[i].StarredAt) {
+			return false
+		}
+	}
+	return true
+}
+
+func jsonResponse(status int, v interface{}) *http.Response {
+	w := httptest.NewRecorder()
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+	return w.Result()
+}
+
+func TestGetStargazersPage(t *testing.T) {
+	repo := Repository{FullName: "test/test", StargazersCount: 2}
+	stars := []Stargazer{
+		{StarredAt: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{StarredAt: time.Date(2022, 1, 2, 0, 0, 0, 0, time.UTC)},
+	}
+
+	setup := func(t *testing.T, handler http.HandlerFunc) (*GitHub, *cache.Redis) {
+		t.Helper()
+		mr, err := miniredis.Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(mr.Close)
+		cache := cache.New(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+		t.Cleanup(func() { _ = cache.Close() })
+		gt := New(testConfig(), cache)
+		gt.client = handlerDoer(handler)
+		return gt, cache
+	}
+
+	t.Run("200", func(t *testing.T) {
+		is := is.New(t)
+		gt, cache := setup(t, func(w http.ResponseWriter, r *http.Request) {
+			is.Equal("/repos/test/test/stargazers", r.URL.Path)
+			is.Equal("2", r.URL.Query().Get("page"))
+			is.Equal("token XXX", r.Header.Get("Authorization"))
+			w.Header().Set("etag", "v1")
+			_ = json.NewEncoder(w).Encode(stars)
+		})
+		result, err := gt.getStargazersPage(context.Background(), repo, 2)
+		is.NoErr(err)
+		is.Equal(stars, result)
+
+		var cached []Stargazer
+		is.NoErr(cache.Get(pageKey(repo.FullName, 2), &cached))
+		is.True(sameStars(stars, cached)) // should have cached the page
+		var etag string
+		is.NoErr(cache.Get(pageEtagKey(repo.FullName, 2), &etag))
+		is.Equal("v1", etag) // should have cached the etag
+	})
+
+	t.Run("200 empty", func(t *testing.T) {
+		is := is.New(t)
+		gt, _ := setup(t, func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("[]"))
+		})
+		_, err := gt.getStargazersPage(context.Background(), repo, 1)
+		is.True(errors.Is(err, errNoMorePages))
+	})
+
+	t.Run("304", func(t *testing.T) {
+		is := is.New(t)
+		gt, cache := setup(t, func(w http.ResponseWriter, r *http.Request) {
+			is.Equal("v1", r.Header.Get("If-None-Match"))
+			w.WriteHeader(http.StatusNotModified)
+		})
+		is.NoErr(cache.Put(pageKey(repo.FullName, 1), stars))
+		is.NoErr(cache.Put(pageEtagKey(repo.FullName, 1), "v1"))
+		result, err := gt.getStargazersPage(context.Background(), repo, 1)
+		is.NoErr(err)
+		is.True(sameStars(stars, result)) // should have used the cached page
+	})
+
+	t.Run("304 without cached page", func(t *testing.T) {
+		is := is.New(t)
+		var requests int
+		gt, cache := setup(t, func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			if r.Header.Get("If-None-Match") != "" {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(stars)
+		})
+		is.NoErr(cache.Put(pageEtagKey(repo.FullName, 1), "v1"))
+		result, err := gt.getStargazersPage(context.Background(), repo, 1)
+		is.NoErr(err)
+		is.Equal(stars, result) // should have fetched the page again
+		is.Equal(2, requests)   // should have retried without the etag
+	})
+
+	for status, expected := range map[int]error{
+		http.StatusForbidden:           ErrRateLimit,
+		http.StatusNotFound:            ErrGitHubAPI,
+		http.StatusInternalServerError: ErrGitHubAPI,
+		http.StatusBadGateway:          ErrGitHubAPI,
+	} {
+		status, expected := status, expected
+		t.Run(strconv.Itoa(status), func(t *testing.T) {
+			is := is.New(t)
+			gt, _ := setup(t, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(status)
+				_, _ = w.Write([]byte(`{"message":"nope"}`))
+			})
+			_, err := gt.getStargazersPage(context.Background(), repo, 1)
+			is.True(errors.Is(err, expected))
+		})
+	}
+}
+
+func TestParseStargazersPage(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		is := is.New(t)
+		stars, err := parseStargazersPage([]byte(`[{"starred_at":"2020-01-01T00:00:00Z","user":{"login":"foo"},"extra":1}]`))
+		is.NoErr(err)
+		is.Equal(1, len(stars))
+		is.Equal(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), stars[0].StarredAt)
+	})
+
+	t.Run("full star+json payload", func(t *testing.T) {
+		is := is.New(t)
+		stars, err := parseStargazersPage([]byte(`[
+			{
+				"starred_at": "2020-01-01T00:00:00Z",
+				"user": {
+					"login": "octocat",
+					"id": 1,
+					"node_id": "MDQ6VXNlcjE=",
+					"avatar_url": "https://github.com/images/error/octocat_happy.gif",
+					"type": "User",
+					"site_admin": false
+				}
+			},
+			{"starred_at": "2020-01-02T00:00:00Z"}
+		]`))
+		is.NoErr(err)
+		is.Equal([]Stargazer{
+			{
+				StarredAt: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+				User:      &User{Login: "octocat", ID: 1},
+			},
+			{StarredAt: time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)},
+		}, stars)
+	})
+
+	t.Run("empty list", func(t *testing.T) {
+		is := is.New(t)
+		stars, err := parseStargazersPage([]byte(`[]`))
+		is.NoErr(err)
+		is.Equal(0, len(stars))
+	})
+
+	for name, body := range map[string]string{
+		"empty":        ``,
+		"null":         `null`,
+		"object":       `{"message":"Not Found"}`,
+		"html":         `<html><body>bad gateway</body></html>`,
+		"invalid date": `[{"starred_at":"yesterday"}]`,
+		"truncated":    `[{"starred_at":"2020-01-01T00:00:00Z"`,
+	} {
+		body := body
+		t.Run(name, func(t *testing.T) {
+			is := is.New(t)
+			_, err := parseStargazersPage([]byte(body))
+			is.True(errors.Is(err, ErrInvalidResponse)) // should be an invalid response
+		})
+	}
+}
+
+func FuzzParseStargazersPage(f *testing.F) {
+	for _, seed := range []string{
+		``,
+		`null`,
+		`[]`,
+		`{}`,
+		`[{"starred_at":"2020-01-01T00:00:00Z"}]`,
+		`[{"starred_at":null,"user":{"login":"foo","id":1}}]`,
+		`[1, "a", true]`,
+	} {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, bts []byte) {
+		stars, err := parseStargazersPage(bts)
+		if err != nil && !errors.Is(err, ErrInvalidResponse) {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if err != nil && len(stars) > 0 {
+			t.Errorf("expected no stars on error, got %d", len(stars))
+		}
+	})
+}
+
+func TestStargazers_MediaType(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("https://api.github.com").
+		Get("/rate_limit").
+		Persist().
+		Reply(200).
+		JSON(rateLimit{rate{Limit: 5000, Remaining: 4000}})
+
+	repo := Repository{
+		FullName:        "test/test",
+		CreatedAt:       "2008-02-28T20:40:04Z",
+		StargazersCount: 2,
+	}
+
+	mr, _ := miniredis.Run()
+	rc := redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	})
+
+	config := testConfig()
+	config.GitHubStarsMediaType = "application/vnd.github.unsupported+json"
+	cache := cache.New(rc)
+	defer cache.Close()
+	gt := New(config, cache)
+
+	t.Run("falls back when rejected", func(t *testing.T) {
+		is := is.New(t)
+		gock.New("https://api.github.com").
+			Get("/repos/test/test/stargazers").
+			MatchHeader("Accept", "unsupported").
+			Reply(415)
+		gock.New("https://api.github.com").
+			Get("/repos/test/test/stargazers").
+			MatchHeader("Accept", "v3.star").
+			Reply(200).
+			JSON([]Stargazer{{StarredAt: time.Now()}, {StarredAt: time.Now()}})
+		stars, err := gt.Stargazers(context.TODO(), repo)
+		is.NoErr(err)           // should have fallen back to the default media type
+		is.Equal(2, len(stars)) // should have the stars
+	})
+
+	t.Run("response missing starred_at", func(t *testing.T) {
+		is := is.New(t)
+		mr.FlushAll()
+		gock.New("https://api.github.com").
+			Get("/repos/test/test/stargazers").
+			Reply(200).
+			BodyString(`[{"login":"foo"},{"login":"bar"}]`)
+		gt.starsMediaType = "application/vnd.github+json"
+		stars, err := gt.Stargazers(context.TODO(), repo)
+		is.NoErr(err)
+		is.Equal(0, len(stars)) // should drop the stars without timestamps
+
+		page, err := parseStargazersPage([]byte(`[{"login":"foo"},{"login":"bar"}]`))
+		is.NoErr(err)
+		is.Equal(2, missingStarredAt(page)) // should detect the missing timestamps
+	})
+}
+
+func TestDedupeStargazers(t *testing.T) {
+	is := is.New(t)
+	now := time.Now()
+	stars := []Stargazer{
+		{StarredAt: now, User: &User{Login: "foo"}},
+		{StarredAt: now, User: &User{Login: "bar"}},
+		{StarredAt: now},
+		{StarredAt: now, User: &User{Login: "foo"}},
+		{StarredAt: now},
+		{StarredAt: now.Add(time.Hour), User: &User{Login: "bar"}},
+	}
+	is.Equal([]Stargazer{
+		{StarredAt: now, User: &User{Login: "foo"}},
+		{StarredAt: now, User: &User{Login: "bar"}},
+		{StarredAt: now},
+		{StarredAt: now},
+	}, dedupeStargazers(stars))
+}
+
+func TestStargazers_OldCacheEntries(t *testing.T) {
+	is := is.New(t)
+	mr, _ := miniredis.Run()
+	rc := redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	})
+	cache := cache.New(rc)
+	defer cache.Close()
+
+	// pages cached before users were captured.
+	type oldStargazer struct {
+		StarredAt time.Time `json:"starred_at"`
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	is.NoErr(cache.Put(pageKey("test/test", 1), []oldStargazer{{StarredAt: now}}))
+
+	var stars []Stargazer
+	is.NoErr(cache.Get(pageKey("test/test", 1), &stars)) // should decode old entries
+	is.Equal(1, len(stars))
+	is.True(stars[0].StarredAt.Equal(now))
+	is.Equal(nil, stars[0].User) // should not have a user
+}
+
+func TestStargazers_BodyTooLarge(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("https://api.github.com").
+		Get("/rate_limit").
+		Reply(200).
+		JSON(rateLimit{rate{Limit: 5000, Remaining: 4000}})
+	gock.New("https://api.github.com").
+		Get("/repos/test/test/stargazers").
+		Reply(200).
+		JSON([]Stargazer{{StarredAt: time.Now()}, {StarredAt: time.Now()}})
+
+	mr, _ := miniredis.Run()
+	rc := redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	})
+	cache := cache.New(rc)
+	defer cache.Close()
+	gt := New(testConfig(), cache)
+	gt.maxBodySize = 64
+
+	is := is.New(t)
+	_, err := gt.Stargazers(context.TODO(), Repository{
+		FullName:        "test/test",
+		StargazersCount: 2,
+	})
+	is.True(errors.Is(err, ErrBodyTooLarge)) // should refuse the oversized body
+}
+
+func TestStargazers_SchemaVersion(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("https://api.github.com").
+		Get("/rate_limit").
+		Reply(200).
+		JSON(rateLimit{rate{Limit: 5000, Remaining: 4000}})
+	gock.New("https://api.github.com").
+		Get("/repos/test/test/stargazers").
+		AddMatcher(func(req *http.Request, _ *gock.Request) (bool, error) {
+			return req.Header.Get("If-None-Match") == "", nil
+		}).
+		Reply(200).
+		JSON([]Stargazer{
+			{StarredAt: time.Now(), User: &User{Login: "foo"}},
+			{StarredAt: time.Now(), User: &User{Login: "bar"}},
+		})
+
+	mr, _ := miniredis.Run()
+	rc := redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	})
+	cache := cache.New(rc)
+	defer cache.Close()
+	gt := New(testConfig(), cache)
+	gt.refreshInterval = 0
+
+	// a page cached before the pages were versioned, without users.
+	is := is.New(t)
+	is.NoErr(cache.Put("test/test_1", []Stargazer{{StarredAt: time.Now()}, {StarredAt: time.Now()}}))
+	is.NoErr(cache.Put("test/test_1_etag", "asdasd"))
+
+	stars, err := gt.Stargazers(context.TODO(), Repository{
+		FullName:        "test/test",
+		StargazersCount: 2,
+	})
+	is.NoErr(err)
+	is.Equal(2, len(stars))
+	is.True(stars[0].User != nil) // should have fetched the page again
+	is.True(gock.IsDone())        // should not have revalidated the old etag
+	is.True(mr.Exists(pageKey("test/test", 1)))
+}
+
+func TestStargazers_RefetchLastPage(t *testing.T) {
+	is := is.New(t)
+	repo := Repository{FullName: "test/test", StargazersCount: 3}
+	page := func(from, n int) []Stargazer {
+		var stars []Stargazer
+		for i := 0; i < n; i++ {
+			stars = append(stars, Stargazer{StarredAt: time.Date(2022, 1, from+i, 0, 0, 0, 0, time.UTC)})
+		}
+		return stars
+	}
+
+	mr, err := miniredis.Run()
+	is.NoErr(err)
+	defer mr.Close()
+	cache := cache.New(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	defer cache.Close()
+	config := testConfig()
+	config.GitHubPageSize = 2
+	config.GitHubRefetchLastPage = true
+	gt := New(config, cache)
+
+	// both pages were fetched before, when the repo had 3 stars.
+	is.NoErr(cache.Put(pageKey(repo.FullName, 1), page(1, 2)))
+	is.NoErr(cache.Put(pageEtagKey(repo.FullName, 1), "v1"))
+	is.NoErr(cache.Put(pageKey(repo.FullName, 2), page(3, 1)))
+	is.NoErr(cache.Put(pageEtagKey(repo.FullName, 2), "v1"))
+
+	etags := map[string]string{}
+	gt.client = handlerDoer(func(w http.ResponseWriter, r *http.Request) {
+		n := r.URL.Query().Get("page")
+		etags[n] = r.Header.Get("If-None-Match")
+		switch n {
+		case "1":
+			w.WriteHeader(http.StatusNotModified)
+		case "2":
+			// a new star landed on the last page.
+			w.Header().Set("etag", "v2")
+			_ = json.NewEncoder(w).Encode(page(3, 2))
+		default:
+			_, _ = w.Write([]byte("[]"))
+		}
+	})
+
+	stars, err := gt.Stargazers(context.Background(), repo)
+	is.NoErr(err)
+	is.True(sameStars(page(1, 4), stars)) // should have the new star
+	is.Equal("v1", etags["1"])            // should revalidate the full page
+	is.Equal("", etags["2"])              // should refetch the last page
+}
+
+func TestStargazers_PageBoundaries(t *testing.T) {
+	for count, expected := range map[int][]int{
+		0:   {1},
+		50:  {1},
+		100: {1, 2},
+		150: {1, 2},
+		200: {1, 2, 3},
+		400: {1, 2, 3, 4, 5},
+	} {
+		count, expected := count, expected
+		t.Run(strconv.Itoa(count), func(t *testing.T) {
+			is := is.New(t)
+			mr, err := miniredis.Run()
+			is.NoErr(err)
+			defer mr.Close()
+			cache := cache.New(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+			defer cache.Close()
+			config := testConfig()
+			config.GitHubPageSize = 100
+			gt := New(config, cache)
+
+			var lock sync.Mutex
+			var requested []int
+			gt.client = handlerDoer(func(w http.ResponseWriter, r *http.Request) {
+				page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+				lock.Lock()
+				requested = append(requested, page)
+				lock.Unlock()
+				stars := []Stargazer{}
+				for i := (page - 1) * 100; i < page*100 && i < count; i++ {
+					stars = append(stars, Stargazer{StarredAt: time.Unix(int64(i), 0)})
+				}
+				_ = json.NewEncoder(w).Encode(stars)
+			})
+
+			stars, err := gt.Stargazers(context.Background(), Repository{FullName: "test/test", StargazersCount: count})
+			is.NoErr(err)
+			is.Equal(count, len(stars))
+			sort.Ints(requested)
+			is.Equal(expected, requested) // should request every page, and at most one empty one
+		})
+	}
+
+	t.Run("page limit", func(t *testing.T) {
+		is := is.New(t)
+		gt := &GitHub{pageSize: 100}
+		is.Equal(maxPages, gt.totalPages(Repository{StargazersCount: maxPages * 100}))
+		is.Equal(maxPages+1, gt.totalPages(Repository{StargazersCount: maxPages*100 + 1})) // should count the partial page
+	})
+}
@@ -0,0 +1,46 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/caarlos0/starcharts/internal/roundrobin"
+)
+
+// ErrUnknownToken happens when a token hint matches none of the tokens.
+var ErrUnknownToken = errors.New("no token matches the token hint")
+
+type tokenHintKey struct{}
+
+// WithTokenHint makes the github requests done with the returned context use
+// the token ending with hint, as shown in the logs and metrics, instead of
+// the round robin pick.
+func WithTokenHint(ctx context.Context, hint string) context.Context {
+	return context.WithValue(ctx, tokenHintKey{}, hint)
+}
+
+func tokenHint(ctx context.Context) string {
+	hint, _ := ctx.Value(tokenHintKey{}).(string)
+	return hint
+}
+
+// hintedToken returns the token ending with hint, failing if none or more
+// than one of them do.
+func (gh *GitHub) hintedToken(hint string) (*roundrobin.Token, error) {
+	var found *roundrobin.Token
+	for _, token := range gh.tokens.Tokens() {
+		if !strings.HasSuffix(token.Key(), hint) {
+			continue
+		}
+		if found != nil {
+			return nil, fmt.Errorf("%w: '...%s' is ambiguous", ErrUnknownToken, hint)
+		}
+		found = token
+	}
+	if found == nil {
+		return nil, fmt.Errorf("%w: '...%s'", ErrUnknownToken, hint)
+	}
+	return found, nil
+}
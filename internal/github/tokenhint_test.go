@@ -0,0 +1,52 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/matryer/is"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestAuthorizedDo_TokenHint(t *testing.T) {
+	defer gock.Off()
+
+	config := testConfig()
+	config.GitHubTokens = []string{"token-aaa", "token-bbb", "other-bbb"}
+	gt := New(config, nil)
+
+	do := func(hint string) (*http.Response, error) {
+		ctx := WithTokenHint(context.Background(), hint)
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://api.github.com/repos/test/test", nil)
+		if err != nil {
+			return nil, err
+		}
+		return gt.authorizedDo(req, 0)
+	}
+
+	t.Run("matching token", func(t *testing.T) {
+		is := is.New(t)
+		gock.New("https://api.github.com").
+			Get("/repos/test/test").
+			MatchHeader("Authorization", "^token token-aaa$").
+			Reply(200)
+		resp, err := do("aaa")
+		is.NoErr(err) // should not have errored
+		defer resp.Body.Close()
+		is.True(gock.IsDone()) // should have used the hinted token without checking its rate limit
+	})
+
+	t.Run("ambiguous hint", func(t *testing.T) {
+		is := is.New(t)
+		_, err := do("bbb")
+		is.True(errors.Is(err, ErrUnknownToken)) // should not pick one of the matching tokens
+	})
+
+	t.Run("unknown hint", func(t *testing.T) {
+		is := is.New(t)
+		_, err := do("zzz")
+		is.True(errors.Is(err, ErrUnknownToken)) // should not fall back to another token
+	})
+}
@@ -0,0 +1,75 @@
+package github
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// tokenSlots bounds how many requests each token serves at once, as hitting
+// a single token with too many concurrent requests trips github's secondary
+// rate limits.
+type tokenSlots struct {
+	max int
+
+	lock  sync.Mutex
+	slots map[string]chan struct{}
+}
+
+// newTokenSlots creates a limit of max concurrent requests per token, or nil
+// if max isn't positive, meaning no limit.
+func newTokenSlots(max int) *tokenSlots {
+	if max <= 0 {
+		return nil
+	}
+	return &tokenSlots{
+		max:   max,
+		slots: map[string]chan struct{}{},
+	}
+}
+
+// acquire waits for a free slot of the given token, returning a function to
+// release it.
+func (s *tokenSlots) acquire(ctx context.Context, token string) (func(), error) {
+	if s == nil {
+		return func() {}, nil
+	}
+	s.lock.Lock()
+	slots, ok := s.slots[token]
+	if !ok {
+		slots = make(chan struct{}, s.max)
+		s.slots[token] = slots
+	}
+	s.lock.Unlock()
+
+	select {
+	case slots <- struct{}{}:
+		var once sync.Once
+		return func() { once.Do(func() { <-slots }) }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// releaseOnClose releases the token slot once the response body is closed,
+// as the request is in flight until then.
+type releaseOnClose struct {
+	io.ReadCloser
+	release func()
+}
+
+func (r releaseOnClose) Close() error {
+	defer r.release()
+	return r.ReadCloser.Close()
+}
+
+// holdSlot keeps the token slot until the response body is closed.
+func holdSlot(resp *http.Response, err error, release func()) (*http.Response, error) {
+	if err != nil || resp == nil || resp.Body == nil {
+		release()
+		return resp, err
+	}
+	resp.Body = releaseOnClose{ReadCloser: resp.Body, release: release}
+	return resp, err
+}
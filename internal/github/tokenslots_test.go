@@ -0,0 +1,130 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis"
+	"github.com/caarlos0/starcharts/internal/cache"
+	"github.com/go-redis/redis"
+	"github.com/matryer/is"
+)
+
+func TestTokenSlots(t *testing.T) {
+	t.Run("respects the cap", func(t *testing.T) {
+		is := is.New(t)
+		slots := newTokenSlots(2)
+		var current, peak int64
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				release, err := slots.acquire(context.Background(), "a")
+				is.NoErr(err)
+				defer release()
+				n := atomic.AddInt64(&current, 1)
+				for {
+					p := atomic.LoadInt64(&peak)
+					if n <= p || atomic.CompareAndSwapInt64(&peak, p, n) {
+						break
+					}
+				}
+				time.Sleep(time.Millisecond)
+				atomic.AddInt64(&current, -1)
+			}()
+		}
+		wg.Wait()
+		is.Equal(int64(2), peak) // should serve at most 2 requests at once
+	})
+
+	t.Run("per token", func(t *testing.T) {
+		is := is.New(t)
+		slots := newTokenSlots(1)
+		release, err := slots.acquire(context.Background(), "a")
+		is.NoErr(err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		_, err = slots.acquire(ctx, "a")
+		is.Equal(context.DeadlineExceeded, err) // should wait for a free slot
+
+		other, err := slots.acquire(context.Background(), "b")
+		is.NoErr(err) // other tokens should have their own slots
+		other()
+
+		release()
+		release() // releasing twice should be harmless
+		again, err := slots.acquire(context.Background(), "a")
+		is.NoErr(err)
+		again()
+	})
+
+	t.Run("unlimited", func(t *testing.T) {
+		is := is.New(t)
+		slots := newTokenSlots(0)
+		is.True(slots == nil)
+		release, err := slots.acquire(context.Background(), "a")
+		is.NoErr(err)
+		release()
+	})
+}
+
+func TestTokenSlotsRefetch(t *testing.T) {
+	repo := Repository{FullName: "test/test", StargazersCount: 1}
+	stars := []Stargazer{{StarredAt: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)}}
+
+	// a 304 whose data is gone is refetched, which must not wait for the
+	// only slot of the token while still holding it.
+	setup := func(t *testing.T) (*GitHub, *cache.Redis) {
+		t.Helper()
+		mr, err := miniredis.Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(mr.Close)
+		cache := cache.New(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+		t.Cleanup(func() { _ = cache.Close() })
+		gt := New(testConfig(), cache)
+		gt.tokenSlots = newTokenSlots(1)
+		gt.client = handlerDoer(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("If-None-Match") != "" {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			if r.URL.Path == "/repos/test/test" {
+				_ = json.NewEncoder(w).Encode(repo)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(stars)
+		})
+		return gt, cache
+	}
+
+	t.Run("stargazers page", func(t *testing.T) {
+		is := is.New(t)
+		gt, cache := setup(t)
+		is.NoErr(cache.Put(pageEtagKey(repo.FullName, 1), "v1"))
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		result, err := gt.fetchStargazersPage(ctx, repo, 1, true)
+		is.NoErr(err) // should not deadlock on the token slot
+		is.True(sameStars(stars, result))
+	})
+
+	t.Run("repo details", func(t *testing.T) {
+		is := is.New(t)
+		gt, cache := setup(t)
+		is.NoErr(cache.Put(repo.FullName+"_etag", "v1"))
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		result, err := gt.fetchRepoDetails(ctx, repo.FullName, true)
+		is.NoErr(err) // should not deadlock on the token slot
+		is.Equal(repo.FullName, result.FullName)
+	})
+}
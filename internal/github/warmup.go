@@ -0,0 +1,91 @@
+package github
+
+import (
+	"context"
+	"sync"
+
+	"github.com/apex/log"
+)
+
+// WarmupStatus tells what a warmup request did.
+type WarmupStatus string
+
+const (
+	// WarmupStarted means the repo is being fetched in the background.
+	WarmupStarted WarmupStatus = "started"
+	// WarmupInProgress means the repo was already being fetched.
+	WarmupInProgress WarmupStatus = "in_progress"
+	// WarmupRecentlyDone means the repo was fetched less than the warmup
+	// cooldown ago.
+	WarmupRecentlyDone WarmupStatus = "recently_done"
+)
+
+// warmups tracks the warmups in progress.
+type warmups struct {
+	lock    sync.Mutex
+	running map[string]bool
+}
+
+// start marks the given repo as warming up, returning false if it already
+// was.
+func (w *warmups) start(name string) bool {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.running == nil {
+		w.running = map[string]bool{}
+	}
+	if w.running[name] {
+		return false
+	}
+	w.running[name] = true
+	return true
+}
+
+func (w *warmups) done(name string) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	delete(w.running, name)
+}
+
+func warmedKey(name string) string {
+	return name + "_warmed"
+}
+
+// Warmup fetches the details and stargazers of the given repo in the
+// background, so they are cached for the next requests.
+//
+// Repeated calls are coalesced: a repo already warming up, or warmed up less
+// than the warmup cooldown ago, isn't fetched again.
+func (gh *GitHub) Warmup(name string) WarmupStatus {
+	var warmed bool
+	if gh.cache.Get(warmedKey(name), &warmed) == nil {
+		return WarmupRecentlyDone
+	}
+	if !gh.warmups.start(name) {
+		return WarmupInProgress
+	}
+	go func() {
+		defer gh.warmups.done(name)
+		log := log.WithField("repo", name)
+		if err := gh.warmup(context.Background(), name); err != nil {
+			log.WithError(err).Warn("warmup failed")
+			return
+		}
+		if gh.warmupCooldown <= 0 {
+			return
+		}
+		if err := gh.cache.PutWithTTL(warmedKey(name), true, gh.warmupCooldown); err != nil {
+			log.WithError(err).Warnf("failed to cache %s", warmedKey(name))
+		}
+	}()
+	return WarmupStarted
+}
+
+func (gh *GitHub) warmup(ctx context.Context, name string) error {
+	repo, err := gh.RepoDetails(ctx, name)
+	if err != nil {
+		return err
+	}
+	_, err = gh.Stargazers(ctx, repo)
+	return err
+}
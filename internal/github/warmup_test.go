@@ -0,0 +1,43 @@
+package github
+
+import (
+	"testing"
+
+	"github.com/alicebob/miniredis"
+	"github.com/caarlos0/starcharts/internal/cache"
+	"github.com/go-redis/redis"
+	"github.com/matryer/is"
+)
+
+func TestWarmup(t *testing.T) {
+	mr, _ := miniredis.Run()
+	rc := redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	})
+	cache := cache.New(rc)
+	defer cache.Close()
+	gt := New(testConfig(), cache)
+
+	t.Run("in progress", func(t *testing.T) {
+		is := is.New(t)
+		is.True(gt.warmups.start("test/running"))
+		defer gt.warmups.done("test/running")
+		is.Equal(WarmupInProgress, gt.Warmup("test/running"))
+	})
+
+	t.Run("recently done", func(t *testing.T) {
+		is := is.New(t)
+		is.NoErr(cache.Put(warmedKey("test/warm"), true))
+		is.Equal(WarmupRecentlyDone, gt.Warmup("test/warm"))
+	})
+}
+
+func TestWarmups(t *testing.T) {
+	is := is.New(t)
+	var w warmups
+	is.True(w.start("a/b"))
+	is.True(!w.start("a/b"))
+	is.True(w.start("c/d"))
+	w.done("a/b")
+	is.True(w.start("a/b"))
+}
@@ -0,0 +1,175 @@
+// Package overrides holds per repository settings that take precedence over
+// the instance wide ones, e.g. to give a few heavy repositories of a public
+// instance special handling.
+package overrides
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// Access overrides whether a repository can be charted.
+type Access string
+
+const (
+	// Default leaves it to the allow and block lists.
+	Default Access = ""
+	// Allow charts the repository even if the lists reject it.
+	Allow Access = "allow"
+	// Deny never charts the repository.
+	Deny Access = "deny"
+)
+
+// Override is the settings of a repository that differ from the defaults.
+// Zero values keep the defaults.
+type Override struct {
+	// MaxPages is the most pages of stargazers fetched for a chart. It can
+	// only lower the default, as github doesn't list more than 400 pages.
+	MaxPages int
+	// RepoTTL is how long the repository details are cached.
+	RepoTTL time.Duration
+	// RefreshInterval is how long the stargazers are served from the cache
+	// after a refresh.
+	RefreshInterval time.Duration
+	// Token is the end of the github token to fetch with, as shown in the
+	// logs and metrics, instead of the round robin pick.
+	Token  string
+	Access Access
+}
+
+// rule is an override of the repositories matching a pattern, as written in
+// the overrides file.
+type rule struct {
+	Repo            string `json:"repo"`
+	MaxPages        int    `json:"max_pages"`
+	RepoTTL         string `json:"repo_ttl"`
+	RefreshInterval string `json:"refresh_interval"`
+	Token           string `json:"token"`
+	Access          Access `json:"access"`
+
+	override Override
+}
+
+// Overrides are the overrides of every configured repository.
+//
+// Rules apply in order, each one overriding the settings it sets for the
+// repositories it matches, so general patterns go first and specific ones
+// last, e.g. myorg/* and then myorg/huge.
+type Overrides struct {
+	rules []rule
+}
+
+// Load reads the overrides from the JSON file at the given path, a list of
+// rules like {"repo": "myorg/*", "max_pages": 200, "repo_ttl": "1h",
+// "refresh_interval": "6h", "token": "a1b2", "access": "deny"}, with repo a
+// glob pattern on owner/repo.
+// An empty path means no overrides.
+func Load(path string) (*Overrides, error) {
+	if path == "" {
+		return &Overrides{}, nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return Parse(file)
+}
+
+// Parse reads the overrides from the given JSON, see Load.
+func Parse(r io.Reader) (*Overrides, error) {
+	var rules []rule
+	if err := json.NewDecoder(r).Decode(&rules); err != nil {
+		return nil, fmt.Errorf("invalid repo overrides: %w", err)
+	}
+	for i := range rules {
+		if err := rules[i].parse(); err != nil {
+			return nil, fmt.Errorf("invalid repo override #%d: %w", i+1, err)
+		}
+	}
+	return &Overrides{rules: rules}, nil
+}
+
+func (r *rule) parse() error {
+	r.Repo = strings.ToLower(strings.TrimSpace(r.Repo))
+	if r.Repo == "" {
+		return errors.New("missing repo")
+	}
+	if _, err := path.Match(r.Repo, ""); err != nil {
+		return fmt.Errorf("invalid repo pattern %q: %w", r.Repo, err)
+	}
+	if r.MaxPages < 0 {
+		return fmt.Errorf("invalid max_pages: %d", r.MaxPages)
+	}
+	switch r.Access {
+	case Default, Allow, Deny:
+	default:
+		return fmt.Errorf("invalid access %q, expected allow or deny", r.Access)
+	}
+	r.override = Override{
+		MaxPages: r.MaxPages,
+		Token:    r.Token,
+		Access:   r.Access,
+	}
+	for _, d := range []struct {
+		key   string
+		value string
+		into  *time.Duration
+	}{
+		{"repo_ttl", r.RepoTTL, &r.override.RepoTTL},
+		{"refresh_interval", r.RefreshInterval, &r.override.RefreshInterval},
+	} {
+		if d.value == "" {
+			continue
+		}
+		duration, err := time.ParseDuration(d.value)
+		if err != nil || duration < 0 {
+			return fmt.Errorf("invalid %s: %q", d.key, d.value)
+		}
+		*d.into = duration
+	}
+	return nil
+}
+
+// For returns the override of the given owner/repo name, merging the rules
+// that match it, or the zero Override if none do.
+func (o *Overrides) For(name string) Override {
+	var result Override
+	if o == nil {
+		return result
+	}
+	name = strings.ToLower(name)
+	for _, r := range o.rules {
+		if ok, _ := path.Match(r.Repo, name); !ok {
+			continue
+		}
+		result = merge(result, r.override)
+	}
+	return result
+}
+
+// merge returns base with the settings of override set on top of it.
+func merge(base, override Override) Override {
+	if override.MaxPages > 0 {
+		base.MaxPages = override.MaxPages
+	}
+	if override.RepoTTL > 0 {
+		base.RepoTTL = override.RepoTTL
+	}
+	if override.RefreshInterval > 0 {
+		base.RefreshInterval = override.RefreshInterval
+	}
+	if override.Token != "" {
+		base.Token = override.Token
+	}
+	if override.Access != Default {
+		base.Access = override.Access
+	}
+	return base
+}
@@ -0,0 +1,104 @@
+package overrides
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestFor(t *testing.T) {
+	overrides, err := Parse(strings.NewReader(`[
+		{"repo": "*/*", "max_pages": 100},
+		{"repo": "myorg/*", "max_pages": 200, "repo_ttl": "1h", "token": "aaaa"},
+		{"repo": "MyOrg/huge", "max_pages": 400, "refresh_interval": "6h"},
+		{"repo": "myorg/secret", "access": "deny"},
+		{"repo": "spam/ok", "access": "allow"}
+	]`))
+	is := is.New(t)
+	is.NoErr(err)
+
+	for name, tt := range map[string]struct {
+		repo     string
+		expected Override
+	}{
+		"no match": {
+			repo: "caarlos0",
+		},
+		"general default": {
+			repo:     "caarlos0/starcharts",
+			expected: Override{MaxPages: 100},
+		},
+		"org wide": {
+			repo:     "myorg/small",
+			expected: Override{MaxPages: 200, RepoTTL: time.Hour, Token: "aaaa"},
+		},
+		"specific wins field by field": {
+			repo:     "myorg/huge",
+			expected: Override{MaxPages: 400, RepoTTL: time.Hour, RefreshInterval: 6 * time.Hour, Token: "aaaa"},
+		},
+		"case insensitive": {
+			repo:     "MYORG/Huge",
+			expected: Override{MaxPages: 400, RepoTTL: time.Hour, RefreshInterval: 6 * time.Hour, Token: "aaaa"},
+		},
+		"access": {
+			repo:     "myorg/secret",
+			expected: Override{MaxPages: 200, RepoTTL: time.Hour, Token: "aaaa", Access: Deny},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			is := is.New(t)
+			is.Equal(overrides.For(tt.repo), tt.expected)
+		})
+	}
+}
+
+func TestForNil(t *testing.T) {
+	is := is.New(t)
+	var overrides *Overrides
+	is.Equal(overrides.For("caarlos0/starcharts"), Override{})
+}
+
+func TestParseInvalid(t *testing.T) {
+	for name, json := range map[string]string{
+		"not json":         `{`,
+		"not a list":       `{"repo": "foo/bar"}`,
+		"missing repo":     `[{"max_pages": 1}]`,
+		"bad pattern":      `[{"repo": "foo/["}]`,
+		"negative pages":   `[{"repo": "foo/bar", "max_pages": -1}]`,
+		"bad ttl":          `[{"repo": "foo/bar", "repo_ttl": "soon"}]`,
+		"negative refresh": `[{"repo": "foo/bar", "refresh_interval": "-1h"}]`,
+		"bad access":       `[{"repo": "foo/bar", "access": "maybe"}]`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			is := is.New(t)
+			_, err := Parse(strings.NewReader(json))
+			is.True(err != nil) // should be invalid
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	t.Run("no file", func(t *testing.T) {
+		is := is.New(t)
+		overrides, err := Load("")
+		is.NoErr(err)
+		is.Equal(overrides.For("foo/bar"), Override{})
+	})
+	t.Run("file", func(t *testing.T) {
+		is := is.New(t)
+		path := filepath.Join(t.TempDir(), "overrides.json")
+		is.NoErr(os.WriteFile(path, []byte(`[{"repo": "foo/bar", "max_pages": 3}]`), 0o600))
+		overrides, err := Load(path)
+		is.NoErr(err)
+		is.Equal(overrides.For("foo/bar").MaxPages, 3)
+	})
+	t.Run("missing file", func(t *testing.T) {
+		is := is.New(t)
+		_, err := Load(filepath.Join(t.TempDir(), "nope.json"))
+		is.True(err != nil) // should fail
+	})
+}
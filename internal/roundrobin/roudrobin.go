@@ -2,20 +2,30 @@
 package roundrobin
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 	"sync"
 	"sync/atomic"
 
 	"github.com/apex/log"
 )
 
+// ErrNoTokensConfigured happens when picking a token and no tokens were
+// configured at all.
+var ErrNoTokensConfigured = errors.New("no github tokens configured")
+
 // RoundRobiner can pick a token from a list of tokens.
 type RoundRobiner interface {
 	Pick() (*Token, error)
+	Tokens() []*Token
 }
 
 // New round robin implementation with the given list of tokens.
+// Duplicated tokens are only used once, and blank ones not at all, e.g. when
+// GITHUB_TOKENS is set but empty.
 func New(tokens []string) RoundRobiner {
+	tokens = dedupe(nonBlank(tokens))
 	log.Debugf("creating round robin with %d tokens", len(tokens))
 	if len(tokens) == 0 {
 		return &noTokensRoundRobin{}
@@ -24,7 +34,43 @@ func New(tokens []string) RoundRobiner {
 	for _, item := range tokens {
 		result = append(result, NewToken(item))
 	}
-	return &realRoundRobin{tokens: result}
+	return fromTokens(result)
+}
+
+// nonBlank drops the blank tokens.
+func nonBlank(tokens []string) []string {
+	result := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		if strings.TrimSpace(token) != "" {
+			result = append(result, token)
+		}
+	}
+	return result
+}
+
+// dedupe drops the tokens that are listed more than once, e.g. pasted twice,
+// warning about each of them, as they would make the pool look bigger than it
+// is.
+func dedupe(tokens []string) []string {
+	seen := make(map[string]bool, len(tokens))
+	result := make([]string, 0, len(tokens))
+	for i, token := range tokens {
+		if seen[token] {
+			// the position, as the token itself is a secret.
+			log.Warnf("dropped token #%d, it is a duplicate", i+1)
+			continue
+		}
+		seen[token] = true
+		result = append(result, token)
+	}
+	return result
+}
+
+func fromTokens(tokens []*Token) RoundRobiner {
+	if len(tokens) == 0 {
+		return &noTokensRoundRobin{}
+	}
+	return &realRoundRobin{tokens: tokens}
 }
 
 type realRoundRobin struct {
@@ -49,10 +95,18 @@ func (rr *realRoundRobin) doPick(try int) (*Token, error) {
 	return rr.doPick(try + 1)
 }
 
+func (rr *realRoundRobin) Tokens() []*Token {
+	return rr.tokens
+}
+
 type noTokensRoundRobin struct{}
 
 func (rr *noTokensRoundRobin) Pick() (*Token, error) {
-	return nil, nil
+	return nil, ErrNoTokensConfigured
+}
+
+func (rr *noTokensRoundRobin) Tokens() []*Token {
+	return nil
 }
 
 // Token is a github token.
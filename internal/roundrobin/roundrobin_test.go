@@ -1,6 +1,7 @@
 package roundrobin
 
 import (
+	"errors"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -50,11 +51,18 @@ func TestTokenString(t *testing.T) {
 }
 
 func TestNoTokens(t *testing.T) {
-	is := is.New(t)
-	rr := New([]string{})
-	pick, err := rr.Pick()
-	is.True(pick == nil) // pick should not nil
-	is.NoErr(err)        // no error should be returned
+	for name, tokens := range map[string][]string{
+		"none":  {},
+		"blank": {"", " "},
+	} {
+		t.Run(name, func(t *testing.T) {
+			is := is.New(t)
+			rr := New(tokens)
+			pick, err := rr.Pick()
+			is.True(pick == nil)                           // pick should be nil
+			is.True(errors.Is(err, ErrNoTokensConfigured)) // should tell there are no tokens
+		})
+	}
 }
 
 func TestNoValidTokens(t *testing.T) {
@@ -117,3 +125,20 @@ func exercise(t *testing.T, rr RoundRobiner, n int) (int64, int64, int64, int64)
 
 	return a, b, c, d
 }
+
+func TestDuplicatedTokens(t *testing.T) {
+	is := is.New(t)
+	rr := New([]string{tokenA, tokenB, tokenA, tokenC, tokenB})
+	is.Equal(3, len(rr.Tokens())) // should keep each token once
+
+	picked := map[string]*Token{}
+	for i := 0; i < 30; i++ {
+		pick, err := rr.Pick()
+		is.NoErr(err)
+		if previous, ok := picked[pick.Key()]; ok {
+			is.True(previous == pick) // should always pick the same token object for a token
+		}
+		picked[pick.Key()] = pick
+	}
+	is.Equal(3, len(picked))
+}
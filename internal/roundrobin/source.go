@@ -0,0 +1,140 @@
+package roundrobin
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+)
+
+// TokenSource provides the github tokens to use, e.g. from a secrets manager.
+type TokenSource interface {
+	Tokens() ([]string, error)
+}
+
+// Static is a fixed list of tokens, e.g. from the GITHUB_TOKENS environment
+// variable.
+type Static []string
+
+// Tokens returns the list of tokens.
+func (s Static) Tokens() ([]string, error) {
+	return s, nil
+}
+
+// File reads the tokens from the file at the given path, one per line.
+// Blank lines and lines starting with # are ignored.
+type File string
+
+// Tokens reads the tokens from the file.
+func (f File) Tokens() ([]string, error) {
+	file, err := os.Open(string(f))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var tokens []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		tokens = append(tokens, line)
+	}
+	return tokens, scanner.Err()
+}
+
+// NewFromSource creates a round robin with the tokens of the given source,
+// reading them again on every interval so rotated tokens are picked up.
+// A zero interval disables re-reading.
+//
+// If the source fails, the error is returned alongside a usable round robin,
+// which keeps its previous tokens until the source succeeds again.
+// The returned round robin is an io.Closer, closing it stops the re-reading.
+func NewFromSource(source TokenSource, interval time.Duration) (RoundRobiner, error) {
+	rr := &reloadingRoundRobin{
+		source: source,
+		rr:     &noTokensRoundRobin{},
+		stop:   make(chan struct{}),
+	}
+	err := rr.reload()
+	if interval > 0 {
+		go rr.watch(interval)
+	}
+	return rr, err
+}
+
+type reloadingRoundRobin struct {
+	source TokenSource
+	lock   sync.RWMutex
+	rr     RoundRobiner
+	stop   chan struct{}
+	once   sync.Once
+}
+
+func (r *reloadingRoundRobin) current() RoundRobiner {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return r.rr
+}
+
+func (r *reloadingRoundRobin) Pick() (*Token, error) {
+	return r.current().Pick()
+}
+
+func (r *reloadingRoundRobin) Tokens() []*Token {
+	return r.current().Tokens()
+}
+
+// reload reads the tokens from the source again, keeping the state of the
+// tokens that didn't change, so invalidated tokens stay invalidated.
+func (r *reloadingRoundRobin) reload() error {
+	keys, err := r.source.Tokens()
+	if err != nil {
+		return err
+	}
+	keys = dedupe(keys)
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	existing := map[string]*Token{}
+	for _, token := range r.rr.Tokens() {
+		existing[token.Key()] = token
+	}
+	tokens := make([]*Token, 0, len(keys))
+	for _, key := range keys {
+		if token, ok := existing[key]; ok {
+			tokens = append(tokens, token)
+			continue
+		}
+		tokens = append(tokens, NewToken(key))
+	}
+	log.Debugf("loaded %d tokens", len(tokens))
+	r.rr = fromTokens(tokens)
+	return nil
+}
+
+func (r *reloadingRoundRobin) watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			if err := r.reload(); err != nil {
+				log.WithError(err).Warn("failed to reload tokens")
+			}
+		}
+	}
+}
+
+// Close stops re-reading the tokens.
+func (r *reloadingRoundRobin) Close() error {
+	r.once.Do(func() { close(r.stop) })
+	return nil
+}
@@ -0,0 +1,109 @@
+package roundrobin
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+type fakeSource struct {
+	tokens []string
+	err    error
+}
+
+func (f *fakeSource) Tokens() ([]string, error) {
+	return f.tokens, f.err
+}
+
+// countingSource counts how many times the tokens were read.
+type countingSource struct {
+	reads int32
+}
+
+func (c *countingSource) Tokens() ([]string, error) {
+	atomic.AddInt32(&c.reads, 1)
+	return []string{tokenA}, nil
+}
+
+func TestFileSource(t *testing.T) {
+	is := is.New(t)
+	path := filepath.Join(t.TempDir(), "tokens")
+	is.NoErr(os.WriteFile(path, []byte("# tokens\n"+tokenA+"\n\n  "+tokenB+"  \n"), 0o600))
+
+	tokens, err := File(path).Tokens()
+	is.NoErr(err)
+	is.Equal([]string{tokenA, tokenB}, tokens)
+
+	_, err = File(filepath.Join(t.TempDir(), "nope")).Tokens()
+	is.True(err != nil) // missing file should err
+}
+
+func TestNewFromSource(t *testing.T) {
+	is := is.New(t)
+	source := &fakeSource{tokens: []string{tokenA, tokenB}}
+	rr, err := NewFromSource(source, 0)
+	is.NoErr(err)
+	is.Equal(2, len(rr.Tokens()))
+
+	invalidated := rr.Tokens()[0]
+	invalidated.Invalidate()
+
+	t.Run("reload", func(t *testing.T) {
+		is := is.New(t)
+		source.tokens = []string{tokenA, tokenB, tokenC}
+		is.NoErr(rr.(*reloadingRoundRobin).reload())
+		is.Equal(3, len(rr.Tokens()))
+		is.True(!rr.Tokens()[0].OK()) // invalidated token should stay invalid
+		is.True(rr.Tokens()[2].OK())
+	})
+
+	t.Run("duplicated tokens", func(t *testing.T) {
+		is := is.New(t)
+		source.tokens = []string{tokenA, tokenB, tokenC, tokenA}
+		is.NoErr(rr.(*reloadingRoundRobin).reload())
+		is.Equal(3, len(rr.Tokens())) // should keep each token once
+	})
+
+	t.Run("failed reload", func(t *testing.T) {
+		is := is.New(t)
+		source.err = errors.New("fake")
+		is.True(rr.(*reloadingRoundRobin).reload() != nil)
+		is.Equal(3, len(rr.Tokens())) // should keep previous tokens
+	})
+}
+
+func TestNewFromSourceError(t *testing.T) {
+	is := is.New(t)
+	rr, err := NewFromSource(&fakeSource{err: errors.New("fake")}, 0)
+	is.True(err != nil)
+	pick, err := rr.Pick()
+	is.True(pick == nil)
+	is.True(errors.Is(err, ErrNoTokensConfigured))
+}
+
+func TestNewFromSourceClose(t *testing.T) {
+	is := is.New(t)
+	source := &countingSource{}
+	rr, err := NewFromSource(source, time.Millisecond)
+	is.NoErr(err)
+	for i := 0; i < 100 && atomic.LoadInt32(&source.reads) < 2; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	is.True(atomic.LoadInt32(&source.reads) >= 2) // should reload on every interval
+
+	closer, ok := rr.(io.Closer)
+	is.True(ok) // should be closeable
+	is.NoErr(closer.Close())
+	is.NoErr(closer.Close()) // closing twice should be fine
+	// a reload may have been in flight when closing.
+	time.Sleep(5 * time.Millisecond)
+	reads := atomic.LoadInt32(&source.reads)
+	time.Sleep(10 * time.Millisecond)
+	is.Equal(reads, atomic.LoadInt32(&source.reads)) // should stop reloading
+}
@@ -2,18 +2,20 @@ package main
 
 import (
 	"embed"
+	"fmt"
 	"net/http"
 	"os"
 	"time"
 
 	"github.com/apex/httplog"
 	"github.com/apex/log"
+	"github.com/apex/log/handlers/json"
 	"github.com/apex/log/handlers/text"
 	"github.com/caarlos0/starcharts/config"
 	"github.com/caarlos0/starcharts/controller"
 	"github.com/caarlos0/starcharts/internal/cache"
 	"github.com/caarlos0/starcharts/internal/github"
-	"github.com/go-redis/redis"
+	"github.com/golang/freetype/truetype"
 	"github.com/gorilla/mux"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
@@ -23,24 +25,86 @@ import (
 //go:embed static/*
 var static embed.FS
 
-var version = "devel"
+var (
+	version = "devel"
+	commit  = "none"
+	date    = "unknown"
+	builtBy = "unknown"
+)
 
 func main() {
 	log.SetHandler(text.New(os.Stderr))
-	// log.SetLevel(log.DebugLevel)
 	// 拿到环境变量 env
 	config := config.Get()
+	setupLog(config)
 	ctx := log.WithField("listen", config.Listen)
-	options, err := redis.ParseURL(config.RedisURL)
+	// 初始化 redis
+	redis, err := cache.NewRedisClient(cache.RedisConfig{
+		URL:            config.RedisURL,
+		PoolSize:       config.RedisPoolSize,
+		DialTimeout:    config.RedisDialTimeout,
+		ReadTimeout:    config.RedisReadTimeout,
+		WriteTimeout:   config.RedisWriteTimeout,
+		MaxRetries:     config.RedisMaxRetries,
+		ClusterAddrs:   config.RedisClusterAddrs,
+		SentinelAddrs:  config.RedisSentinelAddrs,
+		SentinelMaster: config.RedisSentinelMaster,
+	})
 	if err != nil {
-		log.WithError(err).Fatal("invalid redis_url")
+		log.WithError(err).Fatal("invalid redis config")
+	}
+	if err := redis.Ping().Err(); err != nil {
+		log.WithError(err).Fatal("failed to connect to redis")
+	}
+	var cache cache.Cache = cache.New(redis)
+	if config.BlobCacheBucket != "" {
+		// large entries go to the blob store, small ones stay on redis.
+		cache = newTieredCache(config, cache)
+	}
+	if config.ReadOnly {
+		// another instance fetches from github and fills the cache.
+		cache = newReadOnlyCache(cache)
+	}
+	if config.MemoryCacheSize > 0 {
+		// hot keys are answered in-process, without a round trip.
+		cache = newMemoryCache(config, cache)
 	}
-	// 初始化 redis
-	redis := redis.NewClient(options)
-	cache := cache.New(redis)
 	defer cache.Close()
 	// 初始化 github
+	if config.GitHubUserAgent == "" {
+		config.GitHubUserAgent = fmt.Sprintf("starcharts/%s (+https://github.com/caarlos0/starcharts)", version)
+	}
 	github := github.New(config, cache)
+	defer github.Close()
+	if config.GitHubValidateTokens && !config.ReadOnly && github.ValidateTokens() == 0 {
+		log.Fatal("no valid github tokens")
+	}
+
+	watermark := config.Watermark
+	if watermark == "" {
+		watermark = "generated by " + config.InstanceName
+	}
+	if config.WatermarkDisabled {
+		watermark = ""
+	}
+	chartConfig := controller.ChartConfig{
+		Watermark:        watermark,
+		Streaming:        config.ChartStreaming,
+		MaxPoints:        config.ChartMaxPoints,
+		Defaults:         chartDefaults(config),
+		StaleTTL:         config.ChartStaleTTL,
+		DropWeekendStars: config.ChartDropWeekendStars,
+		BusyPlaceholder:  config.ChartBusyPlaceholder,
+		RequestBudget:    config.ChartRequestBudget,
+	}
+
+	filter := controller.NewRepoFilter(config.RepoAllowlist, config.RepoBlocklist).
+		WithOverrides(github.Overrides())
+
+	proxies, err := controller.ParseTrustedProxies(config.TrustedProxies)
+	if err != nil {
+		log.WithError(err).Fatal("invalid trusted proxies")
+	}
 
 	r := mux.NewRouter()
 	r.Path("/").
@@ -52,14 +116,58 @@ func main() {
 	r.PathPrefix("/static/").
 		Methods(http.MethodGet).
 		Handler(http.FileServer(http.FS(static)))
+	r.Path("/admin/cache").
+		Methods(http.MethodGet).
+		Handler(controller.Admin(config.AdminSecret, controller.ListCachedRepos(github)))
+	r.Path("/admin/warmup/{owner}/{repo}").
+		Methods(http.MethodPost).
+		Handler(controller.Admin(config.AdminSecret, controller.WarmupRepo(github)))
+	// registered before the repository routes, as they would match it too.
+	r.Path("/metrics/repos").
+		Methods(http.MethodGet).
+		Handler(controller.Admin(config.AdminSecret, controller.GetRepoMetrics(github)))
+	r.Path("/compare.svg").
+		Methods(http.MethodGet).
+		Handler(controller.DataURI(config.ChartDataURIMaxSize, controller.FilterRepos(filter, controller.GetCompareChart(github))))
+	r.Path("/batch.json").
+		Methods(http.MethodPost).
+		Handler(controller.GetBatchJSON(github, filter))
+	// registered before the repository routes, as they would match it too.
+	r.Path("/orgs/{org}.svg").
+		Methods(http.MethodGet).
+		Handler(controller.DataURI(config.ChartDataURIMaxSize, controller.GetOrgChart(github, filter, chartConfig)))
+	r.Path("/{owner}/{repo}/badge.svg").
+		Methods(http.MethodGet, http.MethodHead).
+		Handler(controller.FilterRepos(filter, controller.GetBadge(github, cache, config.BadgeCacheTTL)))
+	r.Path("/{owner}/{repo}/recent.json").
+		Methods(http.MethodGet).
+		Handler(controller.FilterRepos(filter, controller.GetRecentStargazers(github, config.RecentStargazersMax)))
+	r.Path("/{owner}/{repo}/at").
+		Methods(http.MethodGet).
+		Handler(controller.FilterRepos(filter, controller.GetStarsAt(github)))
+	r.Path("/{owner}/{repo}/between").
+		Methods(http.MethodGet).
+		Handler(controller.FilterRepos(filter, controller.GetStarsBetween(github, config.RecentStargazersMax)))
+	r.Path("/{owner}/{repo}/growth.json").
+		Methods(http.MethodGet).
+		Handler(controller.FilterRepos(filter, controller.GetGrowth(github)))
+	r.Path("/{owner}/{repo}.json").
+		Methods(http.MethodGet, http.MethodHead).
+		Handler(controller.FilterRepos(filter, controller.GetRepoJSON(github)))
+	r.Path("/{owner}/{repo}.csv").
+		Methods(http.MethodGet, http.MethodHead).
+		Handler(controller.FilterRepos(filter, controller.GetRepoCSV(github)))
 	// star 保存为svg
 	r.Path("/{owner}/{repo}.svg").
-		Methods(http.MethodGet).
-		Handler(controller.GetRepoChart(github, cache))
+		Methods(http.MethodGet, http.MethodHead).
+		Handler(controller.DataURI(config.ChartDataURIMaxSize, controller.NormalizeQuery(chartConfig.Defaults, controller.FilterRepos(filter, controller.GetRepoChart(github, cache, chartConfig)))))
+	r.Path("/{owner}/{repo}.png").
+		Methods(http.MethodGet, http.MethodHead).
+		Handler(controller.DataURI(config.ChartDataURIMaxSize, controller.NormalizeQuery(chartConfig.Defaults, controller.FilterRepos(filter, controller.GetRepoChartPNG(github, cache, chartConfig)))))
 	// 核心功能
 	r.Path("/{owner}/{repo}").
 		Methods(http.MethodGet).
-		Handler(controller.GetRepo(static, github, cache, version))
+		Handler(controller.FilterRepos(filter, controller.GetRepo(static, github, cache, version)))
 
 	// generic metrics
 	requestCounter := promauto.NewCounterVec(prometheus.CounterOpts{
@@ -76,17 +184,23 @@ func main() {
 	}, []string{"code", "method"})
 
 	r.Methods(http.MethodGet).Path("/metrics").Handler(promhttp.Handler())
+	r.Methods(http.MethodGet).Path("/version").Handler(controller.GetVersion(controller.BuildInfo{
+		Version: version,
+		Commit:  commit,
+		Date:    date,
+		BuiltBy: builtBy,
+	}))
 
 	srv := &http.Server{
-		Handler: httplog.New(
+		Handler: controller.RealIP(proxies, httplog.New(
 			promhttp.InstrumentHandlerDuration(
 				responseObserver,
 				promhttp.InstrumentHandlerCounter(
 					requestCounter,
-					r,
+					controller.Recover(controller.TokenHint(config.AdminSecret, r)),
 				),
 			),
-		),
+		)),
 		Addr:         config.Listen,
 		WriteTimeout: 60 * time.Second,
 		ReadTimeout:  60 * time.Second,
@@ -94,3 +208,70 @@ func main() {
 	ctx.Info("starting up...")
 	ctx.WithError(srv.ListenAndServe()).Error("failed to start up server")
 }
+
+// setupLog sets the log format and level, so logs can be shipped to log
+// aggregators as JSON, with their fields as keys.
+func setupLog(config config.Config) {
+	switch config.LogFormat {
+	case "text":
+		log.SetHandler(text.New(os.Stderr))
+	case "json":
+		log.SetHandler(json.New(os.Stderr))
+	default:
+		log.Fatalf("invalid log format %q, expected text or json", config.LogFormat)
+	}
+	level, err := log.ParseLevel(config.LogLevel)
+	if err != nil {
+		log.WithError(err).Fatal("invalid log level")
+	}
+	log.SetLevel(level)
+}
+
+func chartDefaults(config config.Config) controller.ChartDefaults {
+	defaults := controller.ChartDefaults{
+		Theme:         config.ChartTheme,
+		Width:         config.ChartWidth,
+		Height:        config.ChartHeight,
+		CalendarTicks: config.ChartCalendarTicks,
+	}
+	if config.ChartLineColor != "" {
+		color, err := controller.ParseColor(config.ChartLineColor)
+		if err != nil {
+			log.WithError(err).Fatal("invalid chart line color")
+		}
+		defaults.LineColor = color
+	}
+	if config.ChartFont != "" {
+		bts, err := os.ReadFile(config.ChartFont)
+		if err != nil {
+			log.WithError(err).Fatal("failed to read chart font")
+		}
+		font, err := truetype.Parse(bts)
+		if err != nil {
+			log.WithError(err).Fatal("failed to parse chart font")
+		}
+		defaults.Font = font
+	}
+	return defaults
+}
+
+func newTieredCache(config config.Config, hot cache.Cache) cache.Cache {
+	blob := cache.NewBlob(cache.BlobConfig{
+		Endpoint:  config.BlobCacheEndpoint,
+		Bucket:    config.BlobCacheBucket,
+		Region:    config.BlobCacheRegion,
+		AccessKey: config.BlobCacheAccessKey,
+		SecretKey: config.BlobCacheSecretKey,
+		Prefix:    "starcharts/",
+	})
+	return cache.NewTiered(hot, blob, config.BlobCacheMinSize)
+}
+
+func newReadOnlyCache(next cache.Cache) cache.Cache {
+	log.Info("read-only mode, github will not be called")
+	return cache.NewReadOnly(next)
+}
+
+func newMemoryCache(config config.Config, next cache.Cache) cache.Cache {
+	return cache.NewMemory(next, config.MemoryCacheSize, config.MemoryCacheTTL)
+}